@@ -0,0 +1,109 @@
+// Command vipps is a command line tool for Vipps MobilePay integrations built with the SDK.
+//
+// Usage:
+//
+//	vipps doctor [-profile name] [-webhook-url url]
+//	vipps catalog [-o file]
+//
+// The doctor command checks the configuration in the environment (or .env file), token
+// acquisition, API access, webhook registrations and clock skew, and exits with status 1
+// if any check fails.
+//
+// The catalog command writes a JSON catalog of the webhook events, payment states and
+// operations, and error conditions of the SDK, to standard output or the file given with -o.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/catalog"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/doctor"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/utils"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "doctor":
+		os.Exit(runDoctor(os.Args[2:]))
+	case "catalog":
+		os.Exit(runCatalog(os.Args[2:]))
+	case "help", "-h", "-help", "--help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+}
+
+// usage prints the usage of the tool
+func usage() {
+	fmt.Fprintln(os.Stderr, "Usage: vipps <command> [flags]")
+	fmt.Fprintln(os.Stderr, "")
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  doctor    diagnose configuration, credentials, API access, webhooks and clock skew")
+	fmt.Fprintln(os.Stderr, "  catalog   write a JSON catalog of events, payment states and error conditions")
+}
+
+// runDoctor runs the doctor command and returns the exit status
+func runDoctor(args []string) int {
+	flags := flag.NewFlagSet("doctor", flag.ExitOnError)
+	profile := flags.String("profile", os.Getenv(utils.ProfileEnv), "credential profile to check")
+	webhookURL := flags.String("webhook-url", "", "webhook URL that should be registered (default VIPPS_WEBHOOK_URL)")
+	_ = flags.Parse(args)
+
+	// The doctor reports token errors itself
+	vippsClient, err := utils.NewClientFromProfile(*profile)
+	if vippsClient == nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	url := *webhookURL
+	if url == "" {
+		url = utils.WebhookURL
+	}
+
+	report := doctor.Run(context.Background(), vippsClient, doctor.Options{
+		Profile:    *profile,
+		WebhookURL: url,
+	})
+	fmt.Println(report)
+
+	if !report.OK() {
+		return 1
+	}
+	return 0
+}
+
+// runCatalog runs the catalog command and returns the exit status
+func runCatalog(args []string) int {
+	flags := flag.NewFlagSet("catalog", flag.ExitOnError)
+	output := flags.String("o", "", "file to write the catalog to (default standard output)")
+	_ = flags.Parse(args)
+
+	w := os.Stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if err := catalog.Build().Write(w); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,17 @@
+module github.com/zenfulcode/vipps-mobilepay-sdk/contrib/redisstore
+
+go 1.21
+
+require (
+	github.com/redis/go-redis/v9 v9.5.1
+	github.com/zenfulcode/vipps-mobilepay-sdk v0.0.0
+)
+
+require (
+	github.com/cespare/xxhash/v2 v2.2.0 // indirect
+	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/google/uuid v1.6.0 // indirect
+	golang.org/x/sync v0.8.0 // indirect
+)
+
+replace github.com/zenfulcode/vipps-mobilepay-sdk => ../..
@@ -0,0 +1,319 @@
+// Package redisstore provides Redis implementations of the SDK's store interfaces, so services
+// running several instances can share access tokens, payment indexes, payment contexts,
+// commands and subscriptions without writing their own adapters. It is a separate module, so
+// the SDK itself does not depend on a Redis client.
+//
+// The command store is the registry of idempotency keys: commands are stored by the key their
+// operation is sent with. The SDK has no interfaces for deduplicating webhook deliveries or for
+// storing secrets, so there are no stores for them: API credentials are resolved with a
+// client.CredentialProvider and the webhook secret key is set on the webhooks.Handler.
+package redisstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/client"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/subscriptions"
+)
+
+// Compile-time checks that the stores implement the SDK interfaces
+var (
+	_ client.TokenStore            = (*TokenStore)(nil)
+	_ client.PaymentIndex          = (*PaymentIndex)(nil)
+	_ client.PaymentContextStore   = (*PaymentContextStore)(nil)
+	_ client.CommandStore          = (*CommandStore)(nil)
+	_ subscriptions.AgreementStore = (*SubscriptionStore)(nil)
+)
+
+// DefaultPrefix prefixes all keys written by the stores
+const DefaultPrefix = "vipps:"
+
+// Store holds the Redis client and key prefix shared by the stores
+type Store struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// New creates a store on a Redis client, a cluster client or a failover client. Keys are
+// prefixed with prefix; an empty prefix means DefaultPrefix.
+func New(client redis.UniversalClient, prefix string) *Store {
+	if prefix == "" {
+		prefix = DefaultPrefix
+	}
+	return &Store{client: client, prefix: prefix}
+}
+
+// key returns a prefixed key
+func (s *Store) key(parts ...string) string {
+	key := s.prefix
+	for i, part := range parts {
+		if i > 0 {
+			key += ":"
+		}
+		key += part
+	}
+	return key
+}
+
+// Tokens returns a client.TokenStore keeping access tokens until they expire
+func (s *Store) Tokens() *TokenStore {
+	return &TokenStore{store: s}
+}
+
+// TokenStore is a client.TokenStore backed by Redis keys expiring with the token
+type TokenStore struct {
+	store *Store
+}
+
+// Get returns the token stored under key, or an empty token if there is none
+func (t *TokenStore) Get(key string) (string, time.Time, error) {
+	values, err := t.store.client.HGetAll(context.Background(), t.store.key(key)).Result()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to get access token: %w", err)
+	}
+	if values["token"] == "" {
+		return "", time.Time{}, nil
+	}
+
+	expiry, err := strconv.ParseInt(values["expiry"], 10, 64)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to decode access token expiry: %w", err)
+	}
+	return values["token"], time.Unix(expiry, 0), nil
+}
+
+// Put stores a token under key, expiring it at its expiry
+func (t *TokenStore) Put(key string, token string, expiry time.Time) error {
+	ctx := context.Background()
+	redisKey := t.store.key(key)
+
+	_, err := t.store.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, redisKey, "token", token, "expiry", strconv.FormatInt(expiry.Unix(), 10))
+		pipe.ExpireAt(ctx, redisKey, expiry)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to put access token: %w", err)
+	}
+	return nil
+}
+
+// PaymentIndex returns a client.PaymentIndex keeping one set of references per metadata entry
+func (s *Store) PaymentIndex() *PaymentIndex {
+	return &PaymentIndex{store: s}
+}
+
+// PaymentIndex is a client.PaymentIndex backed by Redis sets
+type PaymentIndex struct {
+	store *Store
+}
+
+// Record stores the metadata of a created payment
+func (i *PaymentIndex) Record(reference models.Reference, metadata models.Metadata) error {
+	ctx := context.Background()
+
+	_, err := i.store.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for key, value := range metadata {
+			pipe.SAdd(ctx, i.store.key("index", key+"="+value), reference.String())
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to record payment %s: %w", reference, err)
+	}
+	return nil
+}
+
+// Find returns the references of payments whose metadata has the given key and value
+func (i *PaymentIndex) Find(key, value string) ([]models.Reference, error) {
+	members, err := i.store.client.SMembers(context.Background(), i.store.key("index", key+"="+value)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find payments: %w", err)
+	}
+
+	references := make([]models.Reference, len(members))
+	for j, member := range members {
+		references[j] = models.Reference(member)
+	}
+	return references, nil
+}
+
+// PaymentContexts returns a client.PaymentContextStore keeping payment contexts as JSON
+func (s *Store) PaymentContexts() *PaymentContextStore {
+	return &PaymentContextStore{store: s}
+}
+
+// PaymentContextStore is a client.PaymentContextStore backed by Redis
+type PaymentContextStore struct {
+	store *Store
+}
+
+// Save stores the context of a created payment
+func (p *PaymentContextStore) Save(paymentContext *models.PaymentContext) error {
+	data, err := json.Marshal(paymentContext)
+	if err != nil {
+		return fmt.Errorf("failed to encode context of payment %s: %w", paymentContext.Reference, err)
+	}
+
+	err = p.store.client.Set(context.Background(), p.store.key("context", paymentContext.Reference.String()), data, 0).Err()
+	if err != nil {
+		return fmt.Errorf("failed to save context of payment %s: %w", paymentContext.Reference, err)
+	}
+	return nil
+}
+
+// Load returns the context of a payment, or nil if none was stored
+func (p *PaymentContextStore) Load(reference models.Reference) (*models.PaymentContext, error) {
+	data, err := p.store.client.Get(context.Background(), p.store.key("context", reference.String())).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load context of payment %s: %w", reference, err)
+	}
+
+	var paymentContext models.PaymentContext
+	if err := json.Unmarshal(data, &paymentContext); err != nil {
+		return nil, fmt.Errorf("failed to decode context of payment %s: %w", reference, err)
+	}
+	return &paymentContext, nil
+}
+
+// Commands returns a client.CommandStore keeping commands as JSON by their ID, the idempotency
+// key their operation is sent with
+func (s *Store) Commands() *CommandStore {
+	return &CommandStore{store: s}
+}
+
+// CommandStore is a client.CommandStore backed by Redis
+type CommandStore struct {
+	store *Store
+}
+
+// Save stores a command, replacing the stored command with the same ID
+func (c *CommandStore) Save(command *client.Command) error {
+	data, err := json.Marshal(command)
+	if err != nil {
+		return fmt.Errorf("failed to encode command %s: %w", command.ID, err)
+	}
+
+	if err := c.store.client.Set(context.Background(), c.store.key("command", string(command.ID)), data, 0).Err(); err != nil {
+		return fmt.Errorf("failed to save command %s: %w", command.ID, err)
+	}
+	return nil
+}
+
+// Load returns the command with the ID, or nil if there is none
+func (c *CommandStore) Load(id client.CommandID) (*client.Command, error) {
+	data, err := c.store.client.Get(context.Background(), c.store.key("command", string(id))).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load command %s: %w", id, err)
+	}
+
+	var command client.Command
+	if err := json.Unmarshal(data, &command); err != nil {
+		return nil, fmt.Errorf("failed to decode command %s: %w", id, err)
+	}
+	return &command, nil
+}
+
+// Subscriptions returns a subscriptions.AgreementStore keeping subscriptions as JSON, with a
+// sorted set of due dates for ListDue and an index by Recurring agreement
+func (s *Store) Subscriptions() *SubscriptionStore {
+	return &SubscriptionStore{store: s}
+}
+
+// SubscriptionStore is a subscriptions.AgreementStore backed by Redis
+type SubscriptionStore struct {
+	store *Store
+}
+
+// Get returns the subscription with the given ID, or an error if it does not exist
+func (s *SubscriptionStore) Get(id string) (*subscriptions.Subscription, error) {
+	data, err := s.store.client.Get(context.Background(), s.store.key("subscription", id)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("subscription %s not found", id)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription %s: %w", id, err)
+	}
+
+	var sub subscriptions.Subscription
+	if err := json.Unmarshal(data, &sub); err != nil {
+		return nil, fmt.Errorf("failed to decode subscription %s: %w", id, err)
+	}
+	return &sub, nil
+}
+
+// Save creates or updates a subscription
+func (s *SubscriptionStore) Save(sub *subscriptions.Subscription) error {
+	data, err := json.Marshal(sub)
+	if err != nil {
+		return fmt.Errorf("failed to encode subscription %s: %w", sub.ID, err)
+	}
+
+	ctx := context.Background()
+	_, err = s.store.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, s.store.key("subscription", sub.ID), data, 0)
+		if sub.AgreementID != "" {
+			pipe.Set(ctx, s.store.key("agreement", sub.AgreementID), sub.ID, 0)
+		}
+
+		// Only trial and active subscriptions are charged
+		if sub.State == subscriptions.StateTrial || sub.State == subscriptions.StateActive {
+			pipe.ZAdd(ctx, s.store.key("due"), redis.Z{Score: float64(sub.NextChargeAt.Unix()), Member: sub.ID})
+		} else {
+			pipe.ZRem(ctx, s.store.key("due"), sub.ID)
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to save subscription %s: %w", sub.ID, err)
+	}
+	return nil
+}
+
+// ListDue returns trial and active subscriptions with a charge due at or before t
+func (s *SubscriptionStore) ListDue(t time.Time) ([]*subscriptions.Subscription, error) {
+	ids, err := s.store.client.ZRangeByScore(context.Background(), s.store.key("due"), &redis.ZRangeBy{
+		Min: "-inf",
+		Max: strconv.FormatInt(t.Unix(), 10),
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due subscriptions: %w", err)
+	}
+
+	due := make([]*subscriptions.Subscription, 0, len(ids))
+	for _, id := range ids {
+		sub, err := s.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if !sub.NextChargeAt.After(t) && (sub.State == subscriptions.StateTrial || sub.State == subscriptions.StateActive) {
+			due = append(due, sub)
+		}
+	}
+	return due, nil
+}
+
+// GetByAgreement returns the subscription charged through the given agreement
+func (s *SubscriptionStore) GetByAgreement(agreementID string) (*subscriptions.Subscription, error) {
+	id, err := s.store.client.Get(context.Background(), s.store.key("agreement", agreementID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, fmt.Errorf("no subscription for agreement %s", agreementID)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subscription for agreement %s: %w", agreementID, err)
+	}
+	return s.Get(id)
+}
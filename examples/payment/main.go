@@ -31,7 +31,7 @@ func main() {
 	paymentClient := client.NewPayment(vippsClient)
 
 	// Create a unique reference for the payment
-	reference := fmt.Sprintf("order-%s", uuid.New().String())
+	reference := models.Reference(fmt.Sprintf("order-%s", uuid.New().String()))
 
 	phoneNumber := utils.PhoneNumber // Customer's phone number with country code
 	req := models.CreatePaymentRequest{
@@ -43,10 +43,10 @@ func main() {
 			PhoneNumber: &phoneNumber,
 		},
 		PaymentMethod: &models.PaymentMethod{
-			Type: "WALLET",
+			Type: models.PaymentMethodWallet,
 		},
 		Reference:          reference,
-		ReturnURL:          "https://example.com/return?order=" + reference,
+		ReturnURL:          "https://example.com/return?order=" + reference.String(),
 		UserFlow:           models.UserFlowWebRedirect,
 		PaymentDescription: "Test payment",
 	}
@@ -77,7 +77,7 @@ func main() {
 	// In a test environment, you can force approve a payment
 	if vippsClient.TestMode {
 		fmt.Println("\nForce approving the payment (test mode only)...")
-		if err := paymentClient.ForceApprove(reference, phoneNumber); err != nil {
+		if err := paymentClient.ForceApprove(reference, phoneNumber, "payment example"); err != nil {
 			log.Fatalf("Failed to force approve payment: %v", err)
 		}
 		fmt.Println("Payment force approved successfully!")
@@ -2,4 +2,7 @@ module github.com/zenfulcode/vipps-mobilepay-sdk
 
 go 1.21
 
-require github.com/google/uuid v1.6.0
+require (
+	github.com/google/uuid v1.6.0
+	golang.org/x/sync v0.8.0
+)
@@ -0,0 +1,50 @@
+// Package deprecation reports uses of deprecated APIs of the SDK, once per API and process
+package deprecation
+
+import (
+	"log"
+	"sync"
+)
+
+// Notice describes the use of a deprecated API
+type Notice struct {
+	API         string // The deprecated API, e.g. "client.NewClient"
+	Replacement string // What to use instead, e.g. "client.New"
+}
+
+var (
+	mu      sync.Mutex
+	handler = logNotice
+	warned  = make(map[string]bool)
+)
+
+// SetHandler sets the function receiving deprecation notices; nil silences them. By
+// default, notices are written to the standard logger.
+func SetHandler(fn func(Notice)) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	handler = fn
+}
+
+// Warn reports the use of a deprecated API, the first time it is used in the process
+func Warn(api, replacement string) {
+	mu.Lock()
+	if warned[api] {
+		mu.Unlock()
+		return
+	}
+	warned[api] = true
+	fn := handler
+	mu.Unlock()
+
+	if fn != nil {
+		fn(Notice{API: api, Replacement: replacement})
+	}
+}
+
+// logNotice writes a notice to the standard logger
+func logNotice(notice Notice) {
+	log.Printf("Deprecated: %s is deprecated and will be removed in a future major version; use %s instead",
+		notice.API, notice.Replacement)
+}
@@ -0,0 +1,113 @@
+// Package catalog builds a machine-readable catalog of the payment taxonomy: webhook events,
+// payment states and operations, and the error conditions of the API, e.g. for a developer
+// portal. catalog.json in this directory is generated from the models with go generate.
+package catalog
+
+//go:generate go run ../../cmd/vipps catalog -o catalog.json
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/client"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// Version is the version of the catalog format, increased on incompatible changes
+const Version = 1
+
+// Catalog lists the payment taxonomy
+type Catalog struct {
+	Version    int         `json:"version"`
+	Events     []Event     `json:"events"`
+	States     []State     `json:"states"`
+	Operations []Operation `json:"operations"`
+	Errors     []Error     `json:"errors"`
+}
+
+// Event describes a payment event and the webhook event type it is sent as
+type Event struct {
+	Name        models.PaymentEventName `json:"name"`
+	WebhookType models.WebhookEventType `json:"webhookType"`
+	Description string                  `json:"description"`
+}
+
+// State describes a payment state and what can happen to payments in it
+type State struct {
+	Name        models.PaymentState       `json:"name"`
+	Description string                    `json:"description"`
+	Final       bool                      `json:"final"`       // Whether payments in the state can no longer change state
+	Transitions []models.PaymentState     `json:"transitions"` // States payments can move to
+	Operations  []models.PaymentOperation `json:"operations"`  // Operations allowed in the state
+}
+
+// Operation describes an operation the merchant can perform on payments
+type Operation struct {
+	Name        models.PaymentOperation `json:"name"`
+	Description string                  `json:"description"`
+}
+
+// Error describes an error condition that API errors are matched against with errors.Is
+type Error struct {
+	Name    string `json:"name"`    // Name of the sentinel error, e.g. ErrPaymentNotFound
+	Message string `json:"message"` // Message of the sentinel error
+	Status  int    `json:"status"`  // HTTP status code of the responses matching it
+}
+
+// Build builds the catalog of the taxonomy defined by the models of this SDK version
+func Build() *Catalog {
+	c := &Catalog{Version: Version}
+
+	for _, name := range models.PaymentEventNames() {
+		c.Events = append(c.Events, Event{
+			Name:        name,
+			WebhookType: name.WebhookEventType(),
+			Description: name.Description(),
+		})
+	}
+
+	states := models.PaymentStates()
+	for _, state := range states {
+		transitions := []models.PaymentState{}
+		for _, to := range states {
+			if models.CanTransition(state, to) {
+				transitions = append(transitions, to)
+			}
+		}
+		operations := models.AllowedOperations(state)
+		if operations == nil {
+			operations = []models.PaymentOperation{}
+		}
+
+		c.States = append(c.States, State{
+			Name:        state,
+			Description: state.Description(),
+			Final:       state.IsFinal(),
+			Transitions: transitions,
+			Operations:  operations,
+		})
+	}
+
+	for _, operation := range models.PaymentOperations() {
+		c.Operations = append(c.Operations, Operation{Name: operation, Description: operation.Description()})
+	}
+
+	for _, condition := range client.ErrorConditions() {
+		c.Errors = append(c.Errors, Error{Name: condition.Name, Message: condition.Err.Error(), Status: condition.Status})
+	}
+
+	return c
+}
+
+// Write writes the catalog as indented JSON
+func (c *Catalog) Write(w io.Writer) error {
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal catalog: %w", err)
+	}
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		return fmt.Errorf("failed to write catalog: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+// Package checkout implements a complete checkout flow without any persistence, for
+// microsites and ticket sales that do not track orders: a payment is created and the user
+// redirected to Vipps MobilePay, and on return the payment is verified and captured.
+// Everything the return handler needs is carried in a signed token in the return URL.
+package checkout
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/client"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// TokenParam is the query parameter of the return URL carrying the signed return token
+const TokenParam = "checkout"
+
+// DefaultTokenTTL is how long a return token is accepted after the checkout started
+const DefaultTokenTTL = time.Hour
+
+var (
+	// ErrInvalidToken is returned when a return token is missing, malformed or not signed by this checkout
+	ErrInvalidToken = errors.New("invalid checkout token")
+	// ErrTokenExpired is returned when a return token is used after its validity
+	ErrTokenExpired = errors.New("checkout token expired")
+	// ErrAmountMismatch is returned when the payment amount differs from the signed amount
+	ErrAmountMismatch = errors.New("payment amount does not match checkout")
+)
+
+// Order describes what the user pays for
+type Order struct {
+	Amount      models.Amount   // Amount to pay
+	Description string          // Description shown to the user
+	PhoneNumber string          // Optional phone number, prefilled in the landing page
+	Metadata    models.Metadata // Optional metadata stored with the payment
+}
+
+// Result is the outcome of a checkout when the user returns
+type Result struct {
+	Reference models.Reference    // Reference of the payment
+	Amount    models.Amount       // Amount of the checkout
+	State     models.PaymentState // State of the payment
+	Paid      bool                // Whether the payment was authorized and captured
+}
+
+// Checkout runs stateless checkouts
+type Checkout struct {
+	payment   *client.Payment
+	secret    []byte
+	returnURL string
+	tokenTTL  time.Duration
+	now       func() time.Time
+}
+
+// Option configures a Checkout
+type Option func(*Checkout)
+
+// WithTokenTTL sets how long a return token is accepted after the checkout started
+func WithTokenTTL(ttl time.Duration) Option {
+	return func(c *Checkout) {
+		c.tokenTTL = ttl
+	}
+}
+
+// New creates a stateless checkout. Return tokens are signed with secret, which must be kept
+// private and shared by all instances of the service. returnURL is the URL of the return
+// handler, where the user lands after acting on the payment.
+func New(payment *client.Payment, secret []byte, returnURL string, opts ...Option) *Checkout {
+	c := &Checkout{
+		payment:   payment,
+		secret:    secret,
+		returnURL: returnURL,
+		tokenTTL:  DefaultTokenTTL,
+		now:       time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Start creates a payment for the order and returns the URL to redirect the user to
+func (c *Checkout) Start(order Order, opts ...client.CallOption) (string, error) {
+	if order.Amount.Currency == "" {
+		return "", &models.ValidationError{Problems: []models.FieldProblem{{Field: "amount.currency", Reason: "is required"}}}
+	}
+
+	reference := models.Reference("checkout-" + uuid.New().String())
+
+	returnURL, err := c.signedReturnURL(token{
+		Reference: reference,
+		Amount:    order.Amount,
+		Expires:   c.now().Add(c.tokenTTL),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	req := models.CreatePaymentRequest{
+		Amount:             order.Amount,
+		PaymentMethod:      &models.PaymentMethod{Type: models.PaymentMethodWallet},
+		Reference:          reference,
+		ReturnURL:          returnURL,
+		UserFlow:           models.UserFlowWebRedirect,
+		PaymentDescription: order.Description,
+		Metadata:           order.Metadata,
+	}
+	if order.PhoneNumber != "" {
+		phoneNumber := order.PhoneNumber
+		req.Customer = &models.Customer{PhoneNumber: &phoneNumber}
+	}
+
+	resp, err := c.payment.Create(req, opts...)
+	if err != nil {
+		return "", fmt.Errorf("failed to start checkout: %w", err)
+	}
+
+	return resp.RedirectURL, nil
+}
+
+// Complete verifies the return token of a request to the return handler, checks the payment
+// and captures it if it was authorized. Completing a checkout again, e.g. when the user
+// reloads the page, does not capture twice. A payment the user aborted or let expire is
+// reported in the result, not as an error.
+func (c *Checkout) Complete(r *http.Request, opts ...client.CallOption) (*Result, error) {
+	t, err := c.verify(r.URL.Query().Get(TokenParam))
+	if err != nil {
+		return nil, err
+	}
+
+	payment, err := c.payment.Get(t.Reference, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify checkout: %w", err)
+	}
+
+	result := &Result{
+		Reference: t.Reference,
+		Amount:    t.Amount,
+		State:     payment.State,
+	}
+	if payment.Amount != t.Amount {
+		return result, fmt.Errorf("%w: payment %s is %d %s, expected %d %s", ErrAmountMismatch,
+			t.Reference, payment.Amount.Value, payment.Amount.Currency, t.Amount.Value, t.Amount.Currency)
+	}
+	if payment.State != models.PaymentStateAuthorized {
+		return result, nil
+	}
+
+	// Capture what is left, so a repeated completion does not capture twice
+	remaining := t.Amount.Value
+	if payment.Aggregate != nil {
+		remaining -= payment.Aggregate.CapturedAmount.Value + payment.Aggregate.CancelledAmount.Value
+	}
+	if remaining > 0 {
+		_, err := c.payment.Capture(t.Reference, models.ModificationRequest{
+			ModificationAmount: models.Amount{Currency: t.Amount.Currency, Value: remaining},
+		}, opts...)
+		if err != nil {
+			return result, fmt.Errorf("failed to capture checkout: %w", err)
+		}
+	}
+
+	result.Paid = true
+	return result, nil
+}
+
+// ReturnHandler returns an http.Handler for the return URL, completing the checkout and
+// passing the result to respond, which renders the page shown to the user
+func (c *Checkout) ReturnHandler(respond func(w http.ResponseWriter, r *http.Request, result *Result, err error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result, err := c.Complete(r, client.WithContext(r.Context()))
+		respond(w, r, result, err)
+	})
+}
+
+// token is the content of a return token
+type token struct {
+	Reference models.Reference
+	Amount    models.Amount
+	Expires   time.Time
+}
+
+// signedReturnURL returns the return URL carrying the signed token
+func (c *Checkout) signedReturnURL(t token) (string, error) {
+	u, err := url.Parse(c.returnURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid return URL: %w", err)
+	}
+
+	query := u.Query()
+	query.Set(TokenParam, c.sign(t))
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// sign encodes and signs a token
+func (c *Checkout) sign(t token) string {
+	payload := strings.Join([]string{
+		string(t.Reference),
+		strconv.Itoa(t.Amount.Value),
+		t.Amount.Currency,
+		strconv.FormatInt(t.Expires.Unix(), 10),
+	}, "|")
+
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(c.mac(encoded))
+}
+
+// verify checks the signature and validity of a token and decodes it
+func (c *Checkout) verify(signed string) (*token, error) {
+	encoded, signature, ok := strings.Cut(signed, ".")
+	if !ok {
+		return nil, ErrInvalidToken
+	}
+
+	mac, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, c.mac(encoded)) {
+		return nil, ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	fields := strings.Split(string(payload), "|")
+	if len(fields) != 4 {
+		return nil, ErrInvalidToken
+	}
+	value, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+	expires, err := strconv.ParseInt(fields[3], 10, 64)
+	if err != nil {
+		return nil, ErrInvalidToken
+	}
+
+	t := &token{
+		Reference: models.Reference(fields[0]),
+		Amount:    models.Amount{Currency: fields[2], Value: value},
+		Expires:   time.Unix(expires, 0),
+	}
+	if !c.now().Before(t.Expires) {
+		return nil, ErrTokenExpired
+	}
+
+	return t, nil
+}
+
+// mac computes the signature of an encoded token
+func (c *Checkout) mac(encoded string) []byte {
+	h := hmac.New(sha256.New, c.secret)
+	h.Write([]byte(encoded))
+	return h.Sum(nil)
+}
@@ -0,0 +1,154 @@
+package client
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// AbortParam is the query parameter AbortURL adds to a return URL
+const AbortParam = "error"
+
+// abortValues are the values of status-like query parameters that indicate the user aborted
+var abortValues = map[string]bool{
+	"abort":     true,
+	"aborted":   true,
+	"cancel":    true,
+	"cancelled": true,
+	"canceled":  true,
+	"rejected":  true,
+}
+
+// ReturnIndicator is what the query of a return URL callback says about the payment. The
+// query is only a hint, anyone can edit it; the payment state is what counts.
+type ReturnIndicator struct {
+	Aborted     bool   // The query carries an error or abort indicator
+	Error       string // Value of the error parameter, if any
+	Description string // Value of the error_description parameter, if any
+}
+
+// ParseReturn detects error and abort indicators in the query of a return URL callback:
+// an error parameter, an aborted, cancelled or canceled flag, or a status parameter with a
+// value such as "cancel" or "aborted"
+func ParseReturn(query url.Values) ReturnIndicator {
+	indicator := ReturnIndicator{
+		Error:       query.Get("error"),
+		Description: query.Get("error_description"),
+	}
+	if indicator.Error != "" {
+		indicator.Aborted = true
+	}
+
+	for _, flag := range []string{"aborted", "cancelled", "canceled"} {
+		if value := strings.ToLower(query.Get(flag)); value == "true" || value == "1" {
+			indicator.Aborted = true
+		}
+	}
+	for _, param := range []string{"status", "result"} {
+		if abortValues[strings.ToLower(query.Get(param))] {
+			indicator.Aborted = true
+		}
+	}
+
+	return indicator
+}
+
+// AbortURL returns the return URL with an abort indicator, reason defaulting to "aborted".
+// Use it for the cancel links of your own pages, e.g. a "back to shop" link shown while
+// waiting for the user, so they land on the return handler the same way as from the app.
+func AbortURL(returnURL, reason string) (string, error) {
+	u, err := url.Parse(returnURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid return URL: %w", err)
+	}
+	if reason == "" {
+		reason = "aborted"
+	}
+
+	query := u.Query()
+	query.Set(AbortParam, reason)
+	u.RawQuery = query.Encode()
+
+	return u.String(), nil
+}
+
+// ReturnOutcome is the outcome of a payment when the user returns to the merchant
+type ReturnOutcome string
+
+const (
+	// ReturnAuthorized means the user authorized the payment, whatever the query says
+	ReturnAuthorized ReturnOutcome = "AUTHORIZED"
+	// ReturnPending means the payment was not acted upon yet; the user may still complete it
+	// in the app, so keep polling or wait for the webhook
+	ReturnPending ReturnOutcome = "PENDING"
+	// ReturnAbandoned means the user returned with an abort indicator, but the payment is still
+	// open and could be authorized later
+	ReturnAbandoned ReturnOutcome = "ABANDONED"
+	// ReturnAborted means the user aborted the payment in the app
+	ReturnAborted ReturnOutcome = "ABORTED"
+	// ReturnExpired means the user did not act on the payment in time
+	ReturnExpired ReturnOutcome = "EXPIRED"
+	// ReturnTerminated means the merchant cancelled the payment
+	ReturnTerminated ReturnOutcome = "TERMINATED"
+)
+
+// FollowUp is the call the merchant should make after the user returns
+type FollowUp string
+
+const (
+	// FollowUpNone means no call is needed
+	FollowUpNone FollowUp = "NONE"
+	// FollowUpCancel means the payment should be cancelled, so it cannot be authorized after the
+	// user gave up on it
+	FollowUpCancel FollowUp = "CANCEL"
+)
+
+// ClassifyReturn maps the indicator of a return URL callback and the state of the payment to
+// the outcome and the follow-up call. The payment state takes precedence: an authorized
+// payment is reported as authorized even if the query says it was aborted.
+func ClassifyReturn(indicator ReturnIndicator, state models.PaymentState) (ReturnOutcome, FollowUp) {
+	switch state {
+	case models.PaymentStateAuthorized:
+		return ReturnAuthorized, FollowUpNone
+	case models.PaymentStateAborted:
+		return ReturnAborted, FollowUpNone
+	case models.PaymentStateExpired:
+		return ReturnExpired, FollowUpNone
+	case models.PaymentStateTerminated:
+		return ReturnTerminated, FollowUpNone
+	}
+
+	if indicator.Aborted {
+		return ReturnAbandoned, FollowUpCancel
+	}
+	return ReturnPending, FollowUpNone
+}
+
+// ReturnResult describes a payment when the user returns to the merchant
+type ReturnResult struct {
+	Reference models.Reference    // Reference of the payment
+	Indicator ReturnIndicator     // What the query of the return URL said
+	State     models.PaymentState // State of the payment
+	Outcome   ReturnOutcome       // Outcome of the payment
+	FollowUp  FollowUp            // Call the merchant should make
+}
+
+// ResolveReturn fetches the payment the user returned from and classifies the return, see
+// ClassifyReturn. It makes no follow-up call; pass FollowUpCancel results to Cancel.
+func (p *Payment) ResolveReturn(reference models.Reference, query url.Values, opts ...CallOption) (*ReturnResult, error) {
+	payment, err := p.Get(reference, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve return: %w", err)
+	}
+
+	result := &ReturnResult{
+		Reference: payment.Reference,
+		Indicator: ParseReturn(query),
+		State:     payment.State,
+	}
+	result.Outcome, result.FollowUp = ClassifyReturn(result.Indicator, payment.State)
+
+	return result, nil
+}
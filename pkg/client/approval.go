@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// ApprovalRequest describes an operation waiting for approval
+type ApprovalRequest struct {
+	Operation models.PaymentOperation // The operation, e.g. REFUND or CANCEL
+	Reference models.Reference        // Reference of the payment
+	Amount    models.Amount           // Amount moved by the operation; for cancellations the amount released
+}
+
+// ApprovalDecision is the outcome of an approval request
+type ApprovalDecision struct {
+	Approved bool   // Whether the operation may proceed
+	Approver string // Who approved or rejected the operation, for the audit trail
+	Reason   string // Optional reason given by the approver
+}
+
+// ApprovalProvider decides on operations that require a second approval (maker-checker).
+// RequestApproval blocks until the operation is approved or rejected, or ctx is done; ctx
+// carries the deadline of the operation, see WithDeadlineBudget.
+type ApprovalProvider interface {
+	RequestApproval(ctx context.Context, request ApprovalRequest) (ApprovalDecision, error)
+}
+
+// WithApproval requires approval from provider for operations with an amount above the
+// threshold for the operation, in minor units, e.g. to enforce four-eyes policies for large
+// refunds. Operations without a threshold need no approval; with a threshold of zero, every
+// operation of its kind that moves money requires approval. Rejected operations fail with an error matching
+// ErrApprovalRejected and are not sent.
+func WithApproval(provider ApprovalProvider, thresholds map[models.PaymentOperation]int) PaymentOption {
+	return func(p *Payment) {
+		p.approval = &approvalPolicy{provider: provider, thresholds: thresholds}
+	}
+}
+
+// approvalPolicy holds the approval provider and thresholds of a Payment handler
+type approvalPolicy struct {
+	provider   ApprovalProvider
+	thresholds map[models.PaymentOperation]int
+}
+
+// approve requests approval for an operation if its amount exceeds the threshold
+func (p *Payment) approve(cfg callConfig, request ApprovalRequest) error {
+	if p.approval == nil {
+		return nil
+	}
+
+	threshold, ok := p.approval.thresholds[request.Operation]
+	if !ok || request.Amount.Value <= threshold {
+		return nil
+	}
+
+	// The approval counts against the deadline of the operation it is requested for
+	ctx, cancel := cfg.deadlineContext()
+	defer cancel()
+	decision, err := p.approval.provider.RequestApproval(ctx, request)
+	if err != nil {
+		return fmt.Errorf("failed to request approval: %w", err)
+	}
+	if !decision.Approved {
+		return &ApprovalRejectedError{Request: request, Decision: decision}
+	}
+
+	return nil
+}
+
+// needsApproval reports whether an operation requires approval, so callers can skip work
+// needed only to build the approval request
+func (p *Payment) needsApproval(operation models.PaymentOperation) bool {
+	if p.approval == nil {
+		return false
+	}
+	_, ok := p.approval.thresholds[operation]
+	return ok
+}
@@ -0,0 +1,141 @@
+package client
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/redact"
+)
+
+// AuditRecord is a sanitized copy of an API call. Credentials, tokens and personal data
+// such as phone numbers are redacted from headers and bodies.
+type AuditRecord struct {
+	Time     time.Time     // When the request was sent
+	Duration time.Duration // How long the call took
+
+	Method         string
+	URL            string
+	RequestHeader  http.Header
+	RequestBody    []byte // Redacted JSON body; nil if there was none or it was streamed
+	StatusCode     int    // 0 if no response was received
+	ResponseHeader http.Header
+	ResponseBody   []byte // Redacted JSON body; nil if there was none or it was not JSON
+	Error          string // Error of the call, if any
+
+	// Test-only endpoints, such as force approving a payment, are recorded with the reason
+	// they were called for, so compliance can show test tooling never ran against production
+	TestOnly bool   // Whether the endpoint is only available in the test environment
+	Reason   string // Why the call was made, as given by the caller
+
+	// Hash chain making the trail tamper-evident: Hash covers the record and PrevHash, the
+	// Hash of the record before it. Removing or altering a record breaks the chain.
+	PrevHash string
+	Hash     string
+}
+
+// AuditSink receives an audit record for every API call, including token requests and
+// retried attempts. Records are delivered in order; a failing sink does not fail the call.
+type AuditSink interface {
+	Record(record AuditRecord) error
+}
+
+// AuditSinkFunc adapts a function to the AuditSink interface
+type AuditSinkFunc func(record AuditRecord) error
+
+// Record calls f(record)
+func (f AuditSinkFunc) Record(record AuditRecord) error {
+	return f(record)
+}
+
+// SetAuditSink sets the sink receiving an audit record for every API call. The hash chain
+// starts at prevHash, e.g. the Hash of the last record persisted by a previous process.
+func (c *Client) SetAuditSink(sink AuditSink, prevHash string) {
+	c.auditMu.Lock()
+	defer c.auditMu.Unlock()
+
+	c.auditSink = sink
+	c.auditHash = prevHash
+}
+
+// audit records an API call with the audit sink
+func (c *Client) audit(cfg callConfig, req *http.Request, reqBody []byte, resp *response, start time.Time, err error) {
+	duration := time.Since(start)
+	record := AuditRecord{
+		Time:           c.now().Add(-duration),
+		Duration:       duration,
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		RequestHeader:  redact.Header(req.Header),
+		RequestBody:    redact.JSON(reqBody),
+		StatusCode:     resp.statusCode,
+		ResponseHeader: redact.Header(resp.header),
+		ResponseBody:   redact.JSON(resp.body),
+		TestOnly:       isTestOnly(req.URL.Path),
+		Reason:         cfg.reason,
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+
+	c.auditMu.Lock()
+	defer c.auditMu.Unlock()
+
+	record.PrevHash = c.auditHash
+	record.Hash = record.computeHash()
+	c.auditHash = record.Hash
+
+	if err := c.auditSink.Record(record); err != nil {
+		c.logf("Error recording audit record for %s %s: %v", record.Method, record.URL, err)
+	}
+}
+
+// computeHash returns the hash of the record, covering every field but Hash itself
+func (r AuditRecord) computeHash() string {
+	h := sha256.New()
+	write := func(s string) {
+		h.Write([]byte(strconv.Itoa(len(s))))
+		h.Write([]byte{':'})
+		h.Write([]byte(s))
+	}
+
+	write(r.PrevHash)
+	write(r.Time.UTC().Format(time.RFC3339Nano))
+	write(r.Duration.String())
+	write(r.Method)
+	write(r.URL)
+	write(headerString(r.RequestHeader))
+	write(string(r.RequestBody))
+	write(strconv.Itoa(r.StatusCode))
+	write(headerString(r.ResponseHeader))
+	write(string(r.ResponseBody))
+	write(r.Error)
+	// Only covered when set, so trails recorded before the fields existed still verify
+	if r.TestOnly || r.Reason != "" {
+		write(strconv.FormatBool(r.TestOnly))
+		write(r.Reason)
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// VerifyHash reports whether the record's Hash matches its content, so a stored trail can be
+// checked by verifying every record and that each PrevHash equals the Hash before it
+func (r AuditRecord) VerifyHash() bool {
+	return r.Hash == r.computeHash()
+}
+
+// isTestOnly reports whether a path is an endpoint of the test environment only
+func isTestOnly(path string) bool {
+	return strings.Contains(path, "/test/")
+}
+
+// headerString returns a canonical string form of a header
+func headerString(header http.Header) string {
+	var b strings.Builder
+	_ = header.WriteSubset(&b, nil)
+	return b.String()
+}
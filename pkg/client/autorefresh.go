@@ -0,0 +1,85 @@
+package client
+
+import (
+	"context"
+	"time"
+)
+
+// DefaultRefreshMargin is how long before expiry StartAutoRefresh renews the access token by default
+const DefaultRefreshMargin = 5 * time.Minute
+
+// autoRefreshRetryDelay is the wait before a failed background refresh is retried
+const autoRefreshRetryDelay = 10 * time.Second
+
+// SetRefreshMargin sets how long before its expiry the access token is renewed. Requests
+// treat a token within the margin as expired, so a token never expires while a request is
+// in flight, and StartAutoRefresh renews it in the background when the margin is reached.
+func (c *Client) SetRefreshMargin(margin time.Duration) {
+	c.refreshMargin = margin
+}
+
+// StartAutoRefresh starts a goroutine renewing the access token before it expires, so calls
+// after a quiet period do not wait for a token request. The token is renewed the refresh
+// margin before expiry, DefaultRefreshMargin unless set with SetRefreshMargin. Failed
+// renewals are logged and retried. The goroutine stops when ctx is done. It does nothing
+// when token fetching is disabled, see DisableTokenFetching.
+func (c *Client) StartAutoRefresh(ctx context.Context) {
+	if c.noTokenFetching {
+		return
+	}
+
+	margin := c.refreshMargin
+	if margin <= 0 {
+		margin = DefaultRefreshMargin
+	}
+
+	go func() {
+		timer := time.NewTimer(0)
+		defer timer.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-timer.C:
+			}
+
+			if err := c.renewToken(margin); err != nil {
+				c.logf("Error refreshing access token in the background, retrying in %s: %v", autoRefreshRetryDelay, err)
+				timer.Reset(autoRefreshRetryDelay)
+				continue
+			}
+			// Tokens living shorter than the margin are renewed halfway through their validity
+			remaining := c.tokenExpiry().Sub(c.now())
+			wait := remaining - margin
+			if wait < remaining/2 {
+				wait = remaining / 2
+			}
+			// A token that expires at once, e.g. with an expires_in of 0, must not renew in a loop
+			if wait < autoRefreshRetryDelay {
+				wait = autoRefreshRetryDelay
+			}
+			timer.Reset(wait)
+		}
+	}()
+}
+
+// renewToken replaces the access token unless it is valid beyond the margin, preferring a
+// token in the token store that is valid beyond the margin over fetching a new one
+func (c *Client) renewToken(margin time.Duration) error {
+	if c.now().Add(margin).Before(c.tokenExpiry()) {
+		return nil
+	}
+	if c.tokenStore != nil && c.loadStoredToken() && c.now().Add(margin).Before(c.tokenExpiry()) {
+		return nil
+	}
+	return c.GetAccessToken()
+}
+
+// tokenExpiry returns the expiry of the current access token
+func (c *Client) tokenExpiry() time.Time {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+
+	return c.TokenExpiry
+}
@@ -0,0 +1,82 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// errBodyNotRewindable is returned when a streamed request body cannot be sent again
+var errBodyNotRewindable = errors.New("request body cannot be rewound for another attempt")
+
+// requestBody is a request body prepared for sending, possibly in several attempts
+type requestBody struct {
+	data   []byte    // Body that is sent as is
+	stream io.Reader // Streamed body, used when data is nil
+	sent   bool      // Whether the stream has been consumed by an attempt
+
+	// Content encoding of the body, e.g. gzip; empty if it is not encoded
+	encoding string
+
+	// Body before encoding, for the audit trail; nil for streamed bodies
+	plain []byte
+}
+
+// newRequestBody prepares a request body. Values of type []byte and json.RawMessage are
+// sent as is, so callers that already have JSON (e.g. when forwarding) avoid marshaling
+// it twice. An io.Reader is streamed without being buffered. Any other value is marshaled
+// to JSON with the codec.
+func newRequestBody(body interface{}, codec Codec) (*requestBody, error) {
+	switch b := body.(type) {
+	case nil:
+		return &requestBody{}, nil
+	case []byte:
+		return &requestBody{data: b, plain: b}, nil
+	case json.RawMessage:
+		return &requestBody{data: b, plain: b}, nil
+	case io.Reader:
+		return &requestBody{stream: b}, nil
+	}
+
+	data, err := codec.Marshal(body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal request body: %w", err)
+	}
+	return &requestBody{data: data, plain: data}, nil
+}
+
+// rewindable reports whether the body can be sent more than once. Streamed bodies can
+// only be resent if they implement io.Seeker.
+func (b *requestBody) rewindable() bool {
+	if b.stream == nil {
+		return true
+	}
+	_, ok := b.stream.(io.Seeker)
+	return ok
+}
+
+// reader returns the body for the next attempt, or nil if there is no body
+func (b *requestBody) reader() (io.Reader, error) {
+	if b.stream == nil {
+		if b.data == nil {
+			return nil, nil
+		}
+		return bytes.NewReader(b.data), nil
+	}
+
+	if b.sent {
+		seeker, ok := b.stream.(io.Seeker)
+		if !ok {
+			return nil, errBodyNotRewindable
+		}
+		if _, err := seeker.Seek(0, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to rewind request body: %w", err)
+		}
+	}
+	b.sent = true
+
+	// Hide the concrete type, so the stream is not buffered or closed by net/http
+	return struct{ io.Reader }{b.stream}, nil
+}
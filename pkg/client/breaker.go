@@ -0,0 +1,150 @@
+package client
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned without sending the request while the circuit breaker is open
+var ErrCircuitOpen = errors.New("circuit breaker is open: Vipps MobilePay API is unavailable")
+
+// CircuitBreakerConfig configures the circuit breaker. Network errors and 5xx responses
+// count as failures; any other response shows the API is reachable and resets the count.
+type CircuitBreakerConfig struct {
+	FailureThreshold int           // Consecutive failures that open the circuit
+	OpenTimeout      time.Duration // How long the circuit stays open before a trial request is let through
+}
+
+// circuitState is the state of the circuit breaker
+type circuitState int
+
+const (
+	circuitClosed circuitState = iota
+	circuitOpen
+	circuitHalfOpen
+)
+
+// circuitBreaker fails requests fast after repeated failures, so callers can degrade
+// gracefully instead of waiting for timeouts while the API is down
+type circuitBreaker struct {
+	config CircuitBreakerConfig
+
+	mu       sync.Mutex
+	state    circuitState
+	failures int
+	openedAt time.Time
+	trialAt  time.Time // When the trial request of the half-open circuit was let through
+}
+
+// allow returns ErrCircuitOpen if a request may not be sent, and reports whether the request
+// is the trial request. After the open timeout a single trial request is let through; its
+// outcome closes or reopens the circuit. A trial without an outcome within the open timeout,
+// e.g. one whose caller never reported it, is replaced by a new trial.
+func (b *circuitBreaker) allow() (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.config.OpenTimeout {
+			return false, ErrCircuitOpen
+		}
+	case circuitHalfOpen:
+		// A trial request is in flight
+		if time.Since(b.trialAt) < b.config.OpenTimeout {
+			return false, ErrCircuitOpen
+		}
+	default:
+		return false, nil
+	}
+
+	b.state = circuitHalfOpen
+	b.trialAt = time.Now()
+	return true, nil
+}
+
+// record registers the outcome of a request
+func (b *circuitBreaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !failed {
+		b.state = circuitClosed
+		b.failures = 0
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.config.FailureThreshold {
+		b.state = circuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// abandon releases a trial request that ended without an outcome, e.g. because the caller
+// canceled it. The circuit opens again, so a new trial is let through after the open timeout.
+func (b *circuitBreaker) abandon() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == circuitHalfOpen {
+		b.state = circuitOpen
+		b.openedAt = time.Now()
+	}
+}
+
+// isOpen reports whether the circuit is currently rejecting requests
+func (b *circuitBreaker) isOpen() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state == circuitOpen && time.Since(b.openedAt) < b.config.OpenTimeout
+}
+
+// degraded reports whether the breaker has counted failures since the last success, or is not closed
+func (b *circuitBreaker) degraded() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.state != circuitClosed || b.failures > 0
+}
+
+// SetCircuitBreaker enables the circuit breaker. After the configured number of consecutive
+// failures, requests fail fast with ErrCircuitOpen until the open timeout has passed.
+func (c *Client) SetCircuitBreaker(config CircuitBreakerConfig) {
+	if config.FailureThreshold < 1 {
+		config.FailureThreshold = 1
+	}
+	c.breaker = &circuitBreaker{config: config}
+}
+
+// CircuitOpen reports whether the circuit breaker is open and requests are failing fast
+func (c *Client) CircuitOpen() bool {
+	return c.breaker != nil && c.breaker.isOpen()
+}
+
+// breakerAllow checks the circuit breaker before sending a request, and reports whether the
+// request is the trial request of a half-open circuit
+func (c *Client) breakerAllow() (bool, error) {
+	if c.breaker == nil {
+		return false, nil
+	}
+	return c.breaker.allow()
+}
+
+// breakerAbandon releases the trial request of the circuit breaker for a trial that ended
+// without an outcome
+func (c *Client) breakerAbandon(trial bool) {
+	if trial && c.breaker != nil {
+		c.breaker.abandon()
+	}
+}
+
+// breakerRecord reports the outcome of a request to the circuit breaker
+func (c *Client) breakerRecord(statusCode int, err error) {
+	if c.breaker == nil {
+		return
+	}
+	c.breaker.record(err != nil && (statusCode == 0 || statusCode >= 500))
+}
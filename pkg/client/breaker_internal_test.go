@@ -0,0 +1,30 @@
+package client
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCircuitBreakerLostTrial(t *testing.T) {
+	b := &circuitBreaker{config: CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: 20 * time.Millisecond}}
+	b.record(true)
+
+	time.Sleep(30 * time.Millisecond)
+	if trial, err := b.allow(); err != nil || !trial {
+		t.Fatalf("allow after the open timeout = %v, %v, want a trial", trial, err)
+	}
+	// The trial never reports an outcome
+	if _, err := b.allow(); err != ErrCircuitOpen {
+		t.Fatalf("allow during the trial = %v, want ErrCircuitOpen", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	trial, err := b.allow()
+	if err != nil || !trial {
+		t.Fatalf("allow after the trial timed out = %v, %v, want a new trial", trial, err)
+	}
+	b.record(false)
+	if trial, err := b.allow(); err != nil || trial {
+		t.Fatalf("allow after a successful trial = %v, %v, want a closed circuit", trial, err)
+	}
+}
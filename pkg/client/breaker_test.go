@@ -0,0 +1,64 @@
+package client_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/client"
+)
+
+// newBreakerClient returns a client with a circuit breaker opening on the first failure,
+// sending requests to a server answering request n with respond(n)
+func newBreakerClient(t *testing.T, respond func(n int32, w http.ResponseWriter)) *client.Client {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		respond(atomic.AddInt32(&requests, 1), w)
+	}))
+	t.Cleanup(server.Close)
+
+	c := client.New(client.Credentials{MSN: "123456"}, client.WithBaseURL(server.URL))
+	c.SetAccessToken("test-token", time.Now().Add(time.Hour))
+	c.SetCircuitBreaker(client.CircuitBreakerConfig{FailureThreshold: 1, OpenTimeout: 20 * time.Millisecond})
+	return c
+}
+
+func TestCircuitBreakerCanceledTrial(t *testing.T) {
+	c := newBreakerClient(t, func(n int32, w http.ResponseWriter) {
+		switch n {
+		case 1:
+			w.WriteHeader(http.StatusInternalServerError)
+		case 2:
+			time.Sleep(200 * time.Millisecond)
+		}
+		w.Write([]byte("{}"))
+	})
+
+	if _, err := client.Do[json.RawMessage](c, http.MethodGet, "/test", nil); err == nil {
+		t.Fatal("failing request succeeded")
+	}
+	if !c.CircuitOpen() {
+		t.Fatal("circuit not open after a failure")
+	}
+
+	// The trial request is canceled before the API answers
+	time.Sleep(30 * time.Millisecond)
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.Do[json.RawMessage](c, http.MethodGet, "/test", nil, client.WithContext(ctx)); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("trial request returned %v, want context.DeadlineExceeded", err)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, err := client.Do[json.RawMessage](c, http.MethodGet, "/test", nil); err != nil {
+		t.Fatalf("request after the canceled trial failed: %v", err)
+	}
+	if c.CircuitOpen() {
+		t.Fatal("circuit open after a successful trial")
+	}
+}
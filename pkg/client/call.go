@@ -0,0 +1,211 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// CallOption configures a single API call, overriding the client's defaults for that call
+type CallOption func(*callConfig)
+
+// WithContext sets the context of a call. The call is aborted when the context is canceled,
+// and a deadline on the context bounds the call including its retries.
+func WithContext(ctx context.Context) CallOption {
+	return func(cfg *callConfig) {
+		cfg.ctx = ctx
+	}
+}
+
+// WithRequestTimeout sets the total time a call may take, including retries. It replaces the
+// client timeout and any deadline budget for this call, so a long-running call such as a
+// report download can be given more time than a user-facing call.
+func WithRequestTimeout(timeout time.Duration) CallOption {
+	return func(cfg *callConfig) {
+		cfg.deadline = time.Now().Add(timeout)
+		cfg.override = true
+	}
+}
+
+// WithRequestDeadline sets the time by which a call must have completed, including retries.
+// Like WithRequestTimeout, it replaces the client timeout and any deadline budget for this call.
+func WithRequestDeadline(deadline time.Time) CallOption {
+	return func(cfg *callConfig) {
+		cfg.deadline = deadline
+		cfg.override = true
+	}
+}
+
+// WithIdempotencyKey sets the idempotency key of a call. Operations such as Create, Capture
+// and Refund generate a key per call otherwise, which is reused for the automatic retries of
+// the call; pass the same key when retrying an operation manually, e.g. after a timeout, so
+// it is not applied twice. Calls made with Do only send a key when it is set.
+func WithIdempotencyKey(key string) CallOption {
+	return func(cfg *callConfig) {
+		cfg.idempotencyKey = key
+	}
+}
+
+// callConfig holds settings applying to a single logical API call, across all of its attempts
+type callConfig struct {
+	// Context of the call
+	ctx context.Context
+
+	// Deadline for the call including retries; zero means no deadline
+	deadline time.Time
+
+	// Whether the deadline was set for this call and replaces the client timeout
+	override bool
+
+	// Optional destination of the response headers
+	header *http.Header
+
+	// Idempotency key of the call, if set by the caller
+	idempotencyKey string
+
+	// Whether a capture or refund may exceed the amount ceiling
+	overrideCeiling bool
+
+	// Additional headers sent with the request
+	requestHeader http.Header
+
+	// Priority of the call for load shedding
+	priority Priority
+
+	// Merchant-Serial-Number the call is made for, if not the client's
+	msn string
+
+	// Why the call was made, recorded in the audit trail
+	reason string
+
+	// Language of user-facing pages and messages of the call
+	locale models.Locale
+}
+
+// WithHeader adds a header to the request of a call, e.g. one required by a newer API
+// version. It overrides headers the client sets itself, such as Content-Type.
+func WithHeader(name, value string) CallOption {
+	return func(cfg *callConfig) {
+		if cfg.requestHeader == nil {
+			cfg.requestHeader = make(http.Header)
+		}
+		cfg.requestHeader.Add(name, value)
+	}
+}
+
+// WithReason records why a call is made in its audit record, see SetAuditSink. It is
+// required for test-only endpoints such as ForceApprove.
+func WithReason(reason string) CallOption {
+	return func(cfg *callConfig) {
+		cfg.reason = reason
+	}
+}
+
+// ForMSN makes a call on behalf of another merchant, sending its Merchant-Serial-Number
+// instead of the client's. Platforms and partners holding partner keys can serve many
+// merchants with one client, e.g. p.Create(req, client.ForMSN("123456")).
+func ForMSN(msn string) CallOption {
+	return func(cfg *callConfig) {
+		if cfg.requestHeader == nil {
+			cfg.requestHeader = make(http.Header)
+		}
+		cfg.requestHeader.Set("Merchant-Serial-Number", msn)
+		cfg.msn = msn
+	}
+}
+
+// merchant returns the Merchant-Serial-Number the call is made for
+func (cfg callConfig) merchant(c *Client) string {
+	if cfg.msn != "" {
+		return cfg.msn
+	}
+	return c.lastCredentials().MSN
+}
+
+// operationKey returns the idempotency key of an operation: the key set by the caller, or a
+// new key that is sent with every attempt of the operation
+func (cfg callConfig) operationKey() string {
+	if cfg.idempotencyKey != "" {
+		return cfg.idempotencyKey
+	}
+	return uuid.New().String()
+}
+
+// newCallConfig applies call options on top of a base configuration
+func newCallConfig(base callConfig, opts []CallOption) callConfig {
+	cfg := base
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	if cfg.ctx == nil {
+		cfg.ctx = context.Background()
+	}
+
+	// A deadline on the context bounds the call like any other deadline
+	if deadline, ok := cfg.ctx.Deadline(); ok && (cfg.deadline.IsZero() || deadline.Before(cfg.deadline)) {
+		cfg.deadline = deadline
+	}
+
+	return cfg
+}
+
+// httpClient returns the HTTP client to use for the call. A call with its own deadline
+// does not use the client timeout, which would otherwise cap a longer per-call timeout.
+func (cfg callConfig) httpClient(c *Client) *http.Client {
+	if !cfg.override || c.client.Timeout == 0 {
+		return c.client
+	}
+
+	hc := *c.client
+	hc.Timeout = 0
+	return &hc
+}
+
+// attemptContext returns the context for an attempt. With a deadline, the remaining time
+// is divided evenly over the remaining attempts, so retries never exceed the deadline.
+func (cfg callConfig) attemptContext(attemptsLeft int) (context.Context, context.CancelFunc) {
+	if cfg.deadline.IsZero() {
+		return context.WithCancel(cfg.ctx)
+	}
+
+	remaining := time.Until(cfg.deadline)
+	if attemptsLeft > 1 {
+		remaining /= time.Duration(attemptsLeft)
+	}
+
+	return context.WithTimeout(cfg.ctx, remaining)
+}
+
+// deadlineContext returns the context of the call, canceled at the deadline of the call
+func (cfg callConfig) deadlineContext() (context.Context, context.CancelFunc) {
+	if cfg.deadline.IsZero() {
+		return context.WithCancel(cfg.ctx)
+	}
+	return context.WithDeadline(cfg.ctx, cfg.deadline)
+}
+
+// wait waits before the next attempt, returning early with an error if the call is canceled
+func (cfg callConfig) wait(delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-cfg.ctx.Done():
+		return cfg.ctx.Err()
+	}
+}
+
+// canceled returns the error of the call's context if the caller gave up on the call
+func (cfg callConfig) canceled(err error) error {
+	if ctxErr := cfg.ctx.Err(); ctxErr != nil {
+		return errors.Join(ctxErr, err)
+	}
+	return nil
+}
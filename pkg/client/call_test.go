@@ -0,0 +1,50 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/client"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/vippstest"
+)
+
+// slowTransport delays requests for existing payments, as a slow API would
+type slowTransport struct{ delay time.Duration }
+
+func (t slowTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if strings.HasPrefix(r.URL.Path, "/epayment/v1/payments/") {
+		select {
+		case <-time.After(t.delay):
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+	}
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestDeadlineBudgetCoversStateCheck(t *testing.T) {
+	s := vippstest.NewServer()
+	defer s.Close()
+
+	const reference = "order-1007-a"
+	if _, err := client.NewPayment(s.Client()).Create(newCreateRequest(reference, "1007")); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if err := s.Approve(reference); err != nil {
+		t.Fatalf("Approve failed: %v", err)
+	}
+
+	// The state check and the capture each take 150ms, more than the budget together
+	c := s.Client(client.WithHTTPClient(&http.Client{Transport: slowTransport{delay: 150 * time.Millisecond}}))
+	payment := client.NewPayment(c, client.WithStateCheck(), client.WithDeadlineBudget(250*time.Millisecond))
+
+	req := models.ModificationRequest{ModificationAmount: models.Amount{Currency: "NOK", Value: 1000}}
+	if _, err := payment.Capture(reference, req); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Capture returned %v, want a deadline error", err)
+	}
+}
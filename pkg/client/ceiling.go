@@ -0,0 +1,101 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// AmountCeiling limits the amounts a Payment handler captures and refunds, as a safety net
+// against mistyped amounts, e.g. in admin tools. Operations above a limit fail with an
+// error matching ErrAmountCeiling unless they are made with WithCeilingOverride.
+type AmountCeiling struct {
+	// Maximum amount of a single capture or refund, in minor units; zero means no limit
+	PerPayment int
+	// Maximum total captured and refunded per currency and calendar day (UTC), in minor
+	// units; zero means no limit. Totals are tracked in memory by the Payment handler.
+	PerDay int
+}
+
+// WithAmountCeiling sets the ceiling for capture and refund amounts
+func WithAmountCeiling(ceiling AmountCeiling) PaymentOption {
+	return func(p *Payment) {
+		p.ceiling = &ceilingTracker{ceiling: ceiling, totals: make(map[string]int)}
+	}
+}
+
+// WithCeilingOverride allows a capture or refund above the amount ceiling. The amount still
+// counts towards the daily total.
+func WithCeilingOverride() CallOption {
+	return func(cfg *callConfig) {
+		cfg.overrideCeiling = true
+	}
+}
+
+// ceilingTracker enforces an amount ceiling and tracks the daily totals
+type ceilingTracker struct {
+	ceiling AmountCeiling
+
+	mu     sync.Mutex
+	day    string
+	totals map[string]int
+}
+
+// reserve checks an operation against the ceiling and adds its amount to the daily total.
+// The amount is released again if the operation fails.
+func (t *ceilingTracker) reserve(now time.Time, operation models.PaymentOperation, reference models.Reference, amount models.Amount, override bool) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if day := now.UTC().Format(time.DateOnly); day != t.day {
+		t.day = day
+		t.totals = make(map[string]int)
+	}
+
+	total := t.totals[amount.Currency] + amount.Value
+	if !override {
+		if t.ceiling.PerPayment > 0 && amount.Value > t.ceiling.PerPayment {
+			return &AmountCeilingError{Operation: operation, Reference: reference, Amount: amount,
+				Limit: t.ceiling.PerPayment, Daily: false}
+		}
+		if t.ceiling.PerDay > 0 && total > t.ceiling.PerDay {
+			return &AmountCeilingError{Operation: operation, Reference: reference, Amount: amount,
+				Limit: t.ceiling.PerDay, Daily: true}
+		}
+	}
+
+	t.totals[amount.Currency] = total
+	return nil
+}
+
+// release removes the amount of a failed operation from the daily total
+func (t *ceilingTracker) release(amount models.Amount) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.totals[amount.Currency] >= amount.Value {
+		t.totals[amount.Currency] -= amount.Value
+	}
+}
+
+// reserveCeiling checks a capture or refund against the amount ceiling, if configured. The
+// returned function must be called with the error if the operation fails; the amount is only
+// released if the API rejected the operation, since after other failures it may have succeeded.
+func (p *Payment) reserveCeiling(operation models.PaymentOperation, reference models.Reference, amount models.Amount, cfg callConfig) (func(error), error) {
+	if p.ceiling == nil {
+		return func(error) {}, nil
+	}
+
+	if err := p.ceiling.reserve(p.client.now(), operation, reference, amount, cfg.overrideCeiling); err != nil {
+		return nil, err
+	}
+	return func(err error) {
+		var apiErr *APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode < http.StatusInternalServerError {
+			p.ceiling.release(amount)
+		}
+	}, nil
+}
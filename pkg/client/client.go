@@ -2,13 +2,18 @@
 package client
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
-	"strconv"
+	"sync"
 	"time"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/internal/deprecation"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/redact"
+	"golang.org/x/sync/singleflight"
 )
 
 const (
@@ -25,6 +30,8 @@ const (
 type Client struct {
 	// HTTP client used for requests
 	client *http.Client
+	// Copy of the HTTP client's transport owned by this client, see transport
+	ownTransport *http.Transport
 
 	// Base URL for API requests
 	BaseURL string
@@ -35,7 +42,8 @@ type Client struct {
 	SubKey       string // Ocp-Apim-Subscription-Key
 	MSN          string // Merchant-Serial-Number
 
-	// Access token for API requests
+	// Access token for API requests. Once the client is in use, the token is refreshed
+	// concurrently with requests, so use Token and IsTokenValid instead of reading the fields.
 	AccessToken string
 	TokenExpiry time.Time
 
@@ -47,30 +55,147 @@ type Client struct {
 
 	// Whether this client is running in test mode
 	TestMode bool
+
+	// Optional hook receiving the latency of every API call
+	latencyObserver LatencyObserver
+
+	// Retry policies per request class
+	retryPolicies map[RequestClass]RetryPolicy
+
+	// Whether only read-only requests are allowed
+	readOnly bool
+
+	// Handling of rate-limited requests
+	throttlePolicy ThrottlePolicy
+	onThrottle     func(ThrottleEvent)
+
+	// Optional callback receiving every new access token
+	onTokenRefresh func(TokenRefresh)
+
+	// Whether only tokens set with SetAccessToken are used
+	noTokenFetching bool
+
+	// Clock for token expiry and timestamps; nil means the system clock
+	clock Clock
+
+	// Whether the client was hardened by NewStrict
+	strict bool
+
+	// Errors of options that could not be applied, returned by every request
+	optionErr error
+
+	// Logger of diagnostics, see SetLogger
+	logger Logger
+
+	// Subscription key used when the primary one is rejected, the rejected primary key, and
+	// the callback notified of the switch
+	subKeyMu         sync.Mutex
+	secondarySubKey  string
+	rejectedSubKey   string
+	onSubKeyRotation func(SubscriptionKeyRotation)
+
+	// Optional circuit breaker failing fast while the API is unavailable
+	breaker *circuitBreaker
+
+	// Minimum size of request bodies that are gzip compressed; zero disables compression
+	compressMinBytes int
+
+	// Optional receiver of requests rendered instead of being sent
+	dryRun func(*http.Request)
+
+	// Optional audit trail of all API calls
+	auditSink AuditSink
+	auditMu   sync.Mutex
+	auditHash string
+
+	// Optional shedding of low-priority calls while the API is under pressure
+	shedder *loadShedder
+
+	// Codec for request and response bodies
+	codec Codec
+
+	// Optional detector of response fields unknown to the models
+	drift *driftDetector
+
+	// How long before expiry the access token is renewed
+	refreshMargin time.Duration
+
+	// Path of the OAuth token endpoint; empty means /accesstoken/get
+	oauthTokenPath string
+
+	// Optional provider resolving the credentials at request time, and the last resolved ones
+	credentialProvider CredentialProvider
+	credMu             sync.Mutex
+	resolved           Credentials
+
+	// Optional store sharing access tokens with other instances
+	tokenStore TokenStore
+
+	// Scopes requested with access tokens, and those granted with the current one, if known
+	scopes        []string
+	grantedScopes []string
+	scopesKnown   bool
+
+	// Guards AccessToken, TokenExpiry and the scopes; concurrent refreshes share a single token request
+	tokenMu    sync.RWMutex
+	tokenGroup singleflight.Group
 }
 
-// NewClient creates a new API client for Vipps MobilePay
-func NewClient(clientID, clientSecret, subKey, msn string, testMode bool) *Client {
-	baseURL := ProductionBaseURL
-	if testMode {
-		baseURL = TestBaseURL
-	}
+// LatencyObserver receives the endpoint, method, HTTP status and duration of an API call.
+// The status is 0 when no response was received.
+type LatencyObserver func(endpoint string, method string, status int, d time.Duration)
 
-	return &Client{
+// New creates a new API client for Vipps MobilePay. The client targets the production
+// environment unless configured otherwise with options.
+func New(credentials Credentials, opts ...Option) *Client {
+	c := &Client{
 		client: &http.Client{
 			Timeout: defaultTimeout,
 		},
-		BaseURL:      baseURL,
-		ClientID:     clientID,
-		ClientSecret: clientSecret,
-		SubKey:       subKey,
-		MSN:          msn,
-		TestMode:     testMode,
+		ClientID:     credentials.ClientID,
+		ClientSecret: credentials.ClientSecret,
+		SubKey:       credentials.SubscriptionKey,
+		MSN:          credentials.MSN,
 
 		// Default system information
 		SystemName:    "go-vipps-mobilepay-sdk",
 		SystemVersion: "1.0.0",
+
+		retryPolicies: map[RequestClass]RetryPolicy{
+			RequestClassAuth:         NoRetry,
+			RequestClassQuery:        NoRetry,
+			RequestClassModification: NoRetry,
+		},
+		throttlePolicy: DefaultThrottlePolicy(),
+		codec:          JSONCodec{},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.BaseURL == "" {
+		c.BaseURL = ProductionBaseURL
+		if c.TestMode {
+			c.BaseURL = TestBaseURL
+		}
 	}
+
+	return c
+}
+
+// NewClient creates a new API client for Vipps MobilePay
+//
+// Deprecated: Use New, which accepts options for additional configuration.
+func NewClient(clientID, clientSecret, subKey, msn string, testMode bool) *Client {
+	deprecation.Warn("client.NewClient", "client.New")
+
+	return New(Credentials{
+		ClientID:        clientID,
+		ClientSecret:    clientSecret,
+		SubscriptionKey: subKey,
+		MSN:             msn,
+	}, WithTestMode(testMode))
 }
 
 // SetSystemInfo sets the system information for HTTP headers
@@ -94,98 +219,443 @@ func (c *Client) SetTimeout(timeout time.Duration) {
 	c.client.Timeout = timeout
 }
 
+// SetReadOnly enables or disables read-only mode. In read-only mode only GET requests are
+// sent; any other request, such as Create, Capture or Refund, fails with a *ReadOnlyError.
+// This guarantees no money moves, e.g. from a recovered replica during a disaster recovery drill.
+func (c *Client) SetReadOnly(readOnly bool) {
+	c.readOnly = readOnly
+}
+
+// IsReadOnly reports whether the client is in read-only mode
+func (c *Client) IsReadOnly() bool {
+	return c.readOnly
+}
+
+// ObserveLatency registers a hook that is called after every API call with its latency
+func (c *Client) ObserveLatency(observer LatencyObserver) {
+	c.latencyObserver = observer
+}
+
+// observe reports the latency of a call to the registered observer, if any
+func (c *Client) observe(endpoint, method string, status int, start time.Time) {
+	if c.latencyObserver != nil {
+		c.latencyObserver(endpoint, method, status, time.Since(start))
+	}
+}
+
 // IsTokenValid checks if the current access token is still valid
 func (c *Client) IsTokenValid() bool {
-	return c.AccessToken != "" && time.Now().Before(c.TokenExpiry)
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+
+	return c.AccessToken != "" && c.now().Add(c.refreshMargin).Before(c.TokenExpiry)
+}
+
+// token returns the current access token
+func (c *Client) token() string {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+
+	return c.AccessToken
+}
+
+// invalidateToken discards the access token if it is still the one given, so the next request
+// fetches a new one. A token that was replaced concurrently is kept.
+func (c *Client) invalidateToken(token string) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.AccessToken == token {
+		c.AccessToken = ""
+		c.TokenExpiry = time.Time{}
+	}
 }
 
-// GetAccessToken fetches a new access token from the Vipps MobilePay API
+// GetAccessToken fetches a new access token from the Vipps MobilePay API.
+// Failed attempts are retried according to the authentication retry policy. Concurrent
+// calls share a single refresh, so only one token request is in flight at a time.
 func (c *Client) GetAccessToken() error {
-	endpoint := "/accesstoken/get"
-	url := c.BaseURL + endpoint
+	if c.optionErr != nil {
+		return c.optionErr
+	}
+	if c.noTokenFetching {
+		return ErrNoAccessToken
+	}
 
-	req, err := http.NewRequest("POST", url, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
+	_, err, _ := c.tokenGroup.Do("token", func() (interface{}, error) {
+		return nil, c.refreshAccessToken()
+	})
+	return err
+}
+
+// refreshAccessToken fetches a new access token, retrying failed attempts
+func (c *Client) refreshAccessToken() error {
+	policy := c.retryPolicies[RequestClassAuth]
+
+	keyRotated := false
+	for attempt := 1; ; attempt++ {
+		if _, err := c.breakerAllow(); err != nil {
+			return err
+		}
+
+		secondary := c.UsingSecondarySubscriptionKey()
+		status, err := c.fetchAccessToken()
+		c.breakerRecord(status, err)
+		// A rejected primary subscription key is replaced with the secondary one
+		if err != nil && !keyRotated && !secondary && c.rotateSubscriptionKey(err) {
+			keyRotated = true
+			attempt--
+			continue
+		}
+		if err == nil || attempt >= policy.MaxAttempts || !shouldRetry(status, err) {
+			return err
+		}
+		time.Sleep(policy.delay(attempt))
 	}
+}
 
-	// Set headers for token request
-	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("client_id", c.ClientID)
-	req.Header.Set("client_secret", c.ClientSecret)
-	req.Header.Set("Ocp-Apim-Subscription-Key", c.SubKey)
-	req.Header.Set("Merchant-Serial-Number", c.MSN)
+// redactCredentials redacts the credentials of the client and any tokens from a token
+// response body, so errors carrying the body can be logged safely
+func (c *Client) redactCredentials(body []byte) []byte {
+	creds := c.lastCredentials()
+	return []byte(redact.String(string(body), creds.ClientSecret, creds.SubscriptionKey, c.subscriptionKey(creds)))
+}
+
+// fetchAccessToken performs a single token request and returns the HTTP status code received
+func (c *Client) fetchAccessToken() (status int, err error) {
+	creds, err := c.credentials(context.Background())
+	if err != nil {
+		return 0, err
+	}
+	req, endpoint, err := c.newTokenRequest(creds)
+	if err != nil {
+		return 0, err
+	}
 
+	start := time.Now()
 	resp, err := c.client.Do(req)
 	if err != nil {
-		return fmt.Errorf("failed to send request: %w", err)
+		c.observe(endpoint, req.Method, 0, start)
+		err = fmt.Errorf("failed to send request: %w", err)
+		if c.auditSink != nil {
+			c.audit(callConfig{}, req, nil, &response{}, start, err)
+		}
+		return 0, err
 	}
 	defer resp.Body.Close()
+	c.observe(endpoint, req.Method, resp.StatusCode, start)
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("failed to get access token: status %d, body: %s", resp.StatusCode, string(body))
+	body, err := io.ReadAll(resp.Body)
+	if c.auditSink != nil {
+		defer func() {
+			c.audit(callConfig{}, req, nil, &response{statusCode: resp.StatusCode, header: resp.Header, body: body}, start, err)
+		}()
+	}
+	if err != nil {
+		return resp.StatusCode, &ReadError{StatusCode: resp.StatusCode, Header: resp.Header, Body: c.redactCredentials(body), Err: err}
 	}
 
-	var tokenResp struct {
-		AccessToken string `json:"access_token"`
-		ExpiresIn   string `json:"expires_in"`
-		TokenType   string `json:"token_type"`
+	if resp.StatusCode != http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("failed to get access token: %w",
+			newAPIError(req.Method, endpoint, resp.StatusCode, resp.Header, c.redactCredentials(body)))
 	}
 
-	err = json.NewDecoder(resp.Body).Decode(&tokenResp)
+	accessToken, expiresIn, scope, err := c.decodeToken(body)
 	if err != nil {
-		return fmt.Errorf("failed to decode response: %w", err)
+		var decodeErr *DecodeError
+		if errors.As(err, &decodeErr) {
+			decodeErr.StatusCode, decodeErr.Header = resp.StatusCode, resp.Header
+		}
+		return resp.StatusCode, err
 	}
 
-	c.AccessToken = tokenResp.AccessToken
+	expiry := c.now().Add(time.Duration(expiresIn) * time.Second)
+	c.tokenMu.Lock()
+	c.AccessToken = accessToken
+	c.TokenExpiry = expiry
+	c.grantedScopes, c.scopesKnown = c.parseScopes(scope)
+	c.tokenMu.Unlock()
+
+	if c.tokenStore != nil {
+		c.storeToken(creds, accessToken, expiry)
+	}
+	c.tokenRefreshed(accessToken, expiry, TokenFetched)
+
+	return resp.StatusCode, nil
+}
+
+// newTokenRequest builds the request for an access token, to the OAuth token endpoint if
+// one is set and to /accesstoken/get otherwise
+func (c *Client) newTokenRequest(creds Credentials) (*http.Request, string, error) {
+	if c.oauthTokenPath != "" {
+		return c.newOAuthTokenRequest(creds)
+	}
 
-	// Convert expires_in from string to int
-	expiresIn, err := strconv.Atoi(tokenResp.ExpiresIn)
+	endpoint := "/accesstoken/get"
+	req, err := http.NewRequest("POST", c.BaseURL+endpoint, nil)
 	if err != nil {
-		return fmt.Errorf("failed to convert expires_in to int: %w", err)
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
 	}
 
-	c.TokenExpiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	// Set headers for token request
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("client_id", creds.ClientID)
+	req.Header.Set("client_secret", creds.ClientSecret)
+	req.Header.Set("Ocp-Apim-Subscription-Key", c.subscriptionKey(creds))
+	req.Header.Set("Merchant-Serial-Number", creds.MSN)
 
-	return nil
+	return req, endpoint, nil
 }
 
-// EnsureValidToken makes sure a valid access token is available
+// decodeToken decodes a token response into the access token, its lifetime in seconds and
+// the granted scopes, if listed. /accesstoken/get sends expires_in as a string and the OAuth
+// token endpoint as a number; both are accepted from either endpoint.
+func (c *Client) decodeToken(body []byte) (string, int, string, error) {
+	var tokenResp struct {
+		AccessToken string      `json:"access_token"`
+		ExpiresIn   json.Number `json:"expires_in"`
+		TokenType   string      `json:"token_type"`
+		Scope       string      `json:"scope"`
+	}
+	if err := c.codec.Unmarshal(body, &tokenResp); err != nil {
+		return "", 0, "", &DecodeError{Body: c.redactCredentials(body), Err: err}
+	}
+	if tokenResp.AccessToken == "" {
+		return "", 0, "", &DecodeError{Body: c.redactCredentials(body), Err: fmt.Errorf("response has no access_token")}
+	}
+
+	expiresIn, err := tokenResp.ExpiresIn.Float64()
+	if err != nil {
+		return "", 0, "", fmt.Errorf("failed to convert expires_in to int: %w", err)
+	}
+
+	return tokenResp.AccessToken, int(expiresIn), tokenResp.Scope, nil
+}
+
+// EnsureValidToken makes sure a valid access token is available, taking it from the token
+// store if one is set and holds a valid token
 func (c *Client) EnsureValidToken() error {
-	if !c.IsTokenValid() {
-		return c.GetAccessToken()
+	if c.IsTokenValid() {
+		return nil
 	}
-	return nil
+	if c.tokenStore != nil && c.loadStoredToken() {
+		return nil
+	}
+	return c.GetAccessToken()
+}
+
+// DoRequest performs an HTTP request with the appropriate headers and error handling.
+// The body is marshaled to JSON, unless it is a []byte or json.RawMessage holding JSON
+// already, or an io.Reader that is streamed as the request body.
+// Failed requests are retried according to the retry policy of their request class:
+// GET requests and requests carrying an idempotency key are retried on network errors
+// and 5xx responses, other requests are never retried. A request rejected with 401, e.g.
+// because the token was revoked, is sent once more with a new token before the error is
+// returned. Call options such as WithContext and WithRequestTimeout apply to this request only.
+func (c *Client) DoRequest(method, endpoint string, body interface{}, idempotencyKey string, opts ...CallOption) ([]byte, int, error) {
+	resp, err := c.do(newCallConfig(callConfig{}, opts), method, endpoint, body, idempotencyKey)
+	return resp.body, resp.statusCode, err
 }
 
-// DoRequest performs an HTTP request with the appropriate headers and error handling
-func (c *Client) DoRequest(method, endpoint string, body interface{}, idempotencyKey string) ([]byte, int, error) {
+// response is the raw response to an API request. The status code is 0 and the header nil
+// when no response was received.
+type response struct {
+	body       []byte
+	statusCode int
+	header     http.Header
+}
+
+// do performs an API request according to the call configuration. The returned response
+// is never nil, so the body and status code can be inspected on errors.
+func (c *Client) do(cfg callConfig, method, endpoint string, body interface{}, idempotencyKey string) (*response, error) {
+	if c.optionErr != nil {
+		return &response{}, c.optionErr
+	}
+	if c.readOnly && method != http.MethodGet && method != http.MethodHead {
+		return &response{}, &ReadOnlyError{Method: method, Endpoint: endpoint}
+	}
+
+	reqBody, err := newRequestBody(body, c.codec)
+	if err != nil {
+		return &response{}, err
+	}
+	if err := reqBody.compress(c.compressMinBytes); err != nil {
+		return &response{}, err
+	}
+
+	if c.dryRun != nil {
+		return c.renderDryRun(cfg, method, endpoint, reqBody, idempotencyKey)
+	}
+
 	if err := c.EnsureValidToken(); err != nil {
-		return nil, 0, err
+		return &response{}, err
 	}
 
-	url := c.BaseURL + endpoint
-	var reqBody io.Reader
+	class, retryable := classifyRequest(method, idempotencyKey)
+	policy := c.retryPolicies[class]
+	if !retryable || policy.MaxAttempts < 1 {
+		policy = NoRetry
+	}
+
+	// A streamed body that cannot be rewound is only sent once
+	if !reqBody.rewindable() {
+		retryable = false
+		policy = NoRetry
+	}
+
+	if err := c.shed(cfg, method, endpoint); err != nil {
+		return &response{}, err
+	}
 
-	if body != nil {
-		jsonBody, err := json.Marshal(body)
+	attempt, throttled, reauthorized, keyRotated := 1, 0, false, false
+	for {
+		trial, err := c.breakerAllow()
 		if err != nil {
-			return nil, 0, fmt.Errorf("failed to marshal request body: %w", err)
+			return &response{}, err
+		}
+
+		token := c.token()
+		secondary := c.UsingSecondarySubscriptionKey()
+		ctx, cancel := cfg.attemptContext(policy.MaxAttempts - attempt + 1)
+		resp, err := c.send(ctx, cfg, method, endpoint, reqBody, idempotencyKey)
+		cancel()
+		cfg.captureHeader(resp.header)
+
+		// A call the caller gave up on says nothing about the health of the API, but a
+		// canceled trial request must not keep the circuit half-open
+		if cancelErr := cfg.canceled(err); cancelErr != nil {
+			c.breakerAbandon(trial)
+			return resp, cancelErr
+		}
+		c.breakerRecord(resp.statusCode, err)
+		if err == nil {
+			return resp, nil
+		}
+
+		// A primary subscription key rejected during key rotation is replaced with the
+		// secondary one and the request sent once more
+		if !keyRotated && !secondary && reqBody.rewindable() && c.rotateSubscriptionKey(err) {
+			keyRotated = true
+			continue
 		}
-		reqBody = bytes.NewReader(jsonBody)
+
+		// A revoked or prematurely expired token is replaced and the request sent once more.
+		// The API rejected the request, so resending it cannot execute it twice.
+		if resp.statusCode == http.StatusUnauthorized && !reauthorized && reqBody.rewindable() {
+			reauthorized = true
+			c.invalidateToken(token)
+			// Unless another call replaced the token already, fetch a new one rather than
+			// reuse a stored token, which is likely the rejected one
+			if !c.IsTokenValid() {
+				if tokenErr := c.GetAccessToken(); tokenErr != nil {
+					return resp, errors.Join(err, tokenErr)
+				}
+			}
+			continue
+		}
+
+		wait, throttle := c.throttleWait(err, retryable, throttled)
+		if c.shedThrottled(cfg, resp, wait) {
+			return resp, err
+		}
+
+		var delay time.Duration
+		if throttle && reqBody.rewindable() {
+			// Waiting out a rate limit does not count as a retry attempt
+			throttled++
+			delay = wait
+			if c.onThrottle != nil {
+				c.onThrottle(ThrottleEvent{
+					Method:     method,
+					Endpoint:   endpoint,
+					StatusCode: resp.statusCode,
+					RetryAfter: wait,
+					Retry:      throttled,
+				})
+			}
+		} else if attempt < policy.MaxAttempts && shouldRetry(resp.statusCode, err) {
+			delay = policy.delay(attempt)
+			attempt++
+		} else {
+			return resp, err
+		}
+
+		// Do not start another attempt that cannot finish within the deadline
+		if !cfg.deadline.IsZero() && !time.Now().Add(delay).Before(cfg.deadline) {
+			return resp, err
+		}
+		if shedErr := c.shed(cfg, method, endpoint); shedErr != nil {
+			return resp, errors.Join(err, shedErr)
+		}
+		if waitErr := cfg.wait(delay); waitErr != nil {
+			return resp, errors.Join(waitErr, err)
+		}
+	}
+}
+
+// send performs a single attempt of an API request. The returned response is never nil.
+func (c *Client) send(ctx context.Context, cfg callConfig, method, endpoint string, reqBody *requestBody, idempotencyKey string) (resp *response, err error) {
+	req, err := c.newRequest(ctx, method, endpoint, reqBody, idempotencyKey, cfg.requestHeader)
+	if err != nil {
+		return &response{}, err
+	}
+
+	start := time.Now()
+	if c.auditSink != nil {
+		defer func() { c.audit(cfg, req, reqBody.plain, resp, start, err) }()
+	}
+
+	httpResp, err := cfg.httpClient(c).Do(req)
+	if err != nil {
+		c.observe(endpoint, method, 0, start)
+		return &response{}, fmt.Errorf("failed to send request: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	resp = &response{statusCode: httpResp.StatusCode, header: httpResp.Header}
+	resp.body, err = readResponseBody(httpResp)
+	c.observe(endpoint, method, resp.statusCode, start)
+	if err != nil {
+		return resp, &ReadError{StatusCode: resp.statusCode, Header: resp.header, Body: resp.body, Err: err}
+	}
+
+	// Handle error responses
+	if resp.statusCode >= 400 {
+		return resp, newAPIError(method, endpoint, resp.statusCode, resp.header, resp.body)
+	}
+
+	return resp, nil
+}
+
+// newRequest builds the HTTP request for an attempt of an API request. Headers in extra are
+// added last, overriding the headers set by the client.
+func (c *Client) newRequest(ctx context.Context, method, endpoint string, reqBody *requestBody, idempotencyKey string, extra http.Header) (*http.Request, error) {
+	url := c.BaseURL + endpoint
+
+	creds, err := c.credentials(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	req, err := http.NewRequest(method, url, reqBody)
+	bodyReader, err := reqBody.reader()
 	if err != nil {
-		return nil, 0, fmt.Errorf("failed to create request: %w", err)
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, url, bodyReader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	// Set common headers
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+c.AccessToken)
-	req.Header.Set("Ocp-Apim-Subscription-Key", c.SubKey)
-	req.Header.Set("Merchant-Serial-Number", c.MSN)
+	req.Header.Set("Accept-Encoding", "gzip")
+	if reqBody.encoding != "" {
+		req.Header.Set("Content-Encoding", reqBody.encoding)
+	}
+	req.Header.Set("Authorization", "Bearer "+c.token())
+	req.Header.Set("Ocp-Apim-Subscription-Key", c.subscriptionKey(creds))
+	req.Header.Set("Merchant-Serial-Number", creds.MSN)
 
 	// Set system information headers
 	req.Header.Set("Vipps-System-Name", c.SystemName)
@@ -202,34 +672,9 @@ func (c *Client) DoRequest(method, endpoint string, body interface{}, idempotenc
 		req.Header.Set("Idempotency-Key", idempotencyKey)
 	}
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, 0, fmt.Errorf("failed to send request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	respBody, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, resp.StatusCode, fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	// Handle error responses
-	if resp.StatusCode >= 400 {
-		var problemDetails struct {
-			Title  string `json:"title"`
-			Detail string `json:"detail"`
-			Status int    `json:"status"`
-			Code   string `json:"code"`
-		}
-
-		if err := json.Unmarshal(respBody, &problemDetails); err == nil {
-			return respBody, resp.StatusCode, fmt.Errorf("API error: %s - %s (Code: %s, Status: %d)",
-				problemDetails.Title, problemDetails.Detail, problemDetails.Code, problemDetails.Status)
-		}
-
-		return respBody, resp.StatusCode, fmt.Errorf("API error: status code %d, body: %s",
-			resp.StatusCode, string(respBody))
+	for name, values := range extra {
+		req.Header[http.CanonicalHeaderKey(name)] = values
 	}
 
-	return respBody, resp.StatusCode, nil
+	return req, nil
 }
@@ -0,0 +1,31 @@
+package client
+
+import "time"
+
+// Clock tells the current time. The client reads it to check the expiry of access tokens and
+// to timestamp audit records and commands, so tests can simulate token expiry and clock skew
+// without sleeping. Timeouts and retry delays always follow the real time.
+type Clock interface {
+	Now() time.Time
+}
+
+// ClockFunc adapts a function to the Clock interface
+type ClockFunc func() time.Time
+
+// Now calls f()
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// SetClock sets the clock of the client; nil restores the system clock
+func (c *Client) SetClock(clock Clock) {
+	c.clock = clock
+}
+
+// now returns the current time of the client's clock
+func (c *Client) now() time.Time {
+	if c.clock == nil {
+		return time.Now()
+	}
+	return c.clock.Now()
+}
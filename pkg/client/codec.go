@@ -0,0 +1,32 @@
+package client
+
+import "encoding/json"
+
+// Codec encodes request bodies and decodes response bodies. The default codec uses
+// encoding/json; drop-in replacements such as jsoniter or segmentio/encoding can be
+// adapted to reduce the CPU spent on JSON by high-volume integrations.
+type Codec interface {
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONCodec is the default Codec, using encoding/json
+type JSONCodec struct{}
+
+// Marshal encodes v with json.Marshal
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes data into v with json.Unmarshal
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// SetCodec sets the codec used for request and response bodies; nil restores JSONCodec
+func (c *Client) SetCodec(codec Codec) {
+	if codec == nil {
+		codec = JSONCodec{}
+	}
+	c.codec = codec
+}
@@ -0,0 +1,304 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// ErrCommandNotFound is returned for a command ID the command store does not know
+var ErrCommandNotFound = errors.New("command not found")
+
+// ErrCommandMismatch is returned when a command is submitted with the ID of a recorded command
+// for another operation, payment or amount
+var ErrCommandMismatch = errors.New("command ID is already used for another operation")
+
+// CommandID identifies a command. It is the idempotency key the operation is sent with, so
+// submitting a command again never applies it twice.
+type CommandID string
+
+// CommandStatus is the state of a command
+type CommandStatus string
+
+const (
+	// CommandPending means the command was submitted but its completion is not confirmed
+	CommandPending CommandStatus = "PENDING"
+	// CommandSucceeded means the operation was applied to the payment
+	CommandSucceeded CommandStatus = "SUCCEEDED"
+	// CommandFailed means the operation was rejected and will not be applied
+	CommandFailed CommandStatus = "FAILED"
+)
+
+// Command is a capture or refund submitted through Commands
+type Command struct {
+	ID          CommandID               `json:"id"`
+	Operation   models.PaymentOperation `json:"operation"`             // CAPTURE or REFUND
+	Reference   models.Reference        `json:"reference"`             // Reference of the payment
+	Amount      models.Amount           `json:"amount"`                // Amount of the operation
+	Status      CommandStatus           `json:"status"`                // Current state of the command
+	Error       string                  `json:"error,omitempty"`       // Last error, for failed and unconfirmed commands
+	SubmittedAt time.Time               `json:"submittedAt"`           // When the command was first submitted
+	CompletedAt time.Time               `json:"completedAt,omitempty"` // When the command succeeded or failed
+}
+
+// Done reports whether the command succeeded or failed
+func (c *Command) Done() bool {
+	return c.Status == CommandSucceeded || c.Status == CommandFailed
+}
+
+// CommandStore records commands, so their results can be looked up by another process, e.g.
+// a queue consumer confirming what an API handler submitted. Implementations must be safe
+// for concurrent use, and should be persistent so pending commands survive restarts.
+type CommandStore interface {
+	// Save stores a command, replacing the stored command with the same ID
+	Save(command *Command) error
+	// Load returns the command with the ID, or nil if there is none
+	Load(id CommandID) (*Command, error)
+}
+
+// MemoryCommandStore is an in-memory CommandStore, suitable for tests and single processes
+type MemoryCommandStore struct {
+	mu       sync.Mutex
+	commands map[CommandID]Command
+}
+
+// NewMemoryCommandStore creates an empty in-memory command store
+func NewMemoryCommandStore() *MemoryCommandStore {
+	return &MemoryCommandStore{commands: make(map[CommandID]Command)}
+}
+
+// Save stores a command
+func (s *MemoryCommandStore) Save(command *Command) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.commands[command.ID] = *command
+	return nil
+}
+
+// Load returns the command with the ID, or nil if there is none
+func (s *MemoryCommandStore) Load(id CommandID) (*Command, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	command, ok := s.commands[id]
+	if !ok {
+		return nil, nil
+	}
+	return &command, nil
+}
+
+// Commands submits captures and refunds as commands: they return a CommandID as soon as the
+// command is recorded, and are executed in the background. Completion is confirmed from the
+// response, from webhook events (see ObserveWebhook), or from the payment's event log when
+// the result is looked up with Status, so a command interrupted by a timeout or a restart is
+// still resolved. Pending commands can be submitted again with Resume; the idempotency key
+// guarantees they are applied once.
+type Commands struct {
+	payment *Payment
+	store   CommandStore
+}
+
+// NewCommands creates a command executor for the payment handler, recording commands in the store
+func NewCommands(payment *Payment, store CommandStore) *Commands {
+	return &Commands{payment: payment, store: store}
+}
+
+// Capture submits a capture command. Pass WithIdempotencyKey to choose the command ID, e.g.
+// one derived from a queue message, so a redelivered message submits the same command.
+func (c *Commands) Capture(ctx context.Context, reference models.Reference, req models.ModificationRequest, opts ...CallOption) (CommandID, error) {
+	return c.submit(ctx, models.OperationCapture, reference, req, opts)
+}
+
+// Refund submits a refund command, see Capture
+func (c *Commands) Refund(ctx context.Context, reference models.Reference, req models.ModificationRequest, opts ...CallOption) (CommandID, error) {
+	return c.submit(ctx, models.OperationRefund, reference, req, opts)
+}
+
+// submit records a command and executes it in the background. A command submitted again
+// with the same ID is not executed again, unless it is still pending; then the recorded
+// command is sent, so the idempotency key is never reused for a different request.
+func (c *Commands) submit(ctx context.Context, operation models.PaymentOperation, reference models.Reference, req models.ModificationRequest, opts []CallOption) (CommandID, error) {
+	id := CommandID(newCallConfig(callConfig{}, opts).idempotencyKey)
+	if id == "" {
+		id = CommandID(uuid.New().String())
+	}
+
+	command, err := c.store.Load(id)
+	if err != nil {
+		return "", fmt.Errorf("failed to load command %s: %w", id, err)
+	}
+	if command != nil && (command.Operation != operation || command.Reference != reference || command.Amount != req.ModificationAmount) {
+		return "", fmt.Errorf("%w: %s is a %s of %d %s on %s", ErrCommandMismatch, id,
+			command.Operation, command.Amount.Value, command.Amount.Currency, command.Reference)
+	}
+	if command != nil && command.Done() {
+		return id, nil
+	}
+	if command == nil {
+		command = &Command{
+			ID:          id,
+			Operation:   operation,
+			Reference:   reference,
+			Amount:      req.ModificationAmount,
+			Status:      CommandPending,
+			SubmittedAt: c.payment.client.now(),
+		}
+		// Nothing is sent unless the command is recorded, so every operation can be confirmed
+		if err := c.store.Save(command); err != nil {
+			return "", fmt.Errorf("failed to record command %s: %w", id, err)
+		}
+	}
+
+	// The command outlives the caller's context, e.g. that of an HTTP request
+	ctx = context.WithoutCancel(ctx)
+	opts = append(append([]CallOption(nil), opts...), WithContext(ctx), WithIdempotencyKey(string(id)))
+	go c.execute(*command, models.ModificationRequest{ModificationAmount: command.Amount}, opts)
+
+	return id, nil
+}
+
+// execute sends the operation of a command and records its result. Transient failures leave
+// the command pending, since the operation may have been applied.
+func (c *Commands) execute(command Command, req models.ModificationRequest, opts []CallOption) {
+	var err error
+	switch command.Operation {
+	case models.OperationCapture:
+		_, err = c.payment.Capture(command.Reference, req, opts...)
+	case models.OperationRefund:
+		_, err = c.payment.Refund(command.Reference, req, opts...)
+	default:
+		err = fmt.Errorf("unsupported command operation %s", command.Operation)
+	}
+
+	switch {
+	case err == nil:
+		c.complete(&command, CommandSucceeded, "")
+	case IsRetryable(err):
+		// A webhook event may have confirmed the command in the meantime
+		if stored, loadErr := c.store.Load(command.ID); loadErr == nil && stored != nil && stored.Done() {
+			return
+		}
+		command.Error = err.Error()
+		if err := c.store.Save(&command); err != nil {
+			c.payment.client.logf("Error recording command %s: %v", command.ID, err)
+		}
+	default:
+		c.complete(&command, CommandFailed, err.Error())
+	}
+}
+
+// complete records the final status of a command
+func (c *Commands) complete(command *Command, status CommandStatus, reason string) {
+	command.Status = status
+	command.Error = reason
+	command.CompletedAt = c.payment.client.now()
+
+	if err := c.store.Save(command); err != nil {
+		c.payment.client.logf("Error recording result of command %s: %v", command.ID, err)
+	}
+}
+
+// Status returns a command. A pending command is confirmed from the payment's event log,
+// which lists operations with the idempotency key they were sent with.
+func (c *Commands) Status(id CommandID, opts ...CallOption) (*Command, error) {
+	command, err := c.store.Load(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load command %s: %w", id, err)
+	}
+	if command == nil {
+		return nil, fmt.Errorf("%w: %s", ErrCommandNotFound, id)
+	}
+	if command.Done() {
+		return command, nil
+	}
+
+	events, err := c.payment.GetEvents(command.Reference, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to confirm command %s: %w", id, err)
+	}
+	for _, event := range events {
+		if event.IdempotencyKey != string(id) {
+			continue
+		}
+		if event.Success {
+			c.complete(command, CommandSucceeded, "")
+		} else {
+			c.complete(command, CommandFailed, fmt.Sprintf("%s event reported failure", event.Name))
+		}
+		break
+	}
+
+	return command, nil
+}
+
+// Wait looks up a command until it is done, checking as configured by the poll options. When
+// the timeout passes first, the pending command is returned with an error matching ErrPollTimeout.
+func (c *Commands) Wait(id CommandID, options PollOptions, opts ...CallOption) (*Command, error) {
+	var deadline time.Time
+	if options.Timeout > 0 {
+		deadline = time.Now().Add(options.Timeout)
+	}
+	cfg := newCallConfig(callConfig{}, opts)
+
+	delay := options.Interval
+	if delay <= 0 {
+		delay = PollCheckout.Interval
+	}
+
+	for {
+		command, err := c.Status(id, opts...)
+		if err != nil || command.Done() {
+			return command, err
+		}
+
+		if !deadline.IsZero() && !time.Now().Add(delay).Before(deadline) {
+			return command, fmt.Errorf("%w: command %s still pending after %s", ErrPollTimeout, id, options.Timeout)
+		}
+		if err := cfg.wait(delay); err != nil {
+			return command, err
+		}
+		delay = options.next(delay)
+	}
+}
+
+// Resume submits a pending command again, e.g. after a restart interrupted it. It is not
+// applied twice, since it is sent with the same idempotency key.
+func (c *Commands) Resume(ctx context.Context, id CommandID, opts ...CallOption) error {
+	command, err := c.Status(id, append(opts, WithContext(ctx))...)
+	if err != nil || command.Done() {
+		return err
+	}
+
+	req := models.ModificationRequest{ModificationAmount: command.Amount}
+	_, err = c.submit(ctx, command.Operation, command.Reference, req, append(opts, WithIdempotencyKey(string(id))))
+	return err
+}
+
+// ObserveWebhook completes the command a webhook event reports on, if any. Events carry the
+// idempotency key of the operation, which is the command ID.
+func (c *Commands) ObserveWebhook(event *models.WebhookEvent) error {
+	if event.IdempotencyKey == "" {
+		return nil
+	}
+
+	command, err := c.store.Load(CommandID(event.IdempotencyKey))
+	if err != nil {
+		return fmt.Errorf("failed to load command %s: %w", event.IdempotencyKey, err)
+	}
+	if command == nil || command.Done() {
+		return nil
+	}
+
+	if event.Success {
+		c.complete(command, CommandSucceeded, "")
+	} else {
+		c.complete(command, CommandFailed, fmt.Sprintf("%s event reported failure", event.Name))
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// SetRequestCompression gzip compresses request bodies of at least minBytes, sending them
+// with Content-Encoding: gzip. This saves bandwidth for large bodies such as receipts on
+// constrained links, but requires the API or gateway in front of it to accept compressed
+// bodies. Zero disables compression, which is the default. Responses are always requested
+// with Accept-Encoding: gzip and decompressed transparently.
+func (c *Client) SetRequestCompression(minBytes int) {
+	if minBytes < 0 {
+		minBytes = 0
+	}
+	c.compressMinBytes = minBytes
+}
+
+// compress gzip compresses a buffered body of at least minBytes. Streamed bodies are
+// sent as they are.
+func (b *requestBody) compress(minBytes int) error {
+	if minBytes <= 0 || b.data == nil || len(b.data) < minBytes {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(b.data); err != nil {
+		return fmt.Errorf("failed to compress request body: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to compress request body: %w", err)
+	}
+
+	b.data = buf.Bytes()
+	b.encoding = "gzip"
+	return nil
+}
+
+// readResponseBody reads a response body, decompressing it if it is gzip encoded
+func readResponseBody(resp *http.Response) ([]byte, error) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") {
+		return io.ReadAll(resp.Body)
+	}
+
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress response body: %w", err)
+	}
+	defer zr.Close()
+
+	body, err := io.ReadAll(zr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress response body: %w", err)
+	}
+
+	// The body is returned decompressed
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	return body, nil
+}
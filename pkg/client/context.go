@@ -0,0 +1,39 @@
+package client
+
+import "context"
+
+// contextKey is the type of the context keys defined by this package
+type contextKey int
+
+const (
+	requestIDKey contextKey = iota
+	tenantIDKey
+)
+
+// ContextWithRequestID returns a context carrying the caller's request ID. Pass the context
+// to a call with WithContext; it is the context of the outgoing HTTP request, so transport
+// middleware and loggers can read the ID with RequestIDFromContext to correlate SDK calls
+// with the caller's own request traces.
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey, requestID)
+}
+
+// RequestIDFromContext returns the request ID set with ContextWithRequestID, or an empty
+// string if there is none
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey).(string)
+	return requestID
+}
+
+// ContextWithTenantID returns a context carrying the tenant a call is made for, so
+// multi-tenant platforms can attribute SDK calls in middleware and loggers
+func ContextWithTenantID(ctx context.Context, tenantID string) context.Context {
+	return context.WithValue(ctx, tenantIDKey, tenantID)
+}
+
+// TenantIDFromContext returns the tenant ID set with ContextWithTenantID, or an empty
+// string if there is none
+func TenantIDFromContext(ctx context.Context) string {
+	tenantID, _ := ctx.Value(tenantIDKey).(string)
+	return tenantID
+}
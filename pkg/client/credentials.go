@@ -0,0 +1,83 @@
+package client
+
+import (
+	"context"
+	"fmt"
+)
+
+// CredentialProvider resolves the credentials of the client when they are needed, e.g. from
+// HashiCorp Vault, AWS Secrets Manager or Azure Key Vault, so rotated secrets are picked up
+// without a restart. It is called for every request and token request; implementations
+// backed by a remote secret store should cache the secrets and refresh them periodically.
+type CredentialProvider interface {
+	Credentials(ctx context.Context) (Credentials, error)
+}
+
+// CredentialProviderFunc adapts a function to the CredentialProvider interface
+type CredentialProviderFunc func(ctx context.Context) (Credentials, error)
+
+// Credentials calls f(ctx)
+func (f CredentialProviderFunc) Credentials(ctx context.Context) (Credentials, error) {
+	return f(ctx)
+}
+
+// SetCredentialProvider resolves the credentials with the provider instead of using the
+// static credentials of the client. Empty fields of the resolved credentials fall back to the
+// static ones, so a provider can supply only the secrets. A token fetched with a rotated-out
+// secret stays in use until it expires or is rejected, when a new one is fetched.
+func (c *Client) SetCredentialProvider(provider CredentialProvider) {
+	c.credentialProvider = provider
+}
+
+// credentials resolves the credentials to use for a request
+func (c *Client) credentials(ctx context.Context) (Credentials, error) {
+	static := Credentials{
+		ClientID:        c.ClientID,
+		ClientSecret:    c.ClientSecret,
+		SubscriptionKey: c.SubKey,
+		MSN:             c.MSN,
+	}
+	if c.credentialProvider == nil {
+		return static, nil
+	}
+
+	creds, err := c.credentialProvider.Credentials(ctx)
+	if err != nil {
+		return Credentials{}, fmt.Errorf("failed to resolve credentials: %w", err)
+	}
+	if creds.ClientID == "" {
+		creds.ClientID = static.ClientID
+	}
+	if creds.ClientSecret == "" {
+		creds.ClientSecret = static.ClientSecret
+	}
+	if creds.SubscriptionKey == "" {
+		creds.SubscriptionKey = static.SubscriptionKey
+	}
+	if creds.MSN == "" {
+		creds.MSN = static.MSN
+	}
+
+	c.credMu.Lock()
+	c.resolved = creds
+	c.credMu.Unlock()
+
+	return creds, nil
+}
+
+// lastCredentials returns the credentials last resolved, or the static credentials, for
+// redacting secrets and keying stored tokens without calling the provider
+func (c *Client) lastCredentials() Credentials {
+	c.credMu.Lock()
+	defer c.credMu.Unlock()
+
+	if c.resolved.ClientID != "" {
+		return c.resolved
+	}
+	return Credentials{
+		ClientID:        c.ClientID,
+		ClientSecret:    c.ClientSecret,
+		SubscriptionKey: c.SubKey,
+		MSN:             c.MSN,
+	}
+}
@@ -0,0 +1,77 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// currencyCacheCapacity bounds the number of payment currencies remembered by the currency check
+const currencyCacheCapacity = 10000
+
+// WithCurrencyCheck verifies that the currency of a capture or refund matches the currency
+// of the payment before the request is sent, failing with an error matching ErrCurrencyMismatch
+// otherwise. Currencies of payments created or fetched by this handler are cached; for other
+// payments the currency is fetched from the API first.
+func WithCurrencyCheck() PaymentOption {
+	return func(p *Payment) {
+		p.currencies = &currencyCache{currencies: make(map[models.Reference]string)}
+	}
+}
+
+// currencyCache remembers the currency of payments
+type currencyCache struct {
+	mu         sync.Mutex
+	currencies map[models.Reference]string
+	references []models.Reference
+}
+
+// get returns the cached currency of a payment
+func (c *currencyCache) get(reference models.Reference) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	currency, ok := c.currencies[reference]
+	return currency, ok
+}
+
+// set caches the currency of a payment
+func (c *currencyCache) set(reference models.Reference, currency string) {
+	if currency == "" {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if _, ok := c.currencies[reference]; !ok {
+		if len(c.references) >= currencyCacheCapacity {
+			delete(c.currencies, c.references[0])
+			c.references = c.references[1:]
+		}
+		c.references = append(c.references, reference)
+	}
+	c.currencies[reference] = currency
+}
+
+// checkCurrency verifies the currency of a capture or refund against the payment currency
+func (p *Payment) checkCurrency(cfg callConfig, reference models.Reference, currency string) error {
+	if p.currencies == nil {
+		return nil
+	}
+
+	expected, ok := p.currencies.get(reference)
+	if !ok {
+		// Get caches the currency of the payment
+		payment, err := p.get(cfg, reference)
+		if err != nil {
+			return err
+		}
+		expected = payment.Amount.Currency
+	}
+
+	if expected != "" && expected != currency {
+		return &CurrencyMismatchError{Reference: reference, Expected: expected, Actual: currency}
+	}
+	return nil
+}
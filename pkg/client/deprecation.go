@@ -0,0 +1,13 @@
+package client
+
+import "github.com/zenfulcode/vipps-mobilepay-sdk/internal/deprecation"
+
+// DeprecationNotice describes the use of a deprecated API of the SDK
+type DeprecationNotice = deprecation.Notice
+
+// SetDeprecationHandler sets the function receiving a notice the first time each deprecated
+// API of the SDK is used in the process, e.g. to route the warnings to your logger or fail
+// tests using them; nil silences the notices. By default, they are written to the standard logger.
+func SetDeprecationHandler(fn func(DeprecationNotice)) {
+	deprecation.SetHandler(fn)
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// ErrDirectCaptureUnavailable is returned by Create when direct capture is selected but the
+// sales unit is not configured for it
+var ErrDirectCaptureUnavailable = errors.New("sales unit is not configured for direct capture")
+
+// ErrDirectCapture is returned by Capture when direct capture is selected, since payments are
+// captured on authorization
+var ErrDirectCapture = errors.New("payments are captured on authorization with direct capture")
+
+// WithDirectCapture selects direct capture: payments are captured as soon as the user
+// authorizes them, without a Capture call. Direct capture is a setting of the sales unit,
+// enabled by Vipps MobilePay on request; the first Create checks the sales unit with the
+// Management API and fails with ErrDirectCaptureUnavailable if it captures separately, so
+// goods are not delivered for payments that are only reserved. Capture fails with
+// ErrDirectCapture, since there is nothing left to capture.
+func WithDirectCapture() PaymentOption {
+	return func(p *Payment) {
+		p.directCapture = &directCapture{verified: make(map[string]bool)}
+	}
+}
+
+// directCapture remembers the sales units verified to capture on authorization, by MSN
+type directCapture struct {
+	mu       sync.Mutex
+	verified map[string]bool
+}
+
+// verifyDirectCapture checks once per sales unit that it is configured for direct capture.
+// Failed lookups are retried with the next call.
+func (p *Payment) verifyDirectCapture(opts []CallOption) error {
+	p.directCapture.mu.Lock()
+	defer p.directCapture.mu.Unlock()
+
+	msn := newCallConfig(callConfig{}, opts).merchant(p.client)
+	if p.directCapture.verified[msn] {
+		return nil
+	}
+
+	unit, err := p.client.GetSalesUnit("", opts...)
+	if err != nil {
+		return fmt.Errorf("failed to verify direct capture: %w", err)
+	}
+	if unit.CaptureType != models.CaptureTypeDirect {
+		return fmt.Errorf("%w: sales unit %s uses %s", ErrDirectCaptureUnavailable, unit.MSN, unit.CaptureType)
+	}
+
+	p.directCapture.verified[msn] = true
+	return nil
+}
@@ -0,0 +1,69 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// DecodeError is returned when a successful response cannot be decoded into the expected type
+type DecodeError struct {
+	StatusCode int         // HTTP status code of the response
+	Header     http.Header // Response headers
+	Body       []byte      // Raw response body
+	Err        error       // Underlying decoding error
+}
+
+// Error implements the error interface
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to parse response (status %d)%s: %v", e.StatusCode, traceSuffix(e.Header), e.Err)
+}
+
+// TraceID returns the trace ID of the response, to quote in support tickets
+func (e *DecodeError) TraceID() string {
+	return TraceID(e.Header)
+}
+
+// errorResponse returns the response the error occurred on
+func (e *DecodeError) errorResponse() *ErrorResponse {
+	return newErrorResponse(e.StatusCode, e.Header, e.Body)
+}
+
+// Unwrap returns the underlying decoding error
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+// Do performs an API request and decodes the JSON response into a value of type T, e.g.
+//
+//	payment, err := client.Do[models.GetPaymentResponse](c, http.MethodGet, endpoint, nil)
+//
+// Requests are sent like DoRequest sends them. Error responses are returned as *APIError,
+// and responses that cannot be decoded as *DecodeError. An empty response body leaves T at
+// its zero value. Use WithIdempotencyKey to send an idempotency key.
+func Do[T any](c *Client, method, endpoint string, body interface{}, opts ...CallOption) (*T, error) {
+	cfg := newCallConfig(callConfig{}, opts)
+	result, _, err := doJSON[T](c, cfg, method, endpoint, body, cfg.idempotencyKey)
+	return result, err
+}
+
+// doJSON performs an API request according to the call configuration and decodes the JSON
+// response into a value of type T. The raw response is returned as well, also on errors.
+func doJSON[T any](c *Client, cfg callConfig, method, endpoint string, body interface{}, idempotencyKey string) (*T, *response, error) {
+	resp, err := c.do(cfg, method, endpoint, body, idempotencyKey)
+	if err != nil {
+		return nil, resp, err
+	}
+
+	var result T
+	if len(resp.body) > 0 {
+		if err := c.codec.Unmarshal(resp.body, &result); err != nil {
+			return nil, resp, &DecodeError{StatusCode: resp.statusCode, Header: resp.header, Body: resp.body, Err: err}
+		}
+		if c.drift != nil {
+			c.drift.check(method, endpoint, reflect.TypeOf(result), resp.body)
+		}
+	}
+
+	return &result, resp, nil
+}
@@ -0,0 +1,200 @@
+package client
+
+import (
+	"encoding/json"
+	"log"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSchemaDriftInterval is how often an unknown field is reported at most by default
+const DefaultSchemaDriftInterval = 24 * time.Hour
+
+// SchemaDrift reports fields of an API response that the models of the SDK do not know,
+// e.g. because Vipps MobilePay added a field the SDK does not support yet
+type SchemaDrift struct {
+	Method   string   // HTTP method of the request
+	Endpoint string   // Endpoint of the request
+	Type     string   // Go type the response was decoded into, e.g. "models.GetPaymentResponse"
+	Fields   []string // Paths of the unknown fields, e.g. "aggregate.tipAmount" or "[].newField"
+}
+
+// SchemaDriftHook receives the unknown fields of API responses
+type SchemaDriftHook func(SchemaDrift)
+
+// LogSchemaDrift is a SchemaDriftHook logging unknown fields with the standard logger
+func LogSchemaDrift(drift SchemaDrift) {
+	log.Printf("Unknown fields in %s response of %s %s: %s", drift.Type, drift.Method, drift.Endpoint,
+		strings.Join(drift.Fields, ", "))
+}
+
+// SetSchemaDriftHook enables schema drift detection: every decoded API response is compared
+// with the fields of the model it is decoded into, and unknown fields are passed to hook.
+// Each unknown field of a model is reported at most once per interval, zero meaning
+// DefaultSchemaDriftInterval. Detection decodes responses twice, so it costs some CPU.
+// A nil hook disables detection.
+func (c *Client) SetSchemaDriftHook(hook SchemaDriftHook, interval time.Duration) {
+	if hook == nil {
+		c.drift = nil
+		return
+	}
+	if interval <= 0 {
+		interval = DefaultSchemaDriftInterval
+	}
+
+	c.drift = &driftDetector{
+		hook:     hook,
+		interval: interval,
+		reported: make(map[string]time.Time),
+	}
+}
+
+// driftDetector finds unknown fields in responses and reports them, rate-limited per field
+type driftDetector struct {
+	hook     SchemaDriftHook
+	interval time.Duration
+
+	mu       sync.Mutex
+	reported map[string]time.Time // When a field of a type was last reported, by type and path
+}
+
+// check compares a response body with the fields of the type it was decoded into
+func (d *driftDetector) check(method, endpoint string, t reflect.Type, body []byte) {
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return
+	}
+
+	unknown := make(map[string]struct{})
+	unknownFields(t, v, "", unknown)
+	if len(unknown) == 0 {
+		return
+	}
+
+	now := time.Now()
+	drift := SchemaDrift{Method: method, Endpoint: endpoint, Type: t.String()}
+
+	d.mu.Lock()
+	for path := range unknown {
+		key := drift.Type + " " + path
+		if last, ok := d.reported[key]; ok && now.Sub(last) < d.interval {
+			continue
+		}
+		d.reported[key] = now
+		drift.Fields = append(drift.Fields, path)
+	}
+	d.mu.Unlock()
+
+	if len(drift.Fields) == 0 {
+		return
+	}
+	sort.Strings(drift.Fields)
+	d.hook(drift)
+}
+
+// unmarshalerType is the type of json.Unmarshaler, whose fields cannot be known
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// unknownFields collects the paths of fields in a decoded JSON value that type t does not have
+func unknownFields(t reflect.Type, v interface{}, path string, unknown map[string]struct{}) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		fields := jsonFields(t)
+		for name, value := range object {
+			field, ok := lookupField(fields, name)
+			if !ok {
+				unknown[joinPath(path, name)] = struct{}{}
+				continue
+			}
+			unknownFields(field, value, joinPath(path, name), unknown)
+		}
+	case reflect.Slice, reflect.Array:
+		elements, ok := v.([]interface{})
+		if !ok {
+			return
+		}
+		for _, element := range elements {
+			unknownFields(t.Elem(), element, path+"[]", unknown)
+		}
+	case reflect.Map:
+		object, ok := v.(map[string]interface{})
+		if !ok {
+			return
+		}
+		for _, value := range object {
+			unknownFields(t.Elem(), value, path+"[*]", unknown)
+		}
+	}
+}
+
+// jsonFields returns the types of the JSON fields of a struct by name, including the fields
+// of embedded structs
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				for name, typ := range jsonFields(embedded) {
+					if _, ok := fields[name]; !ok {
+						fields[name] = typ
+					}
+				}
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+// lookupField finds a field by name, case-insensitively like encoding/json
+func lookupField(fields map[string]reflect.Type, name string) (reflect.Type, bool) {
+	if field, ok := fields[name]; ok {
+		return field, true
+	}
+	for fieldName, field := range fields {
+		if strings.EqualFold(fieldName, name) {
+			return field, true
+		}
+	}
+	return nil, false
+}
+
+// joinPath appends a field name to a path
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
@@ -0,0 +1,31 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+)
+
+// ErrDryRun is returned for every request made in dry-run mode, since no response exists
+var ErrDryRun = errors.New("request rendered in dry-run mode and not sent")
+
+// SetDryRun enables dry-run mode: requests are rendered in full, with headers, body and
+// idempotency key, and passed to render instead of being sent. No access token is fetched
+// either, so nothing reaches the API. Calls fail with ErrDryRun. Use it to validate payloads
+// or record what would be sent, e.g. in staging. A nil render disables dry-run mode.
+//
+// The rendered request carries the credentials of the client in its headers; redact them
+// before logging the request.
+func (c *Client) SetDryRun(render func(*http.Request)) {
+	c.dryRun = render
+}
+
+// renderDryRun renders a request and passes it to the dry-run receiver
+func (c *Client) renderDryRun(cfg callConfig, method, endpoint string, reqBody *requestBody, idempotencyKey string) (*response, error) {
+	req, err := c.newRequest(cfg.ctx, method, endpoint, reqBody, idempotencyKey, cfg.requestHeader)
+	if err != nil {
+		return &response{}, err
+	}
+
+	c.dryRun(req)
+	return &response{}, ErrDryRun
+}
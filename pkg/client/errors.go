@@ -0,0 +1,299 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/redact"
+)
+
+// ErrReadOnly is returned for modifying requests made while the client is in read-only mode
+var ErrReadOnly = errors.New("client is in read-only mode")
+
+// ReadOnlyError is returned when a modifying request is blocked by read-only mode
+type ReadOnlyError struct {
+	Method   string // HTTP method of the blocked request
+	Endpoint string // Endpoint of the blocked request
+}
+
+// Error implements the error interface
+func (e *ReadOnlyError) Error() string {
+	return fmt.Sprintf("%s %s blocked: %v", e.Method, e.Endpoint, ErrReadOnly)
+}
+
+// Is reports whether the target is ErrReadOnly
+func (e *ReadOnlyError) Is(target error) bool {
+	return target == ErrReadOnly
+}
+
+// ErrCurrencyMismatch is returned when a capture or refund is in another currency than the payment
+var ErrCurrencyMismatch = errors.New("currency does not match the payment currency")
+
+// CurrencyMismatchError is returned when the currency check rejects a capture or refund before it is sent
+type CurrencyMismatchError struct {
+	Reference models.Reference // Reference of the payment
+	Expected  string           // Currency of the payment
+	Actual    string           // Currency of the capture or refund
+}
+
+// Error implements the error interface
+func (e *CurrencyMismatchError) Error() string {
+	return fmt.Sprintf("%v: payment %s is in %s, got %s", ErrCurrencyMismatch, e.Reference, e.Expected, e.Actual)
+}
+
+// Is reports whether the target is ErrCurrencyMismatch
+func (e *CurrencyMismatchError) Is(target error) bool {
+	return target == ErrCurrencyMismatch
+}
+
+// ErrAmountCeiling is returned when a capture or refund exceeds the amount ceiling
+var ErrAmountCeiling = errors.New("amount exceeds the ceiling")
+
+// AmountCeilingError is returned when the amount ceiling blocks a capture or refund
+type AmountCeilingError struct {
+	Operation models.PaymentOperation // The blocked operation, CAPTURE or REFUND
+	Reference models.Reference        // Reference of the payment
+	Amount    models.Amount           // Amount of the operation
+	Limit     int                     // The exceeded limit, in minor units
+	Daily     bool                    // Whether the daily limit was exceeded, rather than the per-payment limit
+}
+
+// Error implements the error interface
+func (e *AmountCeilingError) Error() string {
+	scope := "per-payment"
+	if e.Daily {
+		scope = "daily"
+	}
+	return fmt.Sprintf("%v: %s of %d %s on payment %s exceeds the %s limit of %d", ErrAmountCeiling,
+		e.Operation, e.Amount.Value, e.Amount.Currency, e.Reference, scope, e.Limit)
+}
+
+// Is reports whether the target is ErrAmountCeiling
+func (e *AmountCeilingError) Is(target error) bool {
+	return target == ErrAmountCeiling
+}
+
+// ErrApprovalRejected is returned when an operation requiring approval is rejected
+var ErrApprovalRejected = errors.New("operation rejected by approver")
+
+// ApprovalRejectedError is returned when the approval provider rejects an operation
+type ApprovalRejectedError struct {
+	Request  ApprovalRequest  // The rejected operation
+	Decision ApprovalDecision // The decision of the approver
+}
+
+// Error implements the error interface
+func (e *ApprovalRejectedError) Error() string {
+	msg := fmt.Sprintf("%v: %s of %d %s on payment %s", ErrApprovalRejected, e.Request.Operation,
+		e.Request.Amount.Value, e.Request.Amount.Currency, e.Request.Reference)
+	if e.Decision.Approver != "" {
+		msg += " by " + e.Decision.Approver
+	}
+	if e.Decision.Reason != "" {
+		msg += ": " + e.Decision.Reason
+	}
+	return msg
+}
+
+// Is reports whether the target is ErrApprovalRejected
+func (e *ApprovalRejectedError) Is(target error) bool {
+	return target == ErrApprovalRejected
+}
+
+// ErrLoadShed is returned without sending the request when a low-priority call is shed
+var ErrLoadShed = errors.New("call shed: Vipps MobilePay API is under pressure")
+
+// LoadShedError is returned when load shedding rejects a call, see SetLoadShedding
+type LoadShedError struct {
+	Method   string   // HTTP method of the shed request
+	Endpoint string   // Endpoint of the shed request
+	Priority Priority // Priority of the call
+}
+
+// Error implements the error interface
+func (e *LoadShedError) Error() string {
+	return fmt.Sprintf("%s %s with %s priority: %v", e.Method, e.Endpoint, e.Priority, ErrLoadShed)
+}
+
+// Is reports whether the target is ErrLoadShed
+func (e *LoadShedError) Is(target error) bool {
+	return target == ErrLoadShed
+}
+
+// ErrNothingRemaining is returned by CaptureFull and RefundFull for a payment with no amount
+// left to capture or refund
+var ErrNothingRemaining = errors.New("no amount left to capture or refund")
+
+// ErrMissingScope is returned when the access token lacks a scope an operation needs
+var ErrMissingScope = errors.New("access token is missing required scopes")
+
+// ErrUnknownScopes is returned by RequireScopes when it is unknown which scopes the access
+// token was granted, e.g. for an injected token or one from /accesstoken/get
+var ErrUnknownScopes = errors.New("scopes granted with the access token are unknown")
+
+// ScopeError is returned by RequireScopes when the access token was not granted all scopes
+type ScopeError struct {
+	Missing []string // Required scopes that were not granted
+	Granted []string // Scopes granted with the access token
+}
+
+// Error implements the error interface
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrMissingScope, strings.Join(e.Missing, ", "))
+}
+
+// Is reports whether the target is ErrMissingScope
+func (e *ScopeError) Is(target error) bool {
+	return target == ErrMissingScope
+}
+
+// APIError is returned when the Vipps MobilePay API responds with an error status code.
+// Use errors.As to access it from the errors returned by the API handlers.
+type APIError struct {
+	StatusCode int                   // HTTP status code of the response
+	Problem    *models.ProblemDetail // Parsed problem details, nil if the body is not a problem response
+
+	body   []byte
+	header http.Header
+
+	// Request that failed, to tell apart conditions sharing a status code
+	method   string
+	endpoint string
+}
+
+// newAPIError creates an APIError from an error response
+func newAPIError(method, endpoint string, statusCode int, header http.Header, body []byte) *APIError {
+	apiErr := &APIError{
+		StatusCode: statusCode,
+		body:       body,
+		header:     header,
+		method:     method,
+		endpoint:   endpoint,
+	}
+
+	var problem models.ProblemDetail
+	if err := json.Unmarshal(body, &problem); err == nil {
+		apiErr.Problem = &problem
+	}
+
+	return apiErr
+}
+
+// Error implements the error interface
+func (e *APIError) Error() string {
+	var msg string
+	if e.Problem != nil {
+		msg = fmt.Sprintf("API error: %s - %s (Code: %s, Status: %d)",
+			e.Problem.Title, e.Problem.Detail, e.Problem.Code, e.Problem.Status)
+		for _, detail := range e.Problem.ExtraDetails {
+			msg += fmt.Sprintf("; %s: %s", detail.Name, detail.Reason)
+		}
+	} else {
+		msg = fmt.Sprintf("API error: status code %d, body: %s", e.StatusCode, string(e.body))
+	}
+
+	if traceID := e.TraceID(); traceID != "" {
+		msg += fmt.Sprintf(" [trace ID: %s]", traceID)
+	}
+	// Bodies of failed requests may echo tokens or keys
+	return redact.String(msg)
+}
+
+// errorResponse returns the response the error occurred on
+func (e *APIError) errorResponse() *ErrorResponse {
+	r := newErrorResponse(e.StatusCode, e.header, e.body)
+	r.TraceID = e.TraceID()
+	return r
+}
+
+// TraceID returns the trace ID of the failed request, to quote in support tickets to
+// Vipps MobilePay. It is read from the response headers, or from the problem details.
+func (e *APIError) TraceID() string {
+	if traceID := TraceID(e.header); traceID != "" {
+		return traceID
+	}
+	if e.Problem != nil {
+		return e.Problem.TraceID
+	}
+	return ""
+}
+
+// FieldErrors returns the field-level details of a validation failure, so they can be shown
+// next to the fields they concern; nil if the response carries none
+func (e *APIError) FieldErrors() []models.ProblemExtraDetail {
+	if e.Problem == nil {
+		return nil
+	}
+	return e.Problem.ExtraDetails
+}
+
+// Body returns the raw response body, so fields the SDK does not model can be parsed
+func (e *APIError) Body() []byte {
+	return e.body
+}
+
+// Header returns the response headers
+func (e *APIError) Header() http.Header {
+	return e.header
+}
+
+// ReadError is returned when the body of a response cannot be read completely, e.g. because
+// the connection broke. The status code and headers were received.
+type ReadError struct {
+	StatusCode int         // HTTP status code of the response
+	Header     http.Header // Response headers
+	Body       []byte      // The part of the body read before the failure
+	Err        error       // Underlying read error
+}
+
+// Error implements the error interface
+func (e *ReadError) Error() string {
+	return fmt.Sprintf("failed to read response body (status %d)%s: %v", e.StatusCode, traceSuffix(e.Header), e.Err)
+}
+
+// Unwrap returns the underlying read error
+func (e *ReadError) Unwrap() error {
+	return e.Err
+}
+
+// errorResponse returns the response the error occurred on
+func (e *ReadError) errorResponse() *ErrorResponse {
+	return newErrorResponse(e.StatusCode, e.Header, e.Body)
+}
+
+// ErrorResponse is the response an error occurred on, for logging and escalation to
+// Vipps MobilePay support
+type ErrorResponse struct {
+	StatusCode int         // HTTP status code
+	Header     http.Header // Response headers
+	Body       []byte      // Raw response body, possibly incomplete
+	TraceID    string      // Trace ID of the request, if the response carries one
+}
+
+// newErrorResponse creates an ErrorResponse, reading the trace ID from the headers
+func newErrorResponse(statusCode int, header http.Header, body []byte) *ErrorResponse {
+	return &ErrorResponse{StatusCode: statusCode, Header: header, Body: body, TraceID: TraceID(header)}
+}
+
+// ResponseOf returns the response an error occurred on. It finds the response of API errors,
+// decode errors and read errors anywhere in the error chain, and reports false for errors
+// that occurred before a response was received, such as network errors.
+func ResponseOf(err error) (*ErrorResponse, bool) {
+	var withResponse interface{ errorResponse() *ErrorResponse }
+	if errors.As(err, &withResponse) {
+		return withResponse.errorResponse(), true
+	}
+	return nil, false
+}
+
+// traceSuffix formats the trace ID of a response for error messages
+func traceSuffix(header http.Header) string {
+	if traceID := TraceID(header); traceID != "" {
+		return fmt.Sprintf(" [trace ID: %s]", traceID)
+	}
+	return ""
+}
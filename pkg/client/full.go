@@ -0,0 +1,60 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// CaptureFull captures everything that is authorized and not yet captured or cancelled, as
+// e-commerce plugins do when an order ships in one go. The amount is computed from the
+// aggregate of the payment, which is fetched first. A payment that is fully captured fails
+// with ErrNothingRemaining, so a repeated call does not capture twice. An idempotency key
+// passed with WithIdempotencyKey applies to the capture.
+func (p *Payment) CaptureFull(ctx context.Context, reference models.Reference, opts ...CallOption) (*models.AdjustmentResponse, error) {
+	cfg := p.call(append([]CallOption{WithContext(ctx)}, opts...))
+	amount, err := p.remaining(cfg, models.OperationCapture, reference)
+	if err != nil {
+		return nil, err
+	}
+	return p.capture(cfg, reference, models.ModificationRequest{ModificationAmount: amount})
+}
+
+// RefundFull refunds everything that is captured and not yet refunded, see CaptureFull. A
+// payment that is fully refunded fails with ErrNothingRemaining.
+func (p *Payment) RefundFull(ctx context.Context, reference models.Reference, opts ...CallOption) (*models.AdjustmentResponse, error) {
+	cfg := p.call(append([]CallOption{WithContext(ctx)}, opts...))
+	amount, err := p.remaining(cfg, models.OperationRefund, reference)
+	if err != nil {
+		return nil, err
+	}
+	return p.refund(cfg, reference, models.ModificationRequest{ModificationAmount: amount})
+}
+
+// remaining fetches a payment and returns the amount left for a capture or refund
+func (p *Payment) remaining(cfg callConfig, operation models.PaymentOperation, reference models.Reference) (models.Amount, error) {
+	payment, err := p.get(cfg, reference)
+	if err != nil {
+		return models.Amount{}, err
+	}
+	if err := operationAllowed(operation, payment); err != nil {
+		return models.Amount{}, err
+	}
+
+	var amount models.Amount
+	if payment.Aggregate != nil {
+		if operation == models.OperationCapture {
+			amount = payment.Aggregate.Capturable()
+		} else {
+			amount = payment.Aggregate.Refundable()
+		}
+	}
+	if amount.Value <= 0 {
+		return models.Amount{}, fmt.Errorf("%w: payment %s", ErrNothingRemaining, reference)
+	}
+	if amount.Currency == "" {
+		amount.Currency = payment.Amount.Currency
+	}
+	return amount, nil
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// PaymentIndex records the metadata of created payments, so payments can be found by their
+// metadata later; the ePayment API cannot search payments. Implementations must be safe for
+// concurrent use, and should be persistent to find payments created by earlier processes.
+type PaymentIndex interface {
+	// Record stores the metadata of a created payment
+	Record(reference models.Reference, metadata models.Metadata) error
+	// Find returns the references of payments whose metadata has the given key and value
+	Find(key, value string) ([]models.Reference, error)
+}
+
+// MemoryPaymentIndex is an in-memory PaymentIndex, suitable for tests and single processes
+type MemoryPaymentIndex struct {
+	mu      sync.Mutex
+	entries map[string][]models.Reference
+}
+
+// NewMemoryPaymentIndex creates an empty in-memory payment index
+func NewMemoryPaymentIndex() *MemoryPaymentIndex {
+	return &MemoryPaymentIndex{entries: make(map[string][]models.Reference)}
+}
+
+// Record stores the metadata of a created payment
+func (i *MemoryPaymentIndex) Record(reference models.Reference, metadata models.Metadata) error {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	for key, value := range metadata {
+		entry := key + "=" + value
+		i.entries[entry] = appendReference(i.entries[entry], reference)
+	}
+	return nil
+}
+
+// Find returns the references of payments whose metadata has the given key and value
+func (i *MemoryPaymentIndex) Find(key, value string) ([]models.Reference, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+
+	return append([]models.Reference(nil), i.entries[key+"="+value]...), nil
+}
+
+// appendReference appends a reference unless it is already present
+func appendReference(references []models.Reference, reference models.Reference) []models.Reference {
+	for _, r := range references {
+		if r == reference {
+			return references
+		}
+	}
+	return append(references, reference)
+}
+
+// WithPaymentIndex records the metadata of every payment created by the handler in the
+// index, so FindPaymentsByMetadata can find them
+func WithPaymentIndex(index PaymentIndex) PaymentOption {
+	return func(p *Payment) {
+		p.index = index
+	}
+}
+
+// FindPaymentsByMetadata returns the current state of all payments created with the given
+// metadata, e.g. FindPaymentsByMetadata("orderId", orderID). More than one payment that was
+// not aborted, expired or terminated for the same order points to a duplicate payment, e.g.
+// from a Create retried with a new reference. It requires a payment index.
+func (p *Payment) FindPaymentsByMetadata(key, value string, opts ...CallOption) ([]*models.GetPaymentResponse, error) {
+	if p.index == nil {
+		return nil, fmt.Errorf("finding payments by metadata requires a payment index, see WithPaymentIndex")
+	}
+
+	references, err := p.index.Find(key, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search payment index: %w", err)
+	}
+
+	cfg := p.call(opts)
+	payments := make([]*models.GetPaymentResponse, 0, len(references))
+	for _, reference := range references {
+		payment, err := p.get(cfg, reference)
+		if err != nil {
+			return nil, err
+		}
+		payments = append(payments, payment)
+	}
+
+	return payments, nil
+}
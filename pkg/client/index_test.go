@@ -0,0 +1,175 @@
+package client_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/client"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/vippstest"
+)
+
+// lossyTransport sends create requests to the server but loses the first response, as a
+// timeout would, and records the idempotency keys they were sent with
+type lossyTransport struct {
+	mu   sync.Mutex
+	keys []string
+}
+
+func (t *lossyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	if r.Method != http.MethodPost || r.URL.Path != "/epayment/v1/payments" {
+		return http.DefaultTransport.RoundTrip(r)
+	}
+
+	t.mu.Lock()
+	t.keys = append(t.keys, r.Header.Get("Idempotency-Key"))
+	first := len(t.keys) == 1
+	t.mu.Unlock()
+
+	resp, err := http.DefaultTransport.RoundTrip(r)
+	if err != nil || !first {
+		return resp, err
+	}
+	resp.Body.Close()
+	return nil, errors.New("connection reset by peer")
+}
+
+func newCreateRequest(reference models.Reference, orderID string) models.CreatePaymentRequest {
+	phoneNumber := "4712345678"
+	return models.CreatePaymentRequest{
+		Amount:        models.Amount{Currency: "NOK", Value: 1000},
+		PaymentMethod: &models.PaymentMethod{Type: "WALLET"},
+		Customer:      &models.Customer{PhoneNumber: &phoneNumber},
+		Reference:     reference,
+		UserFlow:      models.UserFlowWebRedirect,
+		ReturnURL:     "https://example.com/return",
+		Metadata:      models.Metadata{"orderId": orderID},
+	}
+}
+
+func TestCreateRetryReusesIdempotencyKey(t *testing.T) {
+	s := vippstest.NewServer()
+	defer s.Close()
+
+	transport := &lossyTransport{}
+	index := client.NewMemoryPaymentIndex()
+	payment := client.NewPayment(s.Client(
+		client.WithHTTPClient(&http.Client{Transport: transport}),
+		client.WithRetryPolicy(client.RequestClassModification, client.RetryPolicy{MaxAttempts: 2, Backoff: time.Millisecond}),
+	), client.WithPaymentIndex(index))
+
+	if _, err := payment.Create(newCreateRequest("order-1001-a", "1001")); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	if len(transport.keys) != 2 {
+		t.Fatalf("sent %d create requests, want 2", len(transport.keys))
+	}
+	if transport.keys[0] == "" || transport.keys[0] != transport.keys[1] {
+		t.Fatalf("retry sent idempotency key %q, first attempt %q", transport.keys[1], transport.keys[0])
+	}
+
+	payments, err := payment.FindPaymentsByMetadata("orderId", "1001")
+	if err != nil {
+		t.Fatalf("FindPaymentsByMetadata failed: %v", err)
+	}
+	if len(payments) != 1 {
+		t.Fatalf("found %d payments for the order, want 1", len(payments))
+	}
+}
+
+func TestCreateRetriedByCallerCreatesOnePayment(t *testing.T) {
+	s := vippstest.NewServer()
+	defer s.Close()
+
+	payment := client.NewPayment(s.Client())
+	req := newCreateRequest("order-1002-a", "1002")
+
+	first, err := payment.Create(req, client.WithIdempotencyKey("create-order-1002"))
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	retried, err := payment.Create(req, client.WithIdempotencyKey("create-order-1002"))
+	if err != nil {
+		t.Fatalf("retried Create failed: %v", err)
+	}
+	if retried.RedirectURL != first.RedirectURL {
+		t.Fatalf("retried Create returned %q, first %q", retried.RedirectURL, first.RedirectURL)
+	}
+
+	if _, err := payment.Create(req, client.WithIdempotencyKey("another-key")); !errors.Is(err, client.ErrDuplicateReference) {
+		t.Fatalf("Create with another key returned %v, want ErrDuplicateReference", err)
+	}
+}
+
+func TestFindPaymentsByMetadataFindsDuplicates(t *testing.T) {
+	s := vippstest.NewServer()
+	defer s.Close()
+
+	index := client.NewMemoryPaymentIndex()
+	payment := client.NewPayment(s.Client(), client.WithPaymentIndex(index))
+
+	// A retry that regenerated the reference created a second payment for the order
+	for _, reference := range []models.Reference{"order-1003-a", "order-1003-b"} {
+		if _, err := payment.Create(newCreateRequest(reference, "1003")); err != nil {
+			t.Fatalf("Create %s failed: %v", reference, err)
+		}
+	}
+	if _, err := payment.Create(newCreateRequest("order-1004-a", "1004")); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+
+	payments, err := payment.FindPaymentsByMetadata("orderId", "1003")
+	if err != nil {
+		t.Fatalf("FindPaymentsByMetadata failed: %v", err)
+	}
+	if len(payments) != 2 {
+		t.Fatalf("found %d payments for the order, want 2", len(payments))
+	}
+	for i, reference := range []models.Reference{"order-1003-a", "order-1003-b"} {
+		if payments[i].Reference != reference {
+			t.Errorf("payment %d is %s, want %s", i, payments[i].Reference, reference)
+		}
+		if payments[i].State != models.PaymentStateCreated {
+			t.Errorf("payment %s is %s, want %s", reference, payments[i].State, models.PaymentStateCreated)
+		}
+	}
+}
+
+// failingIndex is a PaymentIndex whose store is down
+type failingIndex struct{}
+
+func (failingIndex) Record(models.Reference, models.Metadata) error {
+	return errors.New("index unavailable")
+}
+
+func (failingIndex) Find(string, string) ([]models.Reference, error) {
+	return nil, errors.New("index unavailable")
+}
+
+// recordingLogger is a client.Logger keeping the messages it receives
+type recordingLogger struct{ messages []string }
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.messages = append(l.messages, fmt.Sprintf(format, v...))
+}
+
+func TestFailingIndexIsLoggedWithClientLogger(t *testing.T) {
+	s := vippstest.NewServer()
+	defer s.Close()
+
+	logger := &recordingLogger{}
+	payment := client.NewPayment(s.Client(client.WithLogger(logger)), client.WithPaymentIndex(failingIndex{}))
+
+	if _, err := payment.Create(newCreateRequest("order-1005-a", "1005")); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if len(logger.messages) != 1 || !strings.Contains(logger.messages[0], "index unavailable") {
+		t.Fatalf("logged %q, want the index error", logger.messages)
+	}
+}
@@ -0,0 +1,50 @@
+package client
+
+import (
+	"net/http"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// WithLocale sets the language of the landing page and push messages users see for the
+// payments created by the handler. It defaults to the language of the market set with
+// WithMarket, e.g. Finnish for MarketFinland.
+func WithLocale(locale models.Locale) PaymentOption {
+	return func(p *Payment) {
+		p.locale = locale
+	}
+}
+
+// ForLocale sets the language of the landing page and push messages for a single payment,
+// e.g. the language the user chose in the shop
+func ForLocale(locale models.Locale) CallOption {
+	return func(cfg *callConfig) {
+		cfg.locale = locale
+	}
+}
+
+// applyLocale sends the language of a user-facing call as the Accept-Language header: the
+// locale of the call, the handler or the market, in that order. Without any, no header is
+// sent and Vipps MobilePay picks the language.
+func (p *Payment) applyLocale(cfg *callConfig) {
+	locale := cfg.locale
+	if locale == "" {
+		locale = p.locale
+	}
+	if locale == "" && p.market != "" {
+		locale = p.market.Locale()
+	}
+	if locale == "" {
+		return
+	}
+
+	// The header of the call options may be shared between calls, so it is copied
+	header := make(http.Header, len(cfg.requestHeader)+1)
+	for name, values := range cfg.requestHeader {
+		header[name] = values
+	}
+	if header.Get("Accept-Language") == "" {
+		header.Set("Accept-Language", string(locale))
+	}
+	cfg.requestHeader = header
+}
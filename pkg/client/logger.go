@@ -0,0 +1,26 @@
+package client
+
+import "log"
+
+// Logger receives the diagnostics of a client, such as failing token stores, audit sinks
+// and payment indexes that do not fail the call. A *log.Logger satisfies it.
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// SetLogger sets the logger of the client's diagnostics; nil restores the default, which is
+// the standard logger, or no logging at all for clients created with NewStrict. To silence
+// a client, pass log.New(io.Discard, "", 0).
+func (c *Client) SetLogger(logger Logger) {
+	c.logger = logger
+}
+
+// logf logs a diagnostic with the client's logger
+func (c *Client) logf(format string, v ...interface{}) {
+	switch {
+	case c.logger != nil:
+		c.logger.Printf(format, v...)
+	case !c.strict:
+		log.Printf(format, v...)
+	}
+}
@@ -0,0 +1,26 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// GetSalesUnit returns the configuration of a sales unit from the Management API, e.g. to
+// check its capture type. An empty msn means the sales unit the call is made for, the
+// client's unless set with ForMSN.
+func (c *Client) GetSalesUnit(msn string, opts ...CallOption) (*models.SalesUnit, error) {
+	cfg := newCallConfig(callConfig{}, opts)
+	if msn == "" {
+		msn = cfg.merchant(c)
+	}
+
+	endpoint := fmt.Sprintf("/management/v1/sales-units/%s", msn)
+	unit, _, err := doJSON[models.SalesUnit](c, cfg, http.MethodGet, endpoint, nil, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sales unit: %w", err)
+	}
+
+	return unit, nil
+}
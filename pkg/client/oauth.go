@@ -0,0 +1,43 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// OAuthTokenPath is the path of the standard OAuth 2.0 token endpoint of the API
+const OAuthTokenPath = "/miami/v1/token"
+
+// SetOAuthTokenEndpoint fetches access tokens from a standard OAuth 2.0 token endpoint with the
+// client_credentials grant, instead of from /accesstoken/get. The client ID and secret are
+// sent with HTTP basic authentication. Some partner setups only issue credentials for this
+// endpoint. An empty path restores /accesstoken/get; use OAuthTokenPath for the API's endpoint.
+func (c *Client) SetOAuthTokenEndpoint(path string) {
+	c.oauthTokenPath = path
+}
+
+// newOAuthTokenRequest builds a client_credentials token request
+func (c *Client) newOAuthTokenRequest(creds Credentials) (*http.Request, string, error) {
+	endpoint := c.oauthTokenPath
+	form := url.Values{"grant_type": {"client_credentials"}}
+	c.tokenMu.RLock()
+	if len(c.scopes) > 0 {
+		form.Set("scope", strings.Join(c.scopes, " "))
+	}
+	c.tokenMu.RUnlock()
+
+	req, err := http.NewRequest("POST", c.BaseURL+endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(creds.ClientID), url.QueryEscape(creds.ClientSecret))
+	req.Header.Set("Ocp-Apim-Subscription-Key", c.subscriptionKey(creds))
+	req.Header.Set("Merchant-Serial-Number", creds.MSN)
+
+	return req, endpoint, nil
+}
@@ -0,0 +1,234 @@
+package client
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Credentials are the API keys of a sales unit, found in the merchant portal
+type Credentials struct {
+	ClientID        string
+	ClientSecret    string
+	SubscriptionKey string // Ocp-Apim-Subscription-Key
+	MSN             string // Merchant-Serial-Number
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// optionFailed records the error of an option that could not be applied, failing NewStrict
+// and every request of the client, since New cannot return it
+func (c *Client) optionFailed(option string, err error) {
+	if err != nil {
+		c.optionErr = errors.Join(c.optionErr, fmt.Errorf("failed to apply %s: %w", option, err))
+	}
+}
+
+// WithTestMode selects the test environment when testMode is true
+func WithTestMode(testMode bool) Option {
+	return func(c *Client) {
+		c.TestMode = testMode
+	}
+}
+
+// WithBaseURL sets the base URL of the API, e.g. to point the client at an httptest.Server
+// or to route requests through an internal API gateway. It takes precedence over the URL
+// selected by WithTestMode.
+func WithBaseURL(baseURL string) Option {
+	return func(c *Client) {
+		c.BaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+}
+
+// WithHTTPClient sets the HTTP client used for requests. Options modifying the HTTP client,
+// such as WithTimeout, apply to the client set here when they are passed after it.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) {
+		c.client = httpClient
+	}
+}
+
+// WithTimeout sets the timeout for HTTP requests
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) {
+		c.SetTimeout(timeout)
+	}
+}
+
+// WithSystemInfo sets the system information sent in HTTP headers
+func WithSystemInfo(name, version, pluginName, pluginVersion string) Option {
+	return func(c *Client) {
+		c.SetSystemInfo(name, version, pluginName, pluginVersion)
+	}
+}
+
+// WithLatencyObserver registers a hook that is called after every API call with its latency
+func WithLatencyObserver(observer LatencyObserver) Option {
+	return func(c *Client) {
+		c.ObserveLatency(observer)
+	}
+}
+
+// WithRetryPolicy sets the retry policy used for a class of requests
+func WithRetryPolicy(class RequestClass, policy RetryPolicy) Option {
+	return func(c *Client) {
+		c.SetRetryPolicy(class, policy)
+	}
+}
+
+// WithReadOnly enables read-only mode, in which only GET requests are sent
+func WithReadOnly(readOnly bool) Option {
+	return func(c *Client) {
+		c.SetReadOnly(readOnly)
+	}
+}
+
+// WithThrottlePolicy sets how rate-limited requests are handled
+func WithThrottlePolicy(policy ThrottlePolicy) Option {
+	return func(c *Client) {
+		c.SetThrottlePolicy(policy)
+	}
+}
+
+// WithCircuitBreaker enables the circuit breaker
+func WithCircuitBreaker(config CircuitBreakerConfig) Option {
+	return func(c *Client) {
+		c.SetCircuitBreaker(config)
+	}
+}
+
+// WithTLSConfig sets the TLS configuration of the HTTP transport. If the HTTP client uses
+// a custom transport that is not an *http.Transport, NewStrict fails and so does every
+// request of the client.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *Client) {
+		c.optionFailed("WithTLSConfig", c.SetTLSConfig(config))
+	}
+}
+
+// WithProxy routes requests through an outbound HTTP proxy instead of the one configured by
+// environment variables; an empty URL disables proxying. If the URL is invalid or the HTTP
+// client uses a custom transport, NewStrict fails and so does every request of the client.
+func WithProxy(proxyURL string) Option {
+	return func(c *Client) {
+		c.optionFailed("WithProxy", c.SetProxy(proxyURL))
+	}
+}
+
+// WithRequestCompression gzip compresses request bodies of at least minBytes
+func WithRequestCompression(minBytes int) Option {
+	return func(c *Client) {
+		c.SetRequestCompression(minBytes)
+	}
+}
+
+// WithPoolOptions tunes the connection pool and HTTP/2 use of the HTTP transport. If the
+// HTTP client uses a custom transport that is not an *http.Transport, NewStrict fails and
+// so does every request of the client.
+func WithPoolOptions(options PoolOptions) Option {
+	return func(c *Client) {
+		c.optionFailed("WithPoolOptions", c.SetPoolOptions(options))
+	}
+}
+
+// WithDryRun enables dry-run mode, passing requests to render instead of sending them
+func WithDryRun(render func(*http.Request)) Option {
+	return func(c *Client) {
+		c.SetDryRun(render)
+	}
+}
+
+// WithAuditSink sets the sink receiving a redacted audit record for every API call
+func WithAuditSink(sink AuditSink) Option {
+	return func(c *Client) {
+		c.SetAuditSink(sink, "")
+	}
+}
+
+// WithCodec sets the codec used for request and response bodies
+func WithCodec(codec Codec) Option {
+	return func(c *Client) {
+		c.SetCodec(codec)
+	}
+}
+
+// WithSchemaDriftHook enables schema drift detection, reporting response fields the models do not know
+func WithSchemaDriftHook(hook SchemaDriftHook, interval time.Duration) Option {
+	return func(c *Client) {
+		c.SetSchemaDriftHook(hook, interval)
+	}
+}
+
+// WithLoadShedding enables load shedding of low-priority calls while the API is under pressure
+func WithLoadShedding(policy LoadSheddingPolicy) Option {
+	return func(c *Client) {
+		c.SetLoadShedding(policy)
+	}
+}
+
+// WithTokenStore sets the store sharing access tokens with other instances
+func WithTokenStore(store TokenStore) Option {
+	return func(c *Client) {
+		c.SetTokenStore(store)
+	}
+}
+
+// WithRefreshMargin sets how long before its expiry the access token is renewed
+func WithRefreshMargin(margin time.Duration) Option {
+	return func(c *Client) {
+		c.SetRefreshMargin(margin)
+	}
+}
+
+// WithOAuthTokenEndpoint fetches access tokens from a standard OAuth 2.0 token endpoint, e.g. OAuthTokenPath
+func WithOAuthTokenEndpoint(path string) Option {
+	return func(c *Client) {
+		c.SetOAuthTokenEndpoint(path)
+	}
+}
+
+// WithTokenScopes requests scopes with access tokens, e.g. for Login and Userinfo
+func WithTokenScopes(scopes ...string) Option {
+	return func(c *Client) {
+		c.SetTokenScopes(scopes...)
+	}
+}
+
+// WithCredentialProvider resolves the credentials at request time, e.g. from a secret manager
+func WithCredentialProvider(provider CredentialProvider) Option {
+	return func(c *Client) {
+		c.SetCredentialProvider(provider)
+	}
+}
+
+// WithoutTokenFetching makes the client use only tokens set with SetAccessToken
+func WithoutTokenFetching() Option {
+	return func(c *Client) {
+		c.DisableTokenFetching()
+	}
+}
+
+// WithSecondarySubscriptionKey sets the subscription key used when the primary one is rejected
+func WithSecondarySubscriptionKey(key string) Option {
+	return func(c *Client) {
+		c.SetSecondarySubscriptionKey(key)
+	}
+}
+
+// WithClock sets the clock used for token expiry and timestamps
+func WithClock(clock Clock) Option {
+	return func(c *Client) {
+		c.SetClock(clock)
+	}
+}
+
+// WithLogger sets the logger of the client's diagnostics
+func WithLogger(logger Logger) Option {
+	return func(c *Client) {
+		c.SetLogger(logger)
+	}
+}
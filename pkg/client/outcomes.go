@@ -0,0 +1,148 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// outcomeTrackerCapacity bounds the number of payments and outcomes remembered for tagging and deduplication
+const outcomeTrackerCapacity = 10000
+
+// Outcome is a business outcome of a payment, such as created, authorized or captured,
+// reported to an OutcomeObserver so dashboards can show conversion rates
+type Outcome struct {
+	Event     models.PaymentEventName // What happened to the payment
+	Reference models.Reference        // Payment reference
+	Amount    models.Amount           // Amount of the payment or the operation, tagged with its currency
+	UserFlow  models.PaymentUserFlow  // User flow of the payment, empty if it was not created by this client
+}
+
+// OutcomeObserver receives business outcomes of payments
+type OutcomeObserver func(Outcome)
+
+// WithOutcomeObserver sets an observer receiving the outcomes of payments. Created, captured,
+// refunded and cancelled outcomes are reported when the operations succeed. Outcomes reached
+// in the app, such as authorized and aborted, are reported when Get sees them while polling,
+// or when a webhook event is passed to ObserveWebhook. Every outcome is reported once, however
+// many times it is seen.
+func WithOutcomeObserver(observer OutcomeObserver) PaymentOption {
+	return func(p *Payment) {
+		p.outcomes = &outcomeTracker{
+			observer: observer,
+			flows:    make(map[models.Reference]models.PaymentUserFlow),
+			seen:     make(map[string]struct{}),
+		}
+	}
+}
+
+// ObserveWebhook reports the outcome of a webhook event to the outcome observer, if any
+func (p *Payment) ObserveWebhook(event *models.WebhookEvent) {
+	if p.outcomes == nil || event == nil || !event.Success {
+		return
+	}
+
+	key := event.Reference.String()
+	switch event.Name {
+	case models.EventCaptured, models.EventRefunded:
+		// A payment can be captured and refunded several times, identify the operation instead
+		key = event.IdempotencyKey
+		if key == "" {
+			key = event.PSPReference.String()
+		}
+	}
+
+	p.outcomes.report(key, Outcome{
+		Event:     event.Name,
+		Reference: event.Reference,
+		Amount:    event.Amount,
+	})
+}
+
+// outcomeTracker reports outcomes once each, and tags them with the user flow of the payment
+type outcomeTracker struct {
+	observer OutcomeObserver
+
+	mu       sync.Mutex
+	flows    map[models.Reference]models.PaymentUserFlow
+	flowKeys []models.Reference
+	seen     map[string]struct{}
+	seenKeys []string
+}
+
+// created remembers the user flow of a created payment and reports its creation
+func (t *outcomeTracker) created(reference models.Reference, amount models.Amount, flow models.PaymentUserFlow) {
+	t.mu.Lock()
+	if _, ok := t.flows[reference]; !ok {
+		if len(t.flowKeys) >= outcomeTrackerCapacity {
+			delete(t.flows, t.flowKeys[0])
+			t.flowKeys = t.flowKeys[1:]
+		}
+		t.flowKeys = append(t.flowKeys, reference)
+	}
+	t.flows[reference] = flow
+	t.mu.Unlock()
+
+	t.report(reference.String(), Outcome{
+		Event:     models.EventCreated,
+		Reference: reference,
+		Amount:    amount,
+	})
+}
+
+// sameOutcomes maps events to an event of the same outcome, which they are deduplicated with:
+// a cancelled payment ends in the TERMINATED state, which Get would otherwise report again
+var sameOutcomes = map[models.PaymentEventName]models.PaymentEventName{
+	models.EventTerminated: models.EventCancelled,
+}
+
+// report passes an outcome to the observer unless an outcome with the same event, or an
+// event of the same outcome, and key has already been reported
+func (t *outcomeTracker) report(key string, outcome Outcome) {
+	event := outcome.Event
+	if same, ok := sameOutcomes[event]; ok {
+		event = same
+	}
+	key = string(event) + ":" + key
+
+	t.mu.Lock()
+	if _, ok := t.seen[key]; ok {
+		t.mu.Unlock()
+		return
+	}
+	if len(t.seenKeys) >= outcomeTrackerCapacity {
+		delete(t.seen, t.seenKeys[0])
+		t.seenKeys = t.seenKeys[1:]
+	}
+	t.seen[key] = struct{}{}
+	t.seenKeys = append(t.seenKeys, key)
+
+	if outcome.UserFlow == "" {
+		outcome.UserFlow = t.flows[outcome.Reference]
+	}
+	t.mu.Unlock()
+
+	t.observer(outcome)
+}
+
+// stateOutcomes maps payment states reached outside of this client to their outcome
+var stateOutcomes = map[models.PaymentState]models.PaymentEventName{
+	models.PaymentStateAuthorized: models.EventAuthorized,
+	models.PaymentStateAborted:    models.EventAborted,
+	models.PaymentStateExpired:    models.EventExpired,
+	models.PaymentStateTerminated: models.EventTerminated,
+}
+
+// polled reports the outcome of a payment state seen while polling
+func (t *outcomeTracker) polled(payment *models.GetPaymentResponse) {
+	event, ok := stateOutcomes[payment.State]
+	if !ok {
+		return
+	}
+
+	t.report(payment.Reference.String(), Outcome{
+		Event:     event,
+		Reference: payment.Reference,
+		Amount:    payment.Amount,
+	})
+}
@@ -0,0 +1,38 @@
+package client_test
+
+import (
+	"testing"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/client"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/vippstest"
+)
+
+func TestCancelledPaymentIsNotReportedTerminated(t *testing.T) {
+	s := vippstest.NewServer()
+	defer s.Close()
+
+	var events []models.PaymentEventName
+	payment := client.NewPayment(s.Client(), client.WithOutcomeObserver(func(outcome client.Outcome) {
+		events = append(events, outcome.Event)
+	}))
+
+	const reference = "order-1006-a"
+	if _, err := payment.Create(newCreateRequest(reference, "1006")); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := payment.Cancel(reference, models.CancelModificationRequest{}); err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	got, err := payment.Get(reference)
+	if err != nil {
+		t.Fatalf("Get failed: %v", err)
+	}
+	if got.State != models.PaymentStateTerminated {
+		t.Fatalf("cancelled payment is %s, want %s", got.State, models.PaymentStateTerminated)
+	}
+
+	if len(events) != 2 || events[0] != models.EventCreated || events[1] != models.EventCancelled {
+		t.Fatalf("reported %v, want [%s %s]", events, models.EventCreated, models.EventCancelled)
+	}
+}
@@ -1,140 +1,510 @@
 package client
 
 import (
-	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
+	"strings"
+	"time"
 
-	"github.com/google/uuid"
 	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/redact"
 )
 
+// PaymentAPI is the ePayment API as implemented by *Payment. Depend on it instead of *Payment
+// in your own services, so their unit tests can substitute a fake, e.g. a struct embedding
+// PaymentAPI that overrides only the methods a test calls.
+type PaymentAPI interface {
+	Create(req models.CreatePaymentRequest, opts ...CallOption) (*models.CreatePaymentResponse, error)
+	Get(reference models.Reference, opts ...CallOption) (*models.GetPaymentResponse, error)
+	GetEvents(reference models.Reference, opts ...CallOption) ([]models.PaymentEvent, error)
+	Capture(reference models.Reference, req models.ModificationRequest, opts ...CallOption) (*models.AdjustmentResponse, error)
+	Refund(reference models.Reference, req models.ModificationRequest, opts ...CallOption) (*models.AdjustmentResponse, error)
+	Cancel(reference models.Reference, req models.CancelModificationRequest, opts ...CallOption) (*models.AdjustmentResponse, error)
+	ForceApprove(reference models.Reference, customerPhoneNumber, reason string, opts ...CallOption) error
+}
+
+var _ PaymentAPI = (*Payment)(nil)
+
 // Payment handles all payment-related API calls
 type Payment struct {
 	client *Client
+
+	// Market and currency applied to amounts without a currency
+	market          models.Market
+	defaultCurrency string
+
+	// Prefix tagging references created outside production
+	referencePrefix string
+
+	// Shortener for generated payment links
+	shortener Shortener
+
+	// Total time budget for a single operation, including retries
+	budget time.Duration
+
+	// Optional reporting of business outcomes
+	outcomes *outcomeTracker
+
+	// Optional cache of payment currencies for the currency check
+	currencies *currencyCache
+
+	// Whether operations are checked against the state of the payment before they are sent
+	stateCheck bool
+
+	// Optional index of created payments by metadata
+	index PaymentIndex
+
+	// Optional store of the context of created payments
+	contexts PaymentContextStore
+
+	// Optional ceiling for capture and refund amounts
+	ceiling *ceilingTracker
+
+	// Optional maker-checker approval of operations
+	approval *approvalPolicy
+
+	// Whether payments are captured on authorization
+	directCapture *directCapture
+
+	// Language of the landing page and push messages; empty means that of the market
+	locale models.Locale
+}
+
+// Shortener shortens generated payment links, e.g. so they fit in an SMS
+type Shortener interface {
+	Shorten(url string) (string, error)
+}
+
+// NoopShortener is a Shortener that returns links unchanged
+type NoopShortener struct{}
+
+// Shorten returns the URL unchanged
+func (NoopShortener) Shorten(url string) (string, error) {
+	return url, nil
+}
+
+// PaymentOption configures a Payment handler
+type PaymentOption func(*Payment)
+
+// WithMarket sets the market the payments are made in. Amounts without a currency get the
+// market's currency, and amounts in any other currency are rejected before being sent. With
+// a market the SDK does not know, every amount is rejected with a validation error naming it.
+func WithMarket(market models.Market) PaymentOption {
+	return func(p *Payment) {
+		p.market = market
+		p.defaultCurrency = market.Currency()
+	}
+}
+
+// WithDefaultCurrency sets the currency used for amounts that do not specify one
+func WithDefaultCurrency(currency string) PaymentOption {
+	return func(p *Payment) {
+		p.defaultCurrency = currency
+	}
+}
+
+// WithReferencePrefix sets an environment tag (e.g. "test-") that is automatically prepended to
+// references in the test environment. In production, references carrying the prefix are refused,
+// so test data never ends up in production.
+func WithReferencePrefix(prefix string) PaymentOption {
+	return func(p *Payment) {
+		p.referencePrefix = prefix
+	}
+}
+
+// WithShortener sets the shortener used for the payment links returned by Create.
+// The shortened links are available as ShortRedirectURL and ShortQRImageURL.
+func WithShortener(shortener Shortener) PaymentOption {
+	return func(p *Payment) {
+		p.shortener = shortener
+	}
+}
+
+// WithDeadlineBudget sets the total time a single operation may take, e.g. the latency budget
+// of a checkout step. The budget is divided over the attempts of the operation, so retries
+// never exceed it even when the retry policy would allow more attempts. Requests checking an
+// operation, such as the state check and currency check, and its approval count against the
+// same budget.
+func WithDeadlineBudget(total time.Duration) PaymentOption {
+	return func(p *Payment) {
+		p.budget = total
+	}
 }
 
 // NewPayment creates a new payment API handler
-func NewPayment(client *Client) *Payment {
-	return &Payment{
-		client: client,
+func NewPayment(client *Client, opts ...PaymentOption) *Payment {
+	p := &Payment{
+		client:    client,
+		shortener: NoopShortener{},
+	}
+
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// call returns the call configuration for a new operation. Call options override the
+// deadline budget of the Payment handler. The deadline is computed once per public operation,
+// and the requests made to check the operation, such as the state check, share it.
+func (p *Payment) call(opts []CallOption) callConfig {
+	var cfg callConfig
+	if p.budget > 0 {
+		cfg.deadline = time.Now().Add(p.budget)
+	}
+	return newCallConfig(cfg, opts)
+}
+
+// resolveReference applies the environment reference prefix to a reference
+func (p *Payment) resolveReference(reference models.Reference) (models.Reference, error) {
+	if p.referencePrefix == "" {
+		return reference, nil
+	}
+
+	hasPrefix := strings.HasPrefix(string(reference), p.referencePrefix)
+	if !p.client.TestMode {
+		if hasPrefix {
+			problems := &models.ValidationError{}
+			problems.Add("reference", fmt.Sprintf("%s has test prefix %q and cannot be used in production", reference, p.referencePrefix))
+			return "", problems
+		}
+		return reference, nil
+	}
+
+	if hasPrefix {
+		return reference, nil
+	}
+	return models.Reference(p.referencePrefix) + reference, nil
+}
+
+// applyCurrency fills in the default currency and validates the amount against the configured
+// market. Problems are reported for the currency of the amount field given.
+func (p *Payment) applyCurrency(amount *models.Amount, field string) error {
+	if amount.Currency == "" {
+		amount.Currency = p.defaultCurrency
+	}
+
+	problems := &models.ValidationError{}
+	if p.market != "" && p.market.Currency() == "" {
+		problems.Add(field+".currency", fmt.Sprintf("market %s is unknown, expected %s, %s or %s",
+			p.market, models.MarketNorway, models.MarketDenmark, models.MarketFinland))
+	} else if p.market != "" && amount.Currency != "" && amount.Currency != p.market.Currency() {
+		problems.Add(field+".currency", fmt.Sprintf("%s is not valid for market %s, expected %s",
+			amount.Currency, p.market, p.market.Currency()))
 	}
+
+	return problems.Err()
 }
 
 // Create initiates a new payment
-func (p *Payment) Create(req models.CreatePaymentRequest) (*models.CreatePaymentResponse, error) {
+func (p *Payment) Create(req models.CreatePaymentRequest, opts ...CallOption) (*models.CreatePaymentResponse, error) {
 	endpoint := "/epayment/v1/payments"
 
-	// Generate a new idempotency key for the request
-	idempotencyKey := uuid.New().String()
+	reference, err := p.resolveReference(req.Reference)
+	if err != nil {
+		return nil, err
+	}
+	req.Reference = reference
+
+	problems := &models.ValidationError{}
+	problems.Merge("", p.applyCurrency(&req.Amount, "amount"))
+	problems.Merge("", req.Validate())
+	if err := problems.Err(); err != nil {
+		return nil, err
+	}
+	if p.directCapture != nil {
+		if err := p.verifyDirectCapture(opts); err != nil {
+			return nil, err
+		}
+	}
 
-	body, statusCode, err := p.client.DoRequest(http.MethodPost, endpoint, req, idempotencyKey)
+	cfg := p.call(opts)
+	p.applyLocale(&cfg)
+	idempotencyKey := cfg.operationKey()
+
+	response, resp, err := doJSON[models.CreatePaymentResponse](p.client, cfg, http.MethodPost, endpoint, req, idempotencyKey)
 	if err != nil {
-		log.Printf("Error creating payment, status code: %d, response: %s", statusCode, string(body))
+		if p.client.strict {
+			p.client.logf("Error creating payment, status code: %d", resp.statusCode)
+		} else {
+			p.client.logf("Error creating payment, status code: %d, response: %s", resp.statusCode, redact.String(string(resp.body)))
+		}
 		return nil, fmt.Errorf("failed to create payment: %w", err)
 	}
+	response.TraceID = TraceID(resp.header)
+
+	response.ShortRedirectURL = p.shorten(response.RedirectURL)
+	response.ShortQRImageURL = p.shorten(response.QRImageURL)
+
+	if p.outcomes != nil {
+		p.outcomes.created(req.Reference, req.Amount, req.UserFlow)
+	}
+	if p.currencies != nil {
+		p.currencies.set(req.Reference, req.Amount.Currency)
+	}
+	if p.index != nil && len(req.Metadata) > 0 {
+		// The payment has been created, so a failing index must not fail the call
+		if err := p.index.Record(req.Reference, req.Metadata); err != nil {
+			p.client.logf("Error recording payment %s in payment index: %v", req.Reference, err)
+		}
+	}
+	if p.contexts != nil {
+		if err := p.contexts.Save(models.NewPaymentContext(&req)); err != nil {
+			p.client.logf("Error saving context of payment %s: %v", req.Reference, err)
+		}
+	}
+
+	return response, nil
+}
+
+// shorten shortens a payment link, falling back to the full link if shortening fails,
+// since the payment has already been created at this point
+func (p *Payment) shorten(url string) string {
+	if url == "" {
+		return ""
+	}
 
-	var response models.CreatePaymentResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	short, err := p.shortener.Shorten(url)
+	if err != nil {
+		p.client.logf("Error shortening payment link, using full link: %v", err)
+		return url
 	}
 
-	return &response, nil
+	return short
 }
 
 // Get retrieves information about a payment by its reference
-func (p *Payment) Get(reference string) (*models.GetPaymentResponse, error) {
+func (p *Payment) Get(reference models.Reference, opts ...CallOption) (*models.GetPaymentResponse, error) {
+	return p.get(p.call(opts), reference)
+}
+
+// get retrieves a payment within the deadline of an operation
+func (p *Payment) get(cfg callConfig, reference models.Reference) (*models.GetPaymentResponse, error) {
+	reference, err := p.resolveReference(reference)
+	if err != nil {
+		return nil, err
+	}
+
 	endpoint := fmt.Sprintf("/epayment/v1/payments/%s", reference)
 
-	body, _, err := p.client.DoRequest(http.MethodGet, endpoint, nil, "")
+	response, resp, err := doJSON[models.GetPaymentResponse](p.client, cfg, http.MethodGet, endpoint, nil, "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get payment: %w", err)
 	}
+	response.TraceID = TraceID(resp.header)
 
-	var response models.GetPaymentResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	if p.outcomes != nil {
+		p.outcomes.polled(response)
+	}
+	if p.currencies != nil {
+		p.currencies.set(response.Reference, response.Amount.Currency)
 	}
 
-	return &response, nil
+	return response, nil
 }
 
 // GetEvents retrieves the event log for a payment by its reference
-func (p *Payment) GetEvents(reference string) ([]models.PaymentEvent, error) {
+func (p *Payment) GetEvents(reference models.Reference, opts ...CallOption) ([]models.PaymentEvent, error) {
+	reference, err := p.resolveReference(reference)
+	if err != nil {
+		return nil, err
+	}
+
 	endpoint := fmt.Sprintf("/epayment/v1/payments/%s/events", reference)
 
-	body, _, err := p.client.DoRequest(http.MethodGet, endpoint, nil, "")
+	events, _, err := doJSON[[]models.PaymentEvent](p.client, p.call(opts), http.MethodGet, endpoint, nil, "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get payment events: %w", err)
 	}
 
-	var events []models.PaymentEvent
-	if err := json.Unmarshal(body, &events); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	return events, nil
+	return *events, nil
 }
 
 // Capture captures funds from a previously authorized payment
-func (p *Payment) Capture(reference string, req models.ModificationRequest) (*models.AdjustmentResponse, error) {
+func (p *Payment) Capture(reference models.Reference, req models.ModificationRequest, opts ...CallOption) (*models.AdjustmentResponse, error) {
+	return p.capture(p.call(opts), reference, req)
+}
+
+// capture captures funds within the deadline of an operation
+func (p *Payment) capture(cfg callConfig, reference models.Reference, req models.ModificationRequest) (*models.AdjustmentResponse, error) {
+	reference, err := p.resolveReference(reference)
+	if err != nil {
+		return nil, err
+	}
+
 	endpoint := fmt.Sprintf("/epayment/v1/payments/%s/capture", reference)
 
-	idempotencyKey := uuid.New().String()
-	body, _, err := p.client.DoRequest(http.MethodPost, endpoint, req, idempotencyKey)
+	if p.directCapture != nil {
+		return nil, fmt.Errorf("failed to capture payment %s: %w", reference, ErrDirectCapture)
+	}
+
+	problems := &models.ValidationError{}
+	problems.Merge("", p.applyCurrency(&req.ModificationAmount, "modificationAmount"))
+	problems.Merge("modificationAmount", req.ModificationAmount.Validate())
+	if err := problems.Err(); err != nil {
+		return nil, err
+	}
+	// The state check fetches the payment, so the currency check finds its currency cached
+	if _, err := p.checkState(cfg, models.OperationCapture, reference); err != nil {
+		return nil, err
+	}
+	if err := p.checkCurrency(cfg, reference, req.ModificationAmount.Currency); err != nil {
+		return nil, err
+	}
+
+	release, err := p.reserveCeiling(models.OperationCapture, reference, req.ModificationAmount, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to capture payment: %w", err)
+		return nil, err
 	}
 
-	var response models.AdjustmentResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	idempotencyKey := cfg.operationKey()
+	response, resp, err := doJSON[models.AdjustmentResponse](p.client, cfg, http.MethodPost, endpoint, req, idempotencyKey)
+	if err != nil {
+		release(err)
+		return nil, fmt.Errorf("failed to capture payment: %w", err)
+	}
+	response.TraceID = TraceID(resp.header)
+
+	if p.outcomes != nil {
+		p.outcomes.report(idempotencyKey, Outcome{
+			Event:     models.EventCaptured,
+			Reference: reference,
+			Amount:    req.ModificationAmount,
+		})
 	}
 
-	return &response, nil
+	return response, nil
 }
 
 // Refund returns funds from a previously captured payment
-func (p *Payment) Refund(reference string, req models.ModificationRequest) (*models.AdjustmentResponse, error) {
+func (p *Payment) Refund(reference models.Reference, req models.ModificationRequest, opts ...CallOption) (*models.AdjustmentResponse, error) {
+	return p.refund(p.call(opts), reference, req)
+}
+
+// refund returns funds within the deadline of an operation
+func (p *Payment) refund(cfg callConfig, reference models.Reference, req models.ModificationRequest) (*models.AdjustmentResponse, error) {
+	reference, err := p.resolveReference(reference)
+	if err != nil {
+		return nil, err
+	}
+
 	endpoint := fmt.Sprintf("/epayment/v1/payments/%s/refund", reference)
 
-	idempotencyKey := uuid.New().String()
-	body, _, err := p.client.DoRequest(http.MethodPost, endpoint, req, idempotencyKey)
+	problems := &models.ValidationError{}
+	problems.Merge("", p.applyCurrency(&req.ModificationAmount, "modificationAmount"))
+	problems.Merge("modificationAmount", req.ModificationAmount.Validate())
+	if err := problems.Err(); err != nil {
+		return nil, err
+	}
+	if _, err := p.checkState(cfg, models.OperationRefund, reference); err != nil {
+		return nil, err
+	}
+	if err := p.checkCurrency(cfg, reference, req.ModificationAmount.Currency); err != nil {
+		return nil, err
+	}
+
+	if err := p.approve(cfg, ApprovalRequest{
+		Operation: models.OperationRefund,
+		Reference: reference,
+		Amount:    req.ModificationAmount,
+	}); err != nil {
+		return nil, err
+	}
+
+	release, err := p.reserveCeiling(models.OperationRefund, reference, req.ModificationAmount, cfg)
 	if err != nil {
-		return nil, fmt.Errorf("failed to refund payment: %w", err)
+		return nil, err
 	}
 
-	var response models.AdjustmentResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	idempotencyKey := cfg.operationKey()
+	response, resp, err := doJSON[models.AdjustmentResponse](p.client, cfg, http.MethodPost, endpoint, req, idempotencyKey)
+	if err != nil {
+		release(err)
+		return nil, fmt.Errorf("failed to refund payment: %w", err)
+	}
+	response.TraceID = TraceID(resp.header)
+
+	if p.outcomes != nil {
+		p.outcomes.report(idempotencyKey, Outcome{
+			Event:     models.EventRefunded,
+			Reference: reference,
+			Amount:    req.ModificationAmount,
+		})
 	}
 
-	return &response, nil
+	return response, nil
 }
 
-// Cancel cancels a payment
-func (p *Payment) Cancel(reference string, req *models.CancelModificationRequest) (*models.AdjustmentResponse, error) {
+// Cancel cancels a payment. Like captures and refunds, it is sent with the idempotency key set
+// with WithIdempotencyKey, or a new key that is sent with every attempt, so a retried
+// cancellation is applied once.
+func (p *Payment) Cancel(reference models.Reference, req models.CancelModificationRequest, opts ...CallOption) (*models.AdjustmentResponse, error) {
+	reference, err := p.resolveReference(reference)
+	if err != nil {
+		return nil, err
+	}
+
 	endpoint := fmt.Sprintf("/epayment/v1/payments/%s/cancel", reference)
 
-	body, _, err := p.client.DoRequest(http.MethodPost, endpoint, req, "")
+	cfg := p.call(opts)
+	payment, err := p.checkState(cfg, models.OperationCancel, reference)
 	if err != nil {
-		return nil, fmt.Errorf("failed to cancel payment: %w", err)
+		return nil, err
+	}
+	if p.needsApproval(models.OperationCancel) {
+		// The amount of a cancellation is what is still authorized and not captured
+		if payment == nil {
+			if payment, err = p.get(cfg, reference); err != nil {
+				return nil, err
+			}
+		}
+
+		released := models.Amount{Currency: payment.Amount.Currency}
+		if payment.Aggregate != nil {
+			released.Value = payment.Aggregate.Capturable().Value
+		}
+		if err := p.approve(cfg, ApprovalRequest{
+			Operation: models.OperationCancel,
+			Reference: reference,
+			Amount:    released,
+		}); err != nil {
+			return nil, err
+		}
 	}
 
-	var response models.AdjustmentResponse
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
+	idempotencyKey := cfg.operationKey()
+	response, resp, err := doJSON[models.AdjustmentResponse](p.client, cfg, http.MethodPost, endpoint, req, idempotencyKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cancel payment: %w", err)
+	}
+	response.TraceID = TraceID(resp.header)
+
+	if p.outcomes != nil {
+		p.outcomes.report(reference.String(), Outcome{
+			Event:     models.EventCancelled,
+			Reference: reference,
+			Amount:    response.Amount,
+		})
 	}
 
-	return &response, nil
+	return response, nil
 }
 
-// ForceApprove force approves a payment (only available in test environment)
-func (p *Payment) ForceApprove(reference string, customerPhoneNumber string) error {
+// ForceApprove force approves a payment (only available in test environment). The reason,
+// e.g. the test case, is required; it is logged and recorded in the audit trail with the call.
+func (p *Payment) ForceApprove(reference models.Reference, customerPhoneNumber, reason string, opts ...CallOption) error {
 	if !p.client.TestMode {
 		return fmt.Errorf("force approve is only available in test environment")
 	}
+	if strings.TrimSpace(reason) == "" {
+		return fmt.Errorf("force approve requires a reason for the audit trail")
+	}
+
+	reference, err := p.resolveReference(reference)
+	if err != nil {
+		return err
+	}
 
 	endpoint := fmt.Sprintf("/epayment/v1/test/payments/%s/approve", reference)
 
@@ -146,8 +516,10 @@ func (p *Payment) ForceApprove(reference string, customerPhoneNumber string) err
 	}{}
 	reqBody.Customer.PhoneNumber = customerPhoneNumber
 
-	idempotencyKey := uuid.New().String()
-	_, _, err := p.client.DoRequest(http.MethodPost, endpoint, reqBody, idempotencyKey)
+	p.client.logf("Force approving payment %s on the test endpoint: %s", reference, reason)
+
+	cfg := p.call(append(opts, WithReason(reason)))
+	_, err = p.client.do(cfg, http.MethodPost, endpoint, reqBody, cfg.operationKey())
 	if err != nil {
 		return fmt.Errorf("failed to force approve payment: %w", err)
 	}
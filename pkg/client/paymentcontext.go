@@ -0,0 +1,53 @@
+package client
+
+import (
+	"sync"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// PaymentContextStore keeps the context of created payments by reference, so webhook events
+// can be joined with it, see webhooks.Enrich. Implementations must be safe for concurrent
+// use, and should be persistent since events arrive after the creating process may be gone.
+type PaymentContextStore interface {
+	// Save stores the context of a created payment
+	Save(paymentContext *models.PaymentContext) error
+	// Load returns the context of a payment, or nil if none was stored
+	Load(reference models.Reference) (*models.PaymentContext, error)
+}
+
+// MemoryPaymentContextStore is an in-memory PaymentContextStore, suitable for tests and single processes
+type MemoryPaymentContextStore struct {
+	mu       sync.Mutex
+	contexts map[models.Reference]*models.PaymentContext
+}
+
+// NewMemoryPaymentContextStore creates an empty in-memory payment context store
+func NewMemoryPaymentContextStore() *MemoryPaymentContextStore {
+	return &MemoryPaymentContextStore{contexts: make(map[models.Reference]*models.PaymentContext)}
+}
+
+// Save stores the context of a created payment
+func (s *MemoryPaymentContextStore) Save(paymentContext *models.PaymentContext) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.contexts[paymentContext.Reference] = paymentContext
+	return nil
+}
+
+// Load returns the context of a payment, or nil if none was stored
+func (s *MemoryPaymentContextStore) Load(reference models.Reference) (*models.PaymentContext, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.contexts[reference], nil
+}
+
+// WithPaymentContextStore saves the context of every payment created by the handler, such
+// as its order ID, user flow and description, for webhooks.Enrich to join with its events
+func WithPaymentContextStore(store PaymentContextStore) PaymentOption {
+	return func(p *Payment) {
+		p.contexts = store
+	}
+}
@@ -0,0 +1,108 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// ErrPollTimeout is returned by Poll when the payment does not reach the awaited state in time
+var ErrPollTimeout = errors.New("timed out polling payment")
+
+// PollOptions configures how Poll checks a payment. The delay between checks starts at
+// Interval and grows by Multiplier up to MaxInterval, so long waits cost few requests
+// and stay well within the rate limits of the API.
+type PollOptions struct {
+	Interval    time.Duration // Delay before the second check
+	MaxInterval time.Duration // Upper bound for the delay between checks
+	Multiplier  float64       // Factor applied to the delay after every check; values below 1 mean 1
+	Timeout     time.Duration // Total time to poll; zero means until the call's context is done
+
+	// Until reports whether polling is done; nil means until the payment leaves CREATED
+	Until func(*models.GetPaymentResponse) bool
+}
+
+var (
+	// PollCheckout suits a user waiting at checkout: frequent checks for a few minutes,
+	// covering the time the user has to act on the payment
+	PollCheckout = PollOptions{Interval: 2 * time.Second, MaxInterval: 5 * time.Second, Multiplier: 1.5, Timeout: 10 * time.Minute}
+
+	// PollBackoffice suits back-office tooling waiting on a payment: checks slow down
+	// to once a minute, for up to an hour
+	PollBackoffice = PollOptions{Interval: 10 * time.Second, MaxInterval: time.Minute, Multiplier: 2, Timeout: time.Hour}
+
+	// PollReconciliation suits batch jobs reconciling many payments: checks slow down
+	// to once every 15 minutes, for up to a day
+	PollReconciliation = PollOptions{Interval: time.Minute, MaxInterval: 15 * time.Minute, Multiplier: 2, Timeout: 24 * time.Hour}
+)
+
+// pollPresets are the presets selectable by name
+var pollPresets = map[string]PollOptions{
+	"checkout":       PollCheckout,
+	"backoffice":     PollBackoffice,
+	"reconciliation": PollReconciliation,
+}
+
+// PollPreset returns the preset with the given name: "checkout", "backoffice" or "reconciliation"
+func PollPreset(name string) (PollOptions, error) {
+	preset, ok := pollPresets[name]
+	if !ok {
+		return PollOptions{}, fmt.Errorf("unknown poll preset %q", name)
+	}
+	return preset, nil
+}
+
+// next returns the delay following the given one
+func (o PollOptions) next(delay time.Duration) time.Duration {
+	if o.Multiplier > 1 {
+		delay = time.Duration(float64(delay) * o.Multiplier)
+	}
+	if o.MaxInterval > 0 && delay > o.MaxInterval {
+		delay = o.MaxInterval
+	}
+	return delay
+}
+
+// Poll checks a payment until it is done according to the poll options, by default until
+// the user has acted on it or it expired, and returns its last state. When the timeout
+// passes first, the last state is returned with an error matching ErrPollTimeout. Call
+// options apply to every check; cancel the context passed with WithContext to stop polling.
+func (p *Payment) Poll(reference models.Reference, options PollOptions, opts ...CallOption) (*models.GetPaymentResponse, error) {
+	until := options.Until
+	if until == nil {
+		until = func(payment *models.GetPaymentResponse) bool {
+			return payment.State != models.PaymentStateCreated
+		}
+	}
+
+	var deadline time.Time
+	if options.Timeout > 0 {
+		deadline = time.Now().Add(options.Timeout)
+	}
+	cfg := newCallConfig(callConfig{}, opts)
+
+	delay := options.Interval
+	if delay <= 0 {
+		delay = PollCheckout.Interval
+	}
+
+	for {
+		payment, err := p.Get(reference, opts...)
+		if err != nil {
+			return nil, err
+		}
+		if until(payment) {
+			return payment, nil
+		}
+
+		if !deadline.IsZero() && !time.Now().Add(delay).Before(deadline) {
+			return payment, fmt.Errorf("%w %s after %s: state is %s", ErrPollTimeout, reference, options.Timeout, payment.State)
+		}
+		if err := cfg.wait(delay); err != nil {
+			return payment, err
+		}
+		delay = options.next(delay)
+	}
+}
@@ -0,0 +1,107 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// CredentialSource looks up the credentials of merchants, e.g. in the database of a marketplace
+type CredentialSource interface {
+	MerchantCredentials(ctx context.Context, msn string) (Credentials, error)
+}
+
+// CredentialSourceFunc adapts a function to the CredentialSource interface
+type CredentialSourceFunc func(ctx context.Context, msn string) (Credentials, error)
+
+// MerchantCredentials calls f(ctx, msn)
+func (f CredentialSourceFunc) MerchantCredentials(ctx context.Context, msn string) (Credentials, error) {
+	return f(ctx, msn)
+}
+
+// Pool manages one Client per merchant, keyed by MSN, for marketplaces and platforms calling
+// the API for many merchants with their own keys. Clients are created on first use from the
+// credentials source, configured with the pool's options, and keep their own access token,
+// throttling and circuit breaker state. Concurrent first uses of a merchant share one client.
+type Pool struct {
+	source CredentialSource
+	opts   []Option
+
+	mu      sync.Mutex
+	clients map[string]*Client
+	group   singleflight.Group
+}
+
+// NewPool creates a client pool looking up credentials in source and configuring every
+// client with opts. Options are applied per client, so state they set up, such as a circuit
+// breaker, is not shared; pass shared resources such as an HTTP client or a TokenStore.
+func NewPool(source CredentialSource, opts ...Option) *Pool {
+	return &Pool{
+		source:  source,
+		opts:    opts,
+		clients: make(map[string]*Client),
+	}
+}
+
+// Client returns the client of the merchant, creating it if it does not exist. Lookup
+// failures are not cached, so the next call tries again.
+func (p *Pool) Client(ctx context.Context, msn string) (*Client, error) {
+	p.mu.Lock()
+	c, ok := p.clients[msn]
+	p.mu.Unlock()
+	if ok {
+		return c, nil
+	}
+
+	v, err, _ := p.group.Do(msn, func() (interface{}, error) {
+		p.mu.Lock()
+		c, ok := p.clients[msn]
+		p.mu.Unlock()
+		if ok {
+			return c, nil
+		}
+
+		credentials, err := p.source.MerchantCredentials(ctx, msn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up credentials of merchant %s: %w", msn, err)
+		}
+		if credentials.MSN == "" {
+			credentials.MSN = msn
+		}
+		c = New(credentials, p.opts...)
+
+		p.mu.Lock()
+		p.clients[msn] = c
+		p.mu.Unlock()
+		return c, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Client), nil
+}
+
+// Remove drops the client of a merchant, e.g. when it is offboarded or its credentials
+// changed; the next call for the merchant creates a new client
+func (p *Pool) Remove(msn string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	delete(p.clients, msn)
+}
+
+// Merchants returns the MSNs of the merchants that have a client, in ascending order
+func (p *Pool) Merchants() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	msns := make([]string, 0, len(p.clients))
+	for msn := range p.clients {
+		msns = append(msns, msn)
+	}
+	sort.Strings(msns)
+	return msns
+}
@@ -0,0 +1,34 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// RawResponse is the unprocessed response to a request made with Raw
+type RawResponse struct {
+	StatusCode int         // HTTP status code; 0 if no response was received
+	Header     http.Header // Response headers; nil if no response was received
+	Body       []byte      // Response body, decompressed but otherwise as received
+}
+
+// Raw sends a request to an endpoint the SDK does not model yet, with the same access token,
+// subscription key, MSN and system headers as any other request, and returns the response
+// as received. The body is sent like with DoRequest; headers are added to the request and
+// override the client's own. An Idempotency-Key header makes a modifying request retryable.
+//
+// The response is returned also when the request fails, so the status code and body of error
+// responses can be inspected; for non-2xx responses the error is an *APIError.
+func (c *Client) Raw(ctx context.Context, method, path string, body interface{}, header http.Header, opts ...CallOption) (*RawResponse, error) {
+	cfg := newCallConfig(callConfig{ctx: ctx}, opts)
+	if len(header) > 0 {
+		merged := header.Clone()
+		for name, values := range cfg.requestHeader {
+			merged[name] = values
+		}
+		cfg.requestHeader = merged
+	}
+
+	resp, err := c.do(cfg, method, path, body, header.Get("Idempotency-Key"))
+	return &RawResponse{StatusCode: resp.statusCode, Header: resp.header, Body: resp.body}, err
+}
@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+)
+
+// RequestClass groups API requests that share a retry policy
+type RequestClass int
+
+const (
+	// RequestClassAuth covers access token requests. They never move money and can be retried aggressively.
+	RequestClassAuth RequestClass = iota
+	// RequestClassQuery covers read-only requests (GET) and idempotent deletes
+	RequestClassQuery
+	// RequestClassModification covers requests that create or modify payments. They are only
+	// retried when they carry an idempotency key, which is preserved across attempts.
+	RequestClassModification
+)
+
+// RetryPolicy controls how failed requests are retried. Requests are retried on network
+// errors and 5xx responses; other errors are returned immediately.
+//
+// The delay before retry n is Backoff * Multiplier^(n-1), capped at MaxBackoff. With Jitter
+// set, each delay is randomly reduced by up to that fraction, so clients recovering from
+// the same outage do not retry in lockstep.
+type RetryPolicy struct {
+	MaxAttempts int           // Total number of attempts including the first; values below 2 disable retries
+	Backoff     time.Duration // Delay before the first retry
+	MaxBackoff  time.Duration // Upper bound of the delay; zero means no bound
+	Multiplier  float64       // Growth factor of the delay per retry; values below 1 keep the delay constant
+	Jitter      float64       // Fraction (0-1) of the delay that is randomized
+}
+
+// NoRetry is a retry policy that never retries
+var NoRetry = RetryPolicy{MaxAttempts: 1}
+
+// DefaultRetryPolicy returns a policy with exponential backoff and jitter suitable for read-only requests
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 3,
+		Backoff:     500 * time.Millisecond,
+		MaxBackoff:  5 * time.Second,
+		Multiplier:  2,
+		Jitter:      0.5,
+	}
+}
+
+// DefaultAuthRetryPolicy returns an aggressive policy suitable for access token requests
+func DefaultAuthRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 5,
+		Backoff:     200 * time.Millisecond,
+		MaxBackoff:  2 * time.Second,
+		Multiplier:  2,
+		Jitter:      0.5,
+	}
+}
+
+// DefaultModificationRetryPolicy returns a conservative policy suitable for money-moving requests
+func DefaultModificationRetryPolicy() RetryPolicy {
+	return RetryPolicy{
+		MaxAttempts: 2,
+		Backoff:     time.Second,
+		Multiplier:  2,
+		Jitter:      0.2,
+	}
+}
+
+// delay returns the time to wait before the given retry, where retry 1 follows the first attempt
+func (p RetryPolicy) delay(retry int) time.Duration {
+	delay := float64(p.Backoff)
+	if p.Multiplier > 1 && retry > 1 {
+		delay *= math.Pow(p.Multiplier, float64(retry-1))
+	}
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		jitter := math.Min(p.Jitter, 1)
+		delay -= delay * jitter * rand.Float64()
+	}
+
+	return time.Duration(delay)
+}
+
+// SetRetryPolicy sets the retry policy used for a class of requests. By default no requests are retried.
+func (c *Client) SetRetryPolicy(class RequestClass, policy RetryPolicy) {
+	c.retryPolicies[class] = policy
+}
+
+// RetryPolicy returns the retry policy used for a class of requests
+func (c *Client) RetryPolicy(class RequestClass) RetryPolicy {
+	return c.retryPolicies[class]
+}
+
+// classifyRequest determines the request class of an API request and whether it may be retried
+func classifyRequest(method, idempotencyKey string) (RequestClass, bool) {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		return RequestClassQuery, true
+	default:
+		// Without an idempotency key a retry could execute the operation twice
+		return RequestClassModification, idempotencyKey != ""
+	}
+}
+
+// shouldRetry reports whether a failed attempt with the given status code may be retried.
+// Rate-limited attempts are handled by the throttle policy instead.
+func shouldRetry(statusCode int, err error) bool {
+	if statusCode == http.StatusTooManyRequests {
+		return false
+	}
+	return IsRetryable(err)
+}
+
+// RetryableError is implemented by errors that know whether retrying the failed call may
+// succeed, so application-level retry loops can rely on IsRetryable
+type RetryableError interface {
+	error
+	Retryable() bool
+}
+
+// IsRetryable reports whether retrying a failed call may succeed: network errors, timeouts,
+// rate limits (429), request timeouts (408) and server errors (5xx) are transient, while
+// validation errors and other 4xx responses fail the same way every time. Errors the SDK
+// raises before sending, such as ErrReadOnly or ErrAmountCeiling, are permanent, except
+// ErrCircuitOpen and ErrLoadShed. Retry modifying calls with the same idempotency key, see
+// WithIdempotencyKey.
+func IsRetryable(err error) bool {
+	if err == nil || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var retryable RetryableError
+	if errors.As(err, &retryable) {
+		return retryable.Retryable()
+	}
+
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrLoadShed) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// Retryable reports whether the status code of the response is transient
+func (e *APIError) Retryable() bool {
+	return e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests ||
+		e.StatusCode >= http.StatusInternalServerError
+}
+
+// Retryable reports true, since the connection broke while the response was read
+func (e *ReadError) Retryable() bool {
+	return true
+}
+
+// Retryable reports false, since the same response would fail to decode again
+func (e *DecodeError) Retryable() bool {
+	return false
+}
@@ -0,0 +1,148 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// Sentinel errors for common API error conditions. API errors match them with errors.Is, so
+// callers can branch on a condition without comparing messages:
+//
+//	if errors.Is(err, client.ErrPaymentNotFound) { ... }
+//
+// Conditions that share a status code are told apart by the endpoint and the problem details.
+var (
+	// ErrUnauthorized means the credentials or the access token were rejected (401)
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrForbidden means the credentials lack access to the resource, e.g. another MSN (403)
+	ErrForbidden = errors.New("forbidden")
+	// ErrNotFound means the resource does not exist (404)
+	ErrNotFound = errors.New("not found")
+	// ErrPaymentNotFound means the payment does not exist (404 from the ePayment API)
+	ErrPaymentNotFound = errors.New("payment not found")
+	// ErrConflict means the request conflicts with the state of the resource (409)
+	ErrConflict = errors.New("conflict")
+	// ErrDuplicateReference means a payment with the reference already exists (409 on create)
+	ErrDuplicateReference = errors.New("duplicate payment reference")
+	// ErrInvalidRequest means the API rejected the request as invalid (400)
+	ErrInvalidRequest = errors.New("invalid request")
+	// ErrInvalidAmount means the amount of the request was rejected
+	ErrInvalidAmount = errors.New("invalid amount")
+	// ErrInvalidPaymentState means the operation is not allowed in the current state of the payment
+	ErrInvalidPaymentState = errors.New("operation not allowed in payment state")
+	// ErrCaptureExceedsAuthorization means a capture exceeds the amount left to capture
+	ErrCaptureExceedsAuthorization = errors.New("capture exceeds authorized amount")
+	// ErrRefundExceedsCapture means a refund exceeds the amount left to refund
+	ErrRefundExceedsCapture = errors.New("refund exceeds captured amount")
+	// ErrRateLimited means the request was throttled (429)
+	ErrRateLimited = errors.New("rate limited")
+	// ErrServerError means the API failed to handle the request (5xx)
+	ErrServerError = errors.New("server error")
+)
+
+// ErrorCondition describes a sentinel error for documentation, e.g. in an error catalog
+type ErrorCondition struct {
+	Name   string // Name of the sentinel error variable, e.g. ErrPaymentNotFound
+	Err    error  // The sentinel error
+	Status int    // HTTP status code of the responses matching the error
+}
+
+// errorConditions are the sentinel errors API errors can match
+var errorConditions = []ErrorCondition{
+	{"ErrUnauthorized", ErrUnauthorized, http.StatusUnauthorized},
+	{"ErrForbidden", ErrForbidden, http.StatusForbidden},
+	{"ErrNotFound", ErrNotFound, http.StatusNotFound},
+	{"ErrPaymentNotFound", ErrPaymentNotFound, http.StatusNotFound},
+	{"ErrConflict", ErrConflict, http.StatusConflict},
+	{"ErrDuplicateReference", ErrDuplicateReference, http.StatusConflict},
+	{"ErrInvalidRequest", ErrInvalidRequest, http.StatusBadRequest},
+	{"ErrInvalidAmount", ErrInvalidAmount, http.StatusBadRequest},
+	{"ErrInvalidPaymentState", ErrInvalidPaymentState, http.StatusBadRequest},
+	{"ErrCaptureExceedsAuthorization", ErrCaptureExceedsAuthorization, http.StatusBadRequest},
+	{"ErrRefundExceedsCapture", ErrRefundExceedsCapture, http.StatusBadRequest},
+	{"ErrRateLimited", ErrRateLimited, http.StatusTooManyRequests},
+	{"ErrServerError", ErrServerError, http.StatusInternalServerError},
+}
+
+// ErrorConditions returns the sentinel errors API errors can match, with the status code of
+// the responses matching them. ErrServerError matches any 5xx status.
+func ErrorConditions() []ErrorCondition {
+	return append([]ErrorCondition(nil), errorConditions...)
+}
+
+// Is reports whether the API error matches a sentinel error
+func (e *APIError) Is(target error) bool {
+	for _, sentinel := range e.sentinels() {
+		if target == sentinel {
+			return true
+		}
+	}
+	return false
+}
+
+// sentinels returns the sentinel errors the API error matches
+func (e *APIError) sentinels() []error {
+	payments := strings.HasPrefix(e.endpoint, "/epayment/")
+
+	switch status := e.StatusCode; {
+	case status == http.StatusUnauthorized:
+		return []error{ErrUnauthorized}
+	case status == http.StatusForbidden:
+		return []error{ErrForbidden}
+	case status == http.StatusNotFound:
+		if payments {
+			return []error{ErrNotFound, ErrPaymentNotFound}
+		}
+		return []error{ErrNotFound}
+	case status == http.StatusConflict:
+		if payments && e.method == http.MethodPost && e.endpoint == "/epayment/v1/payments" {
+			return []error{ErrConflict, ErrDuplicateReference}
+		}
+		return []error{ErrConflict}
+	case status == http.StatusTooManyRequests:
+		return []error{ErrRateLimited}
+	case status >= http.StatusInternalServerError:
+		return []error{ErrServerError}
+	case status == http.StatusBadRequest:
+		return append([]error{ErrInvalidRequest}, e.badRequestSentinels(payments)...)
+	}
+	return nil
+}
+
+// badRequestSentinels narrows down a 400 response of the ePayment API using the problem
+// details, which carry no stable codes for these conditions
+func (e *APIError) badRequestSentinels(payments bool) []error {
+	if !payments || e.Problem == nil {
+		return nil
+	}
+
+	parts := []string{e.Problem.Type, e.Problem.Code, e.Problem.Title, e.Problem.Detail}
+	for _, detail := range e.Problem.ExtraDetails {
+		parts = append(parts, detail.Name, detail.Reason)
+	}
+	text := strings.ToLower(strings.Join(parts, " "))
+	exceeds := containsAny(text, "exceed", "too high", "too large", "available", "more than")
+
+	switch {
+	case strings.HasSuffix(e.endpoint, "/capture") && exceeds:
+		return []error{ErrInvalidAmount, ErrCaptureExceedsAuthorization}
+	case strings.HasSuffix(e.endpoint, "/refund") && exceeds:
+		return []error{ErrInvalidAmount, ErrRefundExceedsCapture}
+	case strings.Contains(text, "state"):
+		return []error{ErrInvalidPaymentState}
+	case strings.Contains(text, "amount"):
+		return []error{ErrInvalidAmount}
+	}
+	return nil
+}
+
+// containsAny reports whether s contains any of the substrings
+func containsAny(s string, substrs ...string) bool {
+	for _, substr := range substrs {
+		if strings.Contains(s, substr) {
+			return true
+		}
+	}
+	return false
+}
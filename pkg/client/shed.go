@@ -0,0 +1,119 @@
+package client
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Priority ranks API calls for load shedding. The zero value is PriorityNormal.
+type Priority int
+
+const (
+	// PriorityBatch is for background work that can wait, such as reconciliation and reports
+	PriorityBatch Priority = -1
+	// PriorityNormal is the priority of calls made without WithPriority
+	PriorityNormal Priority = 0
+	// PriorityCritical is for calls a user is waiting for, such as creating and capturing checkout payments
+	PriorityCritical Priority = 1
+)
+
+// String returns the name of the priority
+func (p Priority) String() string {
+	switch {
+	case p < PriorityNormal:
+		return "batch"
+	case p > PriorityNormal:
+		return "critical"
+	default:
+		return "normal"
+	}
+}
+
+// DefaultSheddingCooldown is how long after a rate-limited response the API counts as under pressure by default
+const DefaultSheddingCooldown = 30 * time.Second
+
+// LoadSheddingPolicy controls which calls are shed while the API is under pressure: after a
+// rate-limited response (429), and while the circuit breaker counts failures or is open.
+// Calls below MinPriority then fail with ErrLoadShed without being sent, leaving the
+// capacity to higher-priority calls, and low-priority calls that are rate limited do not wait
+// for the Retry-After period.
+type LoadSheddingPolicy struct {
+	MinPriority Priority      // Lowest priority sent while the API is under pressure
+	Cooldown    time.Duration // How long after a rate-limited response the pressure lasts; zero means DefaultSheddingCooldown
+}
+
+// WithPriority sets the priority of a call, used to shed low-priority calls first when
+// the API is under pressure, see SetLoadShedding
+func WithPriority(priority Priority) CallOption {
+	return func(cfg *callConfig) {
+		cfg.priority = priority
+	}
+}
+
+// SetLoadShedding enables load shedding of low-priority calls while the API is under pressure
+func (c *Client) SetLoadShedding(policy LoadSheddingPolicy) {
+	if policy.Cooldown <= 0 {
+		policy.Cooldown = DefaultSheddingCooldown
+	}
+	c.shedder = &loadShedder{policy: policy}
+}
+
+// Shedding reports whether the API is under pressure and low-priority calls are being shed
+func (c *Client) Shedding() bool {
+	return c.shedder != nil && c.underPressure()
+}
+
+// loadShedder tracks rate limiting to decide which calls to shed
+type loadShedder struct {
+	policy LoadSheddingPolicy
+
+	mu             sync.Mutex
+	throttledUntil time.Time
+}
+
+// throttled records a rate-limited response; the pressure lasts for the cooldown or the
+// Retry-After period, whichever is longer
+func (s *loadShedder) throttled(retryAfter time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if retryAfter < s.policy.Cooldown {
+		retryAfter = s.policy.Cooldown
+	}
+	if until := time.Now().Add(retryAfter); until.After(s.throttledUntil) {
+		s.throttledUntil = until
+	}
+}
+
+// isThrottled reports whether a rate-limited response was received recently
+func (s *loadShedder) isThrottled() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return time.Now().Before(s.throttledUntil)
+}
+
+// underPressure reports whether the API is rate limiting or failing
+func (c *Client) underPressure() bool {
+	return c.shedder.isThrottled() || (c.breaker != nil && c.breaker.degraded())
+}
+
+// shed returns a LoadShedError if a call of the priority is shed
+func (c *Client) shed(cfg callConfig, method, endpoint string) error {
+	if c.shedder == nil || cfg.priority >= c.shedder.policy.MinPriority || !c.underPressure() {
+		return nil
+	}
+	return &LoadShedError{Method: method, Endpoint: endpoint, Priority: cfg.priority}
+}
+
+// shedThrottled records a rate-limited response and reports whether the call should return
+// the error instead of waiting for the Retry-After period
+func (c *Client) shedThrottled(cfg callConfig, resp *response, wait time.Duration) bool {
+	if c.shedder == nil || resp.statusCode != http.StatusTooManyRequests {
+		return false
+	}
+
+	c.shedder.throttled(wait)
+	return cfg.priority < c.shedder.policy.MinPriority
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"fmt"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// WithStateCheck verifies that a capture, refund or cancellation is allowed in the state of the
+// payment before the request is sent, following the documented state machine (see
+// models.IsOperationAllowed), and fails with an error matching ErrInvalidPaymentState otherwise.
+// The payment is fetched from the API first, which adds a request to every operation.
+func WithStateCheck() PaymentOption {
+	return func(p *Payment) {
+		p.stateCheck = true
+	}
+}
+
+// checkState fetches a payment and verifies that the operation is allowed in its state. It
+// returns the fetched payment, or nil if the state check is disabled.
+func (p *Payment) checkState(cfg callConfig, operation models.PaymentOperation, reference models.Reference) (*models.GetPaymentResponse, error) {
+	if !p.stateCheck {
+		return nil, nil
+	}
+
+	payment, err := p.get(cfg, reference)
+	if err != nil {
+		return nil, err
+	}
+	if err := operationAllowed(operation, payment); err != nil {
+		return nil, err
+	}
+	return payment, nil
+}
+
+// operationAllowed returns an error matching ErrInvalidPaymentState unless the operation is
+// allowed in the state of the payment
+func operationAllowed(operation models.PaymentOperation, payment *models.GetPaymentResponse) error {
+	if models.IsOperationAllowed(payment.State, operation) {
+		return nil
+	}
+	return fmt.Errorf("%w: %s not allowed for payment %s in state %s",
+		ErrInvalidPaymentState, operation, payment.Reference, payment.State)
+}
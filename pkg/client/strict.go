@@ -0,0 +1,76 @@
+package client
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// errRedirectRefused is returned for API responses redirecting a strict client
+var errRedirectRefused = errors.New("redirects are refused in strict mode")
+
+// NewStrict creates a client with all hardening enabled, so a security review can check a
+// single constructor. On top of what every client does, such as validating requests and
+// redacting credentials from errors and audit records, a strict client
+//   - only talks to an HTTPS base URL,
+//   - requires TLS 1.2 or later and certificate verification,
+//   - refuses redirects, which would carry the subscription key to another host,
+//   - logs no diagnostics unless given a logger with WithLogger, and never response bodies.
+//
+// The options are applied first; NewStrict fails if one of them cannot be applied or they
+// leave the client in a state it cannot harden, e.g. an HTTP base URL or a custom transport
+// it cannot configure.
+func NewStrict(credentials Credentials, opts ...Option) (*Client, error) {
+	c := New(credentials, opts...)
+	if c.optionErr != nil {
+		return nil, fmt.Errorf("failed to create strict client: %w", c.optionErr)
+	}
+	if err := c.harden(); err != nil {
+		return nil, fmt.Errorf("failed to create strict client: %w", err)
+	}
+	return c, nil
+}
+
+// harden enables strict mode on the client
+func (c *Client) harden() error {
+	u, err := url.Parse(c.BaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse base URL: %w", err)
+	}
+	if u.Scheme != "https" {
+		return fmt.Errorf("base URL %q does not use HTTPS", c.BaseURL)
+	}
+
+	// Copy the HTTP client and its transport, which may be shared with other code
+	httpClient := *c.client
+	t, ok := cloneTransport(httpClient.Transport)
+	if !ok {
+		return fmt.Errorf("cannot harden HTTP transport of type %T", httpClient.Transport)
+	}
+
+	config := t.TLSClientConfig
+	if config == nil {
+		config = &tls.Config{}
+	} else {
+		config = config.Clone()
+	}
+	if config.InsecureSkipVerify {
+		return fmt.Errorf("TLS certificate verification is disabled")
+	}
+	if config.MinVersion < tls.VersionTLS12 {
+		config.MinVersion = tls.VersionTLS12
+	}
+	t.TLSClientConfig = config
+
+	httpClient.Transport = t
+	httpClient.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return errRedirectRefused
+	}
+	c.client = &httpClient
+	c.ownTransport = t
+
+	c.strict = true
+	return nil
+}
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+)
+
+// SubscriptionKeyRotation describes a request that was rejected with the primary subscription
+// key, after which the client switched to the secondary key
+type SubscriptionKeyRotation struct {
+	Method     string // HTTP method of the rejected request
+	Endpoint   string // Endpoint of the rejected request
+	StatusCode int    // 401 or 403
+	Err        error  // Error the request was rejected with
+}
+
+// SetSecondarySubscriptionKey sets the subscription key used when the API rejects the primary
+// one, for rolling over keys without downtime: regenerate the primary key on the portal while
+// the secondary is valid, then update the primary key in the configuration. Requests rejected
+// with the primary key are sent once more with the secondary, and later requests use the
+// secondary until the primary key changes, e.g. through a CredentialProvider.
+func (c *Client) SetSecondarySubscriptionKey(key string) {
+	c.subKeyMu.Lock()
+	defer c.subKeyMu.Unlock()
+
+	c.secondarySubKey = key
+	c.rejectedSubKey = ""
+}
+
+// OnSubscriptionKeyRotation registers a callback that is called when the API first rejects the
+// primary subscription key, e.g. to alert that the configured key needs to be rotated
+func (c *Client) OnSubscriptionKeyRotation(fn func(SubscriptionKeyRotation)) {
+	c.onSubKeyRotation = fn
+}
+
+// UsingSecondarySubscriptionKey reports whether requests use the secondary subscription key,
+// because the API rejected the primary one
+func (c *Client) UsingSecondarySubscriptionKey() bool {
+	creds := c.lastCredentials()
+	return c.subscriptionKey(creds) != creds.SubscriptionKey
+}
+
+// subscriptionKey returns the subscription key to send with the credentials: the secondary
+// key if the API rejected their primary key, else the primary key
+func (c *Client) subscriptionKey(creds Credentials) string {
+	c.subKeyMu.Lock()
+	defer c.subKeyMu.Unlock()
+
+	if c.secondarySubKey != "" && c.rejectedSubKey != "" && c.rejectedSubKey == creds.SubscriptionKey {
+		return c.secondarySubKey
+	}
+	return creds.SubscriptionKey
+}
+
+// rotateSubscriptionKey switches to the secondary subscription key if err rejects the primary
+// one, and reports whether the request should be sent again. The rotation callback is called
+// once per rejected primary key.
+func (c *Client) rotateSubscriptionKey(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || !subscriptionKeyRejected(apiErr) {
+		return false
+	}
+	primary := c.lastCredentials().SubscriptionKey
+
+	c.subKeyMu.Lock()
+	if c.secondarySubKey == "" || primary == "" {
+		c.subKeyMu.Unlock()
+		return false
+	}
+	rotated := c.rejectedSubKey != primary
+	c.rejectedSubKey = primary
+	c.subKeyMu.Unlock()
+
+	if rotated && c.onSubKeyRotation != nil {
+		c.onSubKeyRotation(SubscriptionKeyRotation{
+			Method:     apiErr.method,
+			Endpoint:   apiErr.endpoint,
+			StatusCode: apiErr.StatusCode,
+			Err:        err,
+		})
+	}
+	return true
+}
+
+// subscriptionKeyRejected reports whether an error response rejects the subscription key.
+// The API gateway answers an invalid key with 401 and an inactive subscription with 403,
+// with a message naming the subscription key.
+func subscriptionKeyRejected(apiErr *APIError) bool {
+	if apiErr.StatusCode != http.StatusUnauthorized && apiErr.StatusCode != http.StatusForbidden {
+		return false
+	}
+	return bytes.Contains(bytes.ToLower(apiErr.body), []byte("subscription key"))
+}
@@ -0,0 +1,67 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// TerminateFilter selects the payments TerminateAllOpen cancels. Payments are found in the
+// payment index by a metadata entry, e.g. the ID of a POS station or a sale campaign.
+type TerminateFilter struct {
+	MetadataKey   string                                // Metadata key the payments were created with
+	MetadataValue string                                // Metadata value the payments were created with
+	Match         func(*models.GetPaymentResponse) bool // Optional further selection, e.g. by amount
+}
+
+// TerminateResult reports what TerminateAllOpen did with each payment found
+type TerminateResult struct {
+	Cancelled []models.Reference         // Open payments that were cancelled
+	Skipped   []models.Reference         // Payments that were not open or did not match
+	Failed    map[models.Reference]error // Payments that could not be cancelled
+}
+
+// TerminateAllOpen cancels every open payment matching the filter, e.g. the long-living and QR
+// payments of a POS station that is decommissioned or a sale campaign that ends. Open
+// payments are those in CREATED, not yet acted upon by a user. They are cancelled with
+// CancelTransactionOnly, so a payment the user authorizes in the meantime is left for the
+// merchant to capture or cancel. It requires a payment index, see WithPaymentIndex.
+//
+// All matching payments are attempted; the error joins the failures, which are listed by
+// reference in the result.
+func (p *Payment) TerminateAllOpen(ctx context.Context, filter TerminateFilter, opts ...CallOption) (*TerminateResult, error) {
+	if filter.MetadataKey == "" {
+		return nil, fmt.Errorf("terminating payments requires a metadata key to select them")
+	}
+
+	opts = append([]CallOption{WithContext(ctx)}, opts...)
+	payments, err := p.FindPaymentsByMetadata(filter.MetadataKey, filter.MetadataValue, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find payments to terminate: %w", err)
+	}
+
+	result := &TerminateResult{Failed: make(map[models.Reference]error)}
+	var errs []error
+	for _, payment := range payments {
+		if err := ctx.Err(); err != nil {
+			return result, errors.Join(append(errs, err)...)
+		}
+
+		if payment.State != models.PaymentStateCreated || (filter.Match != nil && !filter.Match(payment)) {
+			result.Skipped = append(result.Skipped, payment.Reference)
+			continue
+		}
+
+		_, err := p.Cancel(payment.Reference, models.CancelModificationRequest{CancelTransactionOnly: true}, opts...)
+		if err != nil {
+			result.Failed[payment.Reference] = err
+			errs = append(errs, fmt.Errorf("payment %s: %w", payment.Reference, err))
+			continue
+		}
+		result.Cancelled = append(result.Cancelled, payment.Reference)
+	}
+
+	return result, errors.Join(errs...)
+}
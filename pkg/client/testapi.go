@@ -0,0 +1,134 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// TestRunMetadataKey is the metadata key used to tag payments created through the TestAPI
+const TestRunMetadataKey = "testRunId"
+
+// TestAPI provides helpers for integration tests running against the test environment.
+// Payments created through it are tracked so they can be cleaned up when the test run ends.
+type TestAPI struct {
+	payment *Payment
+
+	// RunID identifies the test run and is stored in the metadata of every payment created
+	RunID string
+
+	mu         sync.Mutex
+	references []models.Reference
+}
+
+// NewTestAPI creates a new test API handler
+func NewTestAPI(payment *Payment) *TestAPI {
+	return &TestAPI{
+		payment: payment,
+		RunID:   uuid.New().String(),
+	}
+}
+
+// Create initiates a new payment and tracks it for cleanup
+func (t *TestAPI) Create(req models.CreatePaymentRequest, opts ...CallOption) (*models.CreatePaymentResponse, error) {
+	if !t.payment.client.TestMode {
+		return nil, fmt.Errorf("test API is only available in test environment")
+	}
+
+	// Copy the metadata so the caller's map is left untouched
+	metadata := make(models.Metadata, len(req.Metadata)+1)
+	for key, value := range req.Metadata {
+		metadata[key] = value
+	}
+	metadata[TestRunMetadataKey] = t.RunID
+	req.Metadata = metadata
+
+	resp, err := t.payment.Create(req, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// The response carries the reference as sent, including any environment prefix
+	reference := resp.Reference
+	if reference == "" {
+		reference = req.Reference
+	}
+	t.Track(reference)
+
+	return resp, nil
+}
+
+// Track registers a payment created elsewhere so it is included in Cleanup
+func (t *TestAPI) Track(reference models.Reference) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.references = append(t.references, reference)
+}
+
+// ForceApprove force approves a payment, recording the reason and the test run in the audit trail
+func (t *TestAPI) ForceApprove(reference models.Reference, customerPhoneNumber, reason string, opts ...CallOption) error {
+	if strings.TrimSpace(reason) != "" {
+		reason = fmt.Sprintf("%s (test run %s)", reason, t.RunID)
+	}
+	return t.payment.ForceApprove(reference, customerPhoneNumber, reason, opts...)
+}
+
+// Cleanup cancels all tracked payments whose reference starts with referencePrefix and
+// that are still open (created or authorized). An empty prefix matches every tracked payment.
+// It returns the references that were cancelled. Payments that could not be cleaned up
+// remain tracked, so Cleanup can be called again.
+func (t *TestAPI) Cleanup(referencePrefix string) ([]models.Reference, error) {
+	if !t.payment.client.TestMode {
+		return nil, fmt.Errorf("test API is only available in test environment")
+	}
+
+	t.mu.Lock()
+	references := t.references
+	t.references = nil
+	t.mu.Unlock()
+
+	var cancelled, remaining []models.Reference
+	var errs []error
+
+	for _, reference := range references {
+		if !strings.HasPrefix(string(reference), referencePrefix) {
+			remaining = append(remaining, reference)
+			continue
+		}
+
+		payment, err := t.payment.Get(reference)
+		if err != nil {
+			remaining = append(remaining, reference)
+			errs = append(errs, err)
+			continue
+		}
+
+		if payment.State != models.PaymentStateCreated && payment.State != models.PaymentStateAuthorized {
+			// Already in a final state, nothing to clean up
+			continue
+		}
+
+		if _, err := t.payment.Cancel(reference, models.CancelModificationRequest{}); err != nil {
+			remaining = append(remaining, reference)
+			errs = append(errs, err)
+			continue
+		}
+
+		cancelled = append(cancelled, reference)
+	}
+
+	t.mu.Lock()
+	t.references = append(t.references, remaining...)
+	t.mu.Unlock()
+
+	if len(errs) > 0 {
+		return cancelled, fmt.Errorf("failed to clean up %d payments: %w", len(errs), errors.Join(errs...))
+	}
+
+	return cancelled, nil
+}
@@ -0,0 +1,106 @@
+package client
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// defaultThrottleWait is the wait used for a 429 response without a Retry-After header
+const defaultThrottleWait = time.Second
+
+// ThrottlePolicy controls how rate-limited requests (429 and 503 with Retry-After) are handled
+type ThrottlePolicy struct {
+	MaxRetries int           // Maximum number of times a request waits and retries; 0 disables waiting
+	MaxWait    time.Duration // Longest Retry-After the client waits for; longer waits return the error
+}
+
+// DefaultThrottlePolicy returns the throttle policy used by new clients
+func DefaultThrottlePolicy() ThrottlePolicy {
+	return ThrottlePolicy{MaxRetries: 3, MaxWait: 30 * time.Second}
+}
+
+// ThrottleEvent describes a rate-limited request the client is about to retry
+type ThrottleEvent struct {
+	Method     string        // HTTP method of the request
+	Endpoint   string        // Endpoint of the request
+	StatusCode int           // 429 or 503
+	RetryAfter time.Duration // How long the client waits before retrying
+	Retry      int           // Number of the throttle retry, starting at 1
+}
+
+// SetThrottlePolicy sets how rate-limited requests are handled
+func (c *Client) SetThrottlePolicy(policy ThrottlePolicy) {
+	c.throttlePolicy = policy
+}
+
+// OnThrottle registers a callback that is called every time a rate-limited request is retried,
+// e.g. to meter throttling
+func (c *Client) OnThrottle(fn func(ThrottleEvent)) {
+	c.onThrottle = fn
+}
+
+// RetryAfter returns the wait requested by the Retry-After header of the response,
+// and whether the header was present and valid
+func (e *APIError) RetryAfter() (time.Duration, bool) {
+	return parseRetryAfter(e.header.Get("Retry-After"), time.Now())
+}
+
+// parseRetryAfter parses a Retry-After header value given in seconds or as an HTTP date
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+
+	if date, err := http.ParseTime(value); err == nil {
+		wait := date.Sub(now)
+		if wait < 0 {
+			wait = 0
+		}
+		return wait, true
+	}
+
+	return 0, false
+}
+
+// throttleWait returns how long to wait before retrying a rate-limited request.
+// A 429 means the request was not processed and is always safe to retry; a 503 is
+// only retried when the request itself is retryable.
+func (c *Client) throttleWait(err error, retryable bool, throttled int) (time.Duration, bool) {
+	if throttled >= c.throttlePolicy.MaxRetries {
+		return 0, false
+	}
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return 0, false
+	}
+
+	wait, ok := apiErr.RetryAfter()
+	switch apiErr.StatusCode {
+	case http.StatusTooManyRequests:
+		if !ok {
+			wait = defaultThrottleWait
+		}
+	case http.StatusServiceUnavailable:
+		if !ok || !retryable {
+			return 0, false
+		}
+	default:
+		return 0, false
+	}
+
+	if c.throttlePolicy.MaxWait > 0 && wait > c.throttlePolicy.MaxWait {
+		return 0, false
+	}
+
+	return wait, true
+}
@@ -0,0 +1,73 @@
+package client
+
+import (
+	"errors"
+	"time"
+)
+
+// TokenSource tells where the client got an access token from
+type TokenSource string
+
+const (
+	// TokenFetched means the token was fetched from the token endpoint
+	TokenFetched TokenSource = "fetched"
+	// TokenFromStore means the token was taken from the token store, see SetTokenStore
+	TokenFromStore TokenSource = "store"
+	// TokenInjected means the token was set with SetAccessToken
+	TokenInjected TokenSource = "injected"
+)
+
+// ErrNoAccessToken is returned for calls made without a valid access token while token
+// fetching is disabled, see DisableTokenFetching
+var ErrNoAccessToken = errors.New("no valid access token and token fetching is disabled")
+
+// TokenRefresh describes an access token the client adopted
+type TokenRefresh struct {
+	Token  string      // The access token
+	Expiry time.Time   // When the token expires
+	Source TokenSource // Where the token came from
+	Scopes []string    // Scopes granted with the token, nil if unknown
+}
+
+// Token returns the current access token and its expiry, safe to call while the client is
+// in use. The token is empty before the first request and after it was rejected.
+func (c *Client) Token() (string, time.Time) {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+
+	return c.AccessToken, c.TokenExpiry
+}
+
+// OnTokenRefresh registers a callback that is called every time the client adopts a new
+// access token, e.g. to export token age metrics or persist tokens externally. It is called
+// on the goroutine that made the request, so it should return quickly.
+func (c *Client) OnTokenRefresh(fn func(TokenRefresh)) {
+	c.onTokenRefresh = fn
+}
+
+// tokenRefreshed reports a new access token to the registered callback, if any
+func (c *Client) tokenRefreshed(token string, expiry time.Time, source TokenSource) {
+	if c.onTokenRefresh != nil {
+		c.onTokenRefresh(TokenRefresh{Token: token, Expiry: expiry, Source: source, Scopes: c.GrantedScopes()})
+	}
+}
+
+// SetAccessToken sets the access token used for requests, e.g. one issued by a central auth
+// service. Unless token fetching is disabled, the client fetches its own token once the
+// injected one expires.
+func (c *Client) SetAccessToken(token string, expiry time.Time) {
+	c.tokenMu.Lock()
+	c.AccessToken = token
+	c.TokenExpiry = expiry
+	c.grantedScopes, c.scopesKnown = nil, false
+	c.tokenMu.Unlock()
+
+	c.tokenRefreshed(token, expiry, TokenInjected)
+}
+
+// DisableTokenFetching makes the client use only tokens set with SetAccessToken, for
+// deployments where tokens are managed externally. Calls without a valid token, including
+// after the API rejected the token, fail with ErrNoAccessToken until a new one is set.
+func (c *Client) DisableTokenFetching() {
+	c.noTokenFetching = true
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+)
+
+// TokenStore shares access tokens between instances of a service, e.g. the cold starts of
+// AWS Lambda functions, so each instance does not fetch its own token. Implementations
+// backed by Redis or DynamoDB should expire entries at the token expiry, e.g. with a TTL.
+type TokenStore interface {
+	// Get returns the token stored under key and its expiry, or an empty token if there is none
+	Get(key string) (token string, expiry time.Time, err error)
+	// Put stores a token under key until its expiry, replacing any stored token
+	Put(key string, token string, expiry time.Time) error
+}
+
+// MemoryTokenStore is an in-memory TokenStore, suitable for tests and for sharing a token
+// between clients in one process
+type MemoryTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]storedToken
+}
+
+// storedToken is a token held by a MemoryTokenStore
+type storedToken struct {
+	token  string
+	expiry time.Time
+}
+
+// NewMemoryTokenStore creates an empty in-memory token store
+func NewMemoryTokenStore() *MemoryTokenStore {
+	return &MemoryTokenStore{tokens: make(map[string]storedToken)}
+}
+
+// Get returns the token stored under key, or an empty token if there is none or it expired
+func (s *MemoryTokenStore) Get(key string) (string, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.tokens[key]
+	if !ok || !time.Now().Before(stored.expiry) {
+		delete(s.tokens, key)
+		return "", time.Time{}, nil
+	}
+	return stored.token, stored.expiry, nil
+}
+
+// Put stores a token under key until its expiry
+func (s *MemoryTokenStore) Put(key string, token string, expiry time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens[key] = storedToken{token: token, expiry: expiry}
+	return nil
+}
+
+// SetTokenStore sets the store sharing access tokens with other instances. The client uses a
+// stored token while it is valid beyond the refresh margin, see SetRefreshMargin, and stores
+// every token it fetches. Tokens are stored under a key derived from the base URL, credentials
+// and requested scopes, so clients of different sales units or environments can share a
+// store. A nil store disables sharing.
+func (c *Client) SetTokenStore(store TokenStore) {
+	c.tokenStore = store
+}
+
+// tokenStoreKey returns the key the tokens of the credentials are stored under. The
+// credentials are hashed, so the key can be logged and inspected without exposing them.
+func (c *Client) tokenStoreKey(creds Credentials) string {
+	h := sha256.New()
+	for _, part := range []string{c.BaseURL, creds.ClientID, creds.ClientSecret, creds.SubscriptionKey, creds.MSN, c.scopeKey()} {
+		h.Write([]byte(part))
+		h.Write([]byte{0})
+	}
+	return "vipps-token:" + hex.EncodeToString(h.Sum(nil))[:32]
+}
+
+// loadStoredToken adopts the token of the token store if it is valid, and reports whether it did
+func (c *Client) loadStoredToken() bool {
+	creds, err := c.credentials(context.Background())
+	if err != nil {
+		c.logf("Error reading access token from token store, fetching a new one: %v", err)
+		return false
+	}
+
+	token, expiry, err := c.tokenStore.Get(c.tokenStoreKey(creds))
+	if err != nil {
+		c.logf("Error reading access token from token store, fetching a new one: %v", err)
+		return false
+	}
+	// A stored token within the refresh margin is replaced rather than shared, as IsTokenValid
+	// would treat it as expired on the next call
+	if token == "" || !c.now().Add(c.refreshMargin).Before(expiry) {
+		return false
+	}
+
+	c.tokenMu.Lock()
+	c.AccessToken = token
+	c.TokenExpiry = expiry
+	c.grantedScopes, c.scopesKnown = nil, false
+	c.tokenMu.Unlock()
+	c.tokenRefreshed(token, expiry, TokenFromStore)
+
+	return true
+}
+
+// storeToken shares a fetched token through the token store. A failing store does not fail
+// the call, since the token is valid either way.
+func (c *Client) storeToken(creds Credentials, token string, expiry time.Time) {
+	if err := c.tokenStore.Put(c.tokenStoreKey(creds), token, expiry); err != nil {
+		c.logf("Error writing access token to token store: %v", err)
+	}
+}
@@ -0,0 +1,39 @@
+package client_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/client"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/vippstest"
+)
+
+// countingTokenStore is a MemoryTokenStore counting the tokens stored in it
+type countingTokenStore struct {
+	*client.MemoryTokenStore
+	puts int
+}
+
+func (s *countingTokenStore) Put(key string, token string, expiry time.Time) error {
+	s.puts++
+	return s.MemoryTokenStore.Put(key, token, expiry)
+}
+
+func TestStoredTokenWithinRefreshMarginIsReplaced(t *testing.T) {
+	s := vippstest.NewServer()
+	defer s.Close()
+	s.TokenTTL = 4 * time.Minute
+
+	store := &countingTokenStore{MemoryTokenStore: client.NewMemoryTokenStore()}
+	if err := s.Client(client.WithTokenStore(store)).EnsureValidToken(); err != nil {
+		t.Fatalf("EnsureValidToken failed: %v", err)
+	}
+
+	c := s.Client(client.WithTokenStore(store), client.WithRefreshMargin(5*time.Minute))
+	if err := c.EnsureValidToken(); err != nil {
+		t.Fatalf("EnsureValidToken failed: %v", err)
+	}
+	if store.puts != 2 {
+		t.Fatalf("stored %d tokens, want 2: a token within the refresh margin was shared", store.puts)
+	}
+}
@@ -0,0 +1,44 @@
+package client
+
+import (
+	"net/http"
+)
+
+// TraceHeaders are the response headers identifying a request, in order of preference.
+// Quote the trace ID in support tickets to Vipps MobilePay, so the exact request can be found.
+var TraceHeaders = []string{
+	"Vipps-Trace-Id",
+	"Trace-Id",
+	"X-Trace-Id",
+	"Request-Id",
+	"X-Request-Id",
+	"Correlation-Id",
+	"X-Correlation-Id",
+	"Traceparent",
+}
+
+// TraceID returns the trace ID from the headers of a response, or an empty string if the
+// response carries none
+func TraceID(header http.Header) string {
+	for _, name := range TraceHeaders {
+		if value := header.Get(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// WithResponseHeader stores the headers of the response to a call in header, e.g. to read the
+// trace ID of a DoRequest call. The headers of the last attempt are stored, also on errors.
+func WithResponseHeader(header *http.Header) CallOption {
+	return func(cfg *callConfig) {
+		cfg.header = header
+	}
+}
+
+// captureHeader stores the headers of a response if the call asked for them
+func (cfg callConfig) captureHeader(header http.Header) {
+	if cfg.header != nil && header != nil {
+		*cfg.header = header
+	}
+}
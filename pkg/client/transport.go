@@ -0,0 +1,120 @@
+package client
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// transport returns the *http.Transport of the HTTP client for configuration. The HTTP client
+// and its transport may be shared with other code, so the first call configures a copy of both.
+func (c *Client) transport() (*http.Transport, error) {
+	if c.ownTransport != nil && c.client.Transport == c.ownTransport {
+		return c.ownTransport, nil
+	}
+
+	t, ok := cloneTransport(c.client.Transport)
+	if !ok {
+		return nil, fmt.Errorf("cannot configure HTTP transport of type %T, configure it directly", c.client.Transport)
+	}
+
+	httpClient := *c.client
+	httpClient.Transport = t
+	c.client = &httpClient
+	c.ownTransport = t
+	return t, nil
+}
+
+// cloneTransport returns a copy of an HTTP client's transport, or of the default transport
+// if none is set. It reports false for custom transports that are not an *http.Transport.
+func cloneTransport(rt http.RoundTripper) (*http.Transport, bool) {
+	switch t := rt.(type) {
+	case nil:
+		return http.DefaultTransport.(*http.Transport).Clone(), true
+	case *http.Transport:
+		return t.Clone(), true
+	default:
+		return nil, false
+	}
+}
+
+// SetTLSConfig sets the TLS configuration of the HTTP transport, e.g. to trust the internal CA
+// of a TLS-intercepting egress proxy (RootCAs) or to present client certificates (Certificates).
+// It fails if the HTTP client uses a custom transport that is not an *http.Transport.
+func (c *Client) SetTLSConfig(config *tls.Config) error {
+	t, err := c.transport()
+	if err != nil {
+		return err
+	}
+
+	t.TLSClientConfig = config
+	return nil
+}
+
+// SetProxy routes requests through an outbound HTTP proxy, instead of the proxy configured by
+// the process-wide HTTP_PROXY and HTTPS_PROXY environment variables. An empty URL disables
+// proxying altogether, ignoring those variables. It fails if the URL is invalid or the HTTP
+// client uses a custom transport that is not an *http.Transport.
+func (c *Client) SetProxy(proxyURL string) error {
+	var proxy func(*http.Request) (*url.URL, error)
+	if proxyURL != "" {
+		u, err := url.Parse(proxyURL)
+		if err != nil {
+			return fmt.Errorf("failed to parse proxy URL: %w", err)
+		}
+		if u.Scheme == "" || u.Host == "" {
+			return fmt.Errorf("invalid proxy URL %q: scheme and host are required", proxyURL)
+		}
+		proxy = http.ProxyURL(u)
+	}
+
+	t, err := c.transport()
+	if err != nil {
+		return err
+	}
+
+	t.Proxy = proxy
+	return nil
+}
+
+// PoolOptions tunes the connection pool of the HTTP transport. Zero values keep the
+// transport's current settings. Under high load, raise MaxIdleConnsPerHost (2 by default)
+// so connections are reused instead of being opened and closed for every burst of requests.
+type PoolOptions struct {
+	MaxIdleConns        int           // Idle connections kept across all hosts
+	MaxIdleConnsPerHost int           // Idle connections kept to the API host
+	MaxConnsPerHost     int           // Upper bound for connections to the API host, including active ones
+	IdleConnTimeout     time.Duration // How long idle connections are kept
+	DisableHTTP2        bool          // Use HTTP/1.1 only; HTTP/2 is attempted by default
+}
+
+// SetPoolOptions tunes the connection pool and HTTP/2 use of the HTTP transport. It fails
+// if the HTTP client uses a custom transport that is not an *http.Transport.
+func (c *Client) SetPoolOptions(options PoolOptions) error {
+	t, err := c.transport()
+	if err != nil {
+		return err
+	}
+
+	if options.MaxIdleConns > 0 {
+		t.MaxIdleConns = options.MaxIdleConns
+	}
+	if options.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = options.MaxIdleConnsPerHost
+	}
+	if options.MaxConnsPerHost > 0 {
+		t.MaxConnsPerHost = options.MaxConnsPerHost
+	}
+	if options.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = options.IdleConnTimeout
+	}
+	if options.DisableHTTP2 {
+		// A non-nil, empty map disables the transport's built-in HTTP/2 support
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = make(map[string]func(string, *tls.Conn) http.RoundTripper)
+	}
+
+	return nil
+}
@@ -0,0 +1,88 @@
+package client_test
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/client"
+)
+
+func TestTransportOptionsLeaveCallerClientUnchanged(t *testing.T) {
+	transport := &http.Transport{TLSClientConfig: &tls.Config{}}
+	httpClient := &http.Client{Transport: transport}
+
+	c := client.New(client.Credentials{}, client.WithHTTPClient(httpClient))
+	if err := c.SetTLSConfig(&tls.Config{ServerName: "api.example.com"}); err != nil {
+		t.Fatalf("SetTLSConfig failed: %v", err)
+	}
+	if err := c.SetPoolOptions(client.PoolOptions{MaxIdleConnsPerHost: 32}); err != nil {
+		t.Fatalf("SetPoolOptions failed: %v", err)
+	}
+
+	if httpClient.Transport != transport {
+		t.Fatal("caller's HTTP client got another transport")
+	}
+	if transport.TLSClientConfig.ServerName != "" || transport.MaxIdleConnsPerHost != 0 {
+		t.Fatal("caller's transport was modified")
+	}
+
+	defaultClient := &http.Client{}
+	c = client.New(client.Credentials{}, client.WithHTTPClient(defaultClient))
+	if err := c.SetProxy("http://proxy.example.com:3128"); err != nil {
+		t.Fatalf("SetProxy failed: %v", err)
+	}
+	if defaultClient.Transport != nil {
+		t.Fatal("caller's HTTP client got a transport")
+	}
+}
+
+func TestInvalidProxyFailsClient(t *testing.T) {
+	if _, err := client.NewStrict(client.Credentials{}, client.WithProxy("egress-proxy:3128")); err == nil {
+		t.Fatal("NewStrict accepted an invalid proxy URL")
+	}
+
+	c := client.New(client.Credentials{}, client.WithProxy("egress-proxy:3128"))
+	if err := c.GetAccessToken(); err == nil {
+		t.Fatal("client with an invalid proxy URL fetched an access token")
+	}
+	if _, err := client.NewPayment(c).Get("order-1001-a"); err == nil {
+		t.Fatal("client with an invalid proxy URL sent a request")
+	}
+}
+
+// recordingTransport is a custom transport the client cannot configure
+type recordingTransport struct{ requests int }
+
+func (t *recordingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.requests++
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestTLSConfigOnCustomTransportFailsClient(t *testing.T) {
+	transport := &recordingTransport{}
+	c := client.New(client.Credentials{},
+		client.WithHTTPClient(&http.Client{Transport: transport}),
+		client.WithTLSConfig(&tls.Config{MinVersion: tls.VersionTLS13}),
+	)
+	if err := c.GetAccessToken(); err == nil {
+		t.Fatal("client with an unconfigurable transport fetched an access token")
+	}
+	if transport.requests != 0 {
+		t.Fatalf("client sent %d requests without its TLS configuration", transport.requests)
+	}
+}
+
+func TestPoolOptionsOnCustomTransportFailsClient(t *testing.T) {
+	transport := &recordingTransport{}
+	c := client.New(client.Credentials{},
+		client.WithHTTPClient(&http.Client{Transport: transport}),
+		client.WithPoolOptions(client.PoolOptions{MaxIdleConnsPerHost: 100}),
+	)
+	if err := c.GetAccessToken(); err == nil {
+		t.Fatal("client with an unconfigurable transport fetched an access token")
+	}
+	if transport.requests != 0 {
+		t.Fatalf("client sent %d requests without its pool options", transport.requests)
+	}
+}
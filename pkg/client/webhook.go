@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
 )
@@ -24,17 +25,12 @@ func NewWebhook(client *Client) *Webhook {
 func (w *Webhook) Register(req models.WebhookRegistrationRequest) (*models.WebhookRegistration, error) {
 	endpoint := "/webhooks/v1/webhooks"
 
-	body, _, err := w.client.DoRequest(http.MethodPost, endpoint, req, "")
+	response, err := Do[models.WebhookRegistration](w.client, http.MethodPost, endpoint, req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to register webhook: %w", err)
 	}
 
-	var response models.WebhookRegistration
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	return &response, nil
+	return response, nil
 }
 
 // webhooksResponse is a wrapper for the API response which contains a webhooks array
@@ -42,54 +38,209 @@ type webhooksResponse struct {
 	Webhooks []models.WebhookRegistration `json:"webhooks"`
 }
 
-// GetAll retrieves all registered webhooks
-func (w *Webhook) GetAll() ([]models.WebhookRegistration, error) {
+// WebhookFilter selects webhook registrations returned by GetAll
+type WebhookFilter func(models.WebhookRegistration) bool
+
+// WebhookURLContains matches registrations whose URL contains the given substring
+func WebhookURLContains(substr string) WebhookFilter {
+	return func(reg models.WebhookRegistration) bool {
+		return strings.Contains(reg.URL, substr)
+	}
+}
+
+// WebhookHasEvent matches registrations subscribed to the given event type
+func WebhookHasEvent(event models.WebhookEventType) WebhookFilter {
+	return func(reg models.WebhookRegistration) bool {
+		for _, e := range reg.Events {
+			if e == string(event) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// GetAll retrieves all registered webhooks, optionally narrowed down by filters.
+// A registration is returned only if it matches every filter. The Webhooks API does
+// not page its results, so filtering is done client-side.
+func (w *Webhook) GetAll(filters ...WebhookFilter) ([]models.WebhookRegistration, error) {
 	endpoint := "/webhooks/v1/webhooks"
 
-	body, _, err := w.client.DoRequest(http.MethodGet, endpoint, nil, "")
+	body, resp, err := doJSON[json.RawMessage](w.client, newCallConfig(callConfig{}, nil), http.MethodGet, endpoint, nil, "")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get webhooks: %w", err)
 	}
 
 	// Try parsing with the correct wrapper structure first
+	var registrations []models.WebhookRegistration
 	var wrappedResponse webhooksResponse
-	if err := json.Unmarshal(body, &wrappedResponse); err != nil {
+	if err := w.client.codec.Unmarshal(*body, &wrappedResponse); err != nil {
 		// Fall back to the old format in case API changes again
-		var directResponse []models.WebhookRegistration
-		if err2 := json.Unmarshal(body, &directResponse); err2 != nil {
-			return nil, fmt.Errorf("failed to parse response: %w", err)
+		if err2 := w.client.codec.Unmarshal(*body, &registrations); err2 != nil {
+			return nil, &DecodeError{StatusCode: resp.statusCode, Header: resp.header, Body: resp.body, Err: err}
+		}
+	} else {
+		registrations = wrappedResponse.Webhooks
+	}
+
+	return filterWebhooks(registrations, filters), nil
+}
+
+// filterWebhooks returns the registrations matching all filters
+func filterWebhooks(registrations []models.WebhookRegistration, filters []WebhookFilter) []models.WebhookRegistration {
+	if len(filters) == 0 {
+		return registrations
+	}
+
+	var filtered []models.WebhookRegistration
+	for _, reg := range registrations {
+		matches := true
+		for _, filter := range filters {
+			if !filter(reg) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			filtered = append(filtered, reg)
 		}
-		return directResponse, nil
 	}
 
-	return wrappedResponse.Webhooks, nil
+	return filtered
 }
 
 // Get retrieves a specific webhook by ID
 func (w *Webhook) Get(id string) (*models.WebhookRegistration, error) {
 	endpoint := fmt.Sprintf("/webhooks/v1/webhooks/%s", id)
 
-	body, _, err := w.client.DoRequest(http.MethodGet, endpoint, nil, "")
+	response, err := Do[models.WebhookRegistration](w.client, http.MethodGet, endpoint, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get webhook: %w", err)
 	}
 
-	var response models.WebhookRegistration
-	if err := json.Unmarshal(body, &response); err != nil {
-		return nil, fmt.Errorf("failed to parse response: %w", err)
-	}
-
-	return &response, nil
+	return response, nil
 }
 
 // Delete removes a webhook registration
 func (w *Webhook) Delete(id string) error {
 	endpoint := fmt.Sprintf("/webhooks/v1/webhooks/%s", id)
 
-	_, _, err := w.client.DoRequest(http.MethodDelete, endpoint, nil, "")
+	_, err := Do[json.RawMessage](w.client, http.MethodDelete, endpoint, nil)
 	if err != nil {
 		return fmt.Errorf("failed to delete webhook: %w", err)
 	}
 
 	return nil
 }
+
+// Diff compares the desired webhook registrations with the ones currently registered
+// and returns a plan describing the changes needed to reconcile them.
+// Registrations are matched by URL; the order of events is not significant.
+func (w *Webhook) Diff(expected []models.WebhookRegistrationRequest) (*models.WebhookPlan, error) {
+	actual, err := w.GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	plan := &models.WebhookPlan{}
+	matched := make(map[string]bool)
+
+	for _, desired := range expected {
+		if matched[desired.URL] {
+			return nil, fmt.Errorf("duplicate webhook URL in desired configuration: %s", desired.URL)
+		}
+		matched[desired.URL] = true
+
+		var current *models.WebhookRegistration
+		for i := range actual {
+			if actual[i].URL != desired.URL {
+				continue
+			}
+			// Prefer a registration that already has the desired events
+			if current == nil || sameEvents(actual[i].Events, desired.Events) {
+				current = &actual[i]
+			}
+		}
+
+		switch {
+		case current == nil:
+			plan.Create = append(plan.Create, desired)
+		case !sameEvents(current.Events, desired.Events):
+			plan.Update = append(plan.Update, models.WebhookUpdate{Current: *current, Desired: desired})
+		}
+
+		// Any other registration for the same URL is a duplicate and should be removed
+		for _, reg := range actual {
+			if reg.URL == desired.URL && (current == nil || reg.ID != current.ID) {
+				plan.Delete = append(plan.Delete, reg)
+			}
+		}
+	}
+
+	for _, reg := range actual {
+		if !matched[reg.URL] {
+			plan.Delete = append(plan.Delete, reg)
+		}
+	}
+
+	return plan, nil
+}
+
+// Apply executes a plan produced by Diff. Since registrations cannot be modified in place,
+// updates are applied by registering the desired registration again and deleting the current
+// one. Everything is registered before anything is deleted, so events keep being delivered
+// while the plan is applied and a failed registration leaves the current ones in place.
+// The newly created registrations are returned, as their secrets are only available at creation.
+func (w *Webhook) Apply(plan *models.WebhookPlan) ([]models.WebhookRegistration, error) {
+	var created []models.WebhookRegistration
+
+	for _, req := range plan.Create {
+		reg, err := w.Register(req)
+		if err != nil {
+			return created, err
+		}
+		created = append(created, *reg)
+	}
+
+	for _, update := range plan.Update {
+		reg, err := w.Register(update.Desired)
+		if err != nil {
+			return created, err
+		}
+		created = append(created, *reg)
+	}
+
+	for _, update := range plan.Update {
+		if err := w.Delete(update.Current.ID); err != nil {
+			return created, err
+		}
+	}
+
+	for _, reg := range plan.Delete {
+		if err := w.Delete(reg.ID); err != nil {
+			return created, err
+		}
+	}
+
+	return created, nil
+}
+
+// sameEvents reports whether two event lists contain the same events, ignoring order
+func sameEvents(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	counts := make(map[string]int, len(a))
+	for _, event := range a {
+		counts[event]++
+	}
+	for _, event := range b {
+		if counts[event] == 0 {
+			return false
+		}
+		counts[event]--
+	}
+
+	return true
+}
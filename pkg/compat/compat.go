@@ -0,0 +1,47 @@
+// Package compat keeps the previous signatures of APIs the SDK redesigned, so integrations
+// can upgrade first and move to the new APIs at their own pace. Every function reports its
+// use once per process, see client.SetDeprecationHandler, and names its replacement. Shims
+// are removed with the next major version.
+package compat
+
+import (
+	"github.com/zenfulcode/vipps-mobilepay-sdk/internal/deprecation"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/client"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// NewClient creates a new API client from positional credentials
+//
+// Deprecated: Use client.New, which accepts options for additional configuration.
+func NewClient(clientID, clientSecret, subKey, msn string, testMode bool) *client.Client {
+	deprecation.Warn("compat.NewClient", "client.New")
+
+	return client.New(client.Credentials{
+		ClientID:        clientID,
+		ClientSecret:    clientSecret,
+		SubscriptionKey: subKey,
+		MSN:             msn,
+	}, client.WithTestMode(testMode))
+}
+
+// ForceApprove force approves a payment in the test environment without giving a reason
+//
+// Deprecated: Use Payment.ForceApprove, which requires the reason recorded in the audit trail.
+func ForceApprove(payment *client.Payment, reference models.Reference, customerPhoneNumber string, opts ...client.CallOption) error {
+	deprecation.Warn("compat.ForceApprove", "Payment.ForceApprove with a reason")
+
+	return payment.ForceApprove(reference, customerPhoneNumber, "no reason given (compat.ForceApprove)", opts...)
+}
+
+// Cancel cancels a payment with the request passed by pointer; nil cancels with the defaults
+//
+// Deprecated: Use Payment.Cancel, which takes the request by value like Capture and Refund.
+func Cancel(payment *client.Payment, reference models.Reference, req *models.CancelModificationRequest, opts ...client.CallOption) (*models.AdjustmentResponse, error) {
+	deprecation.Warn("compat.Cancel", "Payment.Cancel with a request value")
+
+	var cancelReq models.CancelModificationRequest
+	if req != nil {
+		cancelReq = *req
+	}
+	return payment.Cancel(reference, cancelReq, opts...)
+}
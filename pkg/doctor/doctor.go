@@ -0,0 +1,197 @@
+// Package doctor diagnoses the setup of a Vipps MobilePay integration: configuration, token
+// acquisition, API product access of the subscription key, webhook registrations and clock
+// skew. Onboarding problems almost always come down to one of these.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/client"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/utils"
+)
+
+// DefaultMaxClockSkew is the clock skew tolerated before it is reported
+const DefaultMaxClockSkew = 30 * time.Second
+
+// Status is the outcome of a check
+type Status string
+
+const (
+	// StatusOK means the check passed
+	StatusOK Status = "OK"
+	// StatusWarn means the check found something that may cause problems
+	StatusWarn Status = "WARN"
+	// StatusFail means the check found a problem that breaks the integration
+	StatusFail Status = "FAIL"
+	// StatusSkipped means the check could not run because an earlier check failed
+	StatusSkipped Status = "SKIP"
+)
+
+// Check is the result of a single diagnostic check
+type Check struct {
+	Name   string // What was checked
+	Status Status // Outcome of the check
+	Detail string // Explanation, and how to fix a problem
+}
+
+// Report is the result of a diagnosis
+type Report struct {
+	Checks []Check
+}
+
+// OK reports whether no check failed
+func (r *Report) OK() bool {
+	for _, check := range r.Checks {
+		if check.Status == StatusFail {
+			return false
+		}
+	}
+	return true
+}
+
+// String returns the report as human readable text, one check per line
+func (r *Report) String() string {
+	var b strings.Builder
+	for _, check := range r.Checks {
+		fmt.Fprintf(&b, "[%-4s] %s: %s\n", check.Status, check.Name, check.Detail)
+	}
+
+	if r.OK() {
+		b.WriteString("No problems found.")
+	} else {
+		b.WriteString("Problems found, see the failed checks above.")
+	}
+	return b.String()
+}
+
+// add appends a check to the report
+func (r *Report) add(name string, status Status, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+}
+
+// Options configures a diagnosis
+type Options struct {
+	// Profile whose environment variables are checked, see utils.NewClientFromProfile;
+	// empty checks the unprefixed VIPPS_* variables
+	Profile string
+	// Whether to skip the environment check, for clients configured in code
+	SkipEnv bool
+	// Webhook URL that should be registered; empty only checks that a webhook exists
+	WebhookURL string
+	// Clock skew tolerated before it is reported; zero means DefaultMaxClockSkew
+	MaxClockSkew time.Duration
+}
+
+// Run diagnoses the integration using the client and returns a report of all checks
+func Run(ctx context.Context, c *client.Client, opts Options) *Report {
+	report := &Report{}
+
+	if !opts.SkipEnv {
+		checkEnv(report, opts.Profile)
+	}
+
+	if err := c.GetAccessToken(); err != nil {
+		report.add("Access token", StatusFail, "%v. Check the client ID, client secret, subscription key and MSN, "+
+			"and that test credentials are used with test mode and production credentials without.", err)
+		for _, name := range []string{"ePayment API access", "Webhooks", "Clock skew"} {
+			report.add(name, StatusSkipped, "requires an access token")
+		}
+		return report
+	}
+	report.add("Access token", StatusOK, "token acquired")
+
+	header := checkPaymentAccess(ctx, report, c)
+	checkWebhooks(report, c, opts.WebhookURL)
+	checkClockSkew(report, header, opts.MaxClockSkew)
+
+	return report
+}
+
+// checkEnv checks that the required environment variables are set
+func checkEnv(report *Report, profile string) {
+	if missing := utils.MissingEnv(profile); len(missing) > 0 {
+		report.add("Environment", StatusFail, "missing %s", strings.Join(missing, ", "))
+		return
+	}
+	report.add("Environment", StatusOK, "all required variables are set")
+}
+
+// checkPaymentAccess checks that the subscription key has access to the ePayment API by
+// looking up a payment that does not exist, and returns the response headers
+func checkPaymentAccess(ctx context.Context, report *Report, c *client.Client) http.Header {
+	const name = "ePayment API access"
+
+	resp, err := c.Raw(ctx, http.MethodGet, "/epayment/v1/payments/doctor-"+uuid.New().String(), nil, nil)
+	switch {
+	case err == nil || errors.Is(err, client.ErrNotFound):
+		report.add(name, StatusOK, "the subscription key has access to the ePayment API")
+	case errors.Is(err, client.ErrUnauthorized), errors.Is(err, client.ErrForbidden):
+		report.add(name, StatusFail, "%v. Check that the subscription key belongs to the MSN and that the "+
+			"sales unit has the ePayment API product.", err)
+	default:
+		report.add(name, StatusWarn, "unexpected response: %v", err)
+	}
+
+	return resp.Header
+}
+
+// checkWebhooks checks that a webhook is registered, and the expected URL if given
+func checkWebhooks(report *Report, c *client.Client, url string) {
+	const name = "Webhooks"
+
+	registrations, err := client.NewWebhook(c).GetAll()
+	if err != nil {
+		report.add(name, StatusFail, "%v. Check that the sales unit has access to the Webhooks API.", err)
+		return
+	}
+
+	if len(registrations) == 0 {
+		report.add(name, StatusWarn, "no webhooks are registered, so payment events are only seen by polling")
+		return
+	}
+
+	if url != "" {
+		for _, registration := range registrations {
+			if registration.URL == url {
+				report.add(name, StatusOK, "%s is registered for %s", url, strings.Join(registration.Events, ", "))
+				return
+			}
+		}
+		report.add(name, StatusWarn, "%d webhooks are registered, but not %s", len(registrations), url)
+		return
+	}
+
+	report.add(name, StatusOK, "%d webhooks are registered", len(registrations))
+}
+
+// checkClockSkew compares the local clock with the Date header of an API response. A skewed
+// clock makes tokens appear expired early or late and breaks webhook signature timestamps.
+func checkClockSkew(report *Report, header http.Header, maxSkew time.Duration) {
+	const name = "Clock skew"
+
+	if maxSkew <= 0 {
+		maxSkew = DefaultMaxClockSkew
+	}
+
+	date, err := http.ParseTime(header.Get("Date"))
+	if err != nil {
+		report.add(name, StatusSkipped, "the API response has no Date header")
+		return
+	}
+
+	skew := time.Since(date).Round(time.Second)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxSkew {
+		report.add(name, StatusWarn, "the local clock is off by about %s; synchronize it with NTP", skew)
+		return
+	}
+	report.add(name, StatusOK, "the local clock is within %s of the API", maxSkew)
+}
@@ -0,0 +1,147 @@
+// Package export converts payment events into accounting records for import into ERP systems
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// AccountMapping maps payment operations to ledger accounts
+type AccountMapping struct {
+	Clearing string // Account holding funds in transit from Vipps MobilePay, debited on capture
+	Sales    string // Account credited on capture
+	Refunds  string // Account debited on refund; defaults to Sales when empty
+}
+
+// JournalLine is a single debit or credit posting
+type JournalLine struct {
+	Account string // Ledger account
+	Debit   int    // Debit amount in minor units
+	Credit  int    // Credit amount in minor units
+}
+
+// JournalEntry is a balanced set of postings for a single payment operation
+type JournalEntry struct {
+	Date         time.Time
+	Reference    models.Reference
+	PSPReference models.PSPReference
+	Event        models.PaymentEventName
+	Description  string
+	Currency     string
+	Lines        []JournalLine
+}
+
+// Journal converts payment events into journal entries. Only successful captures
+// and refunds produce entries; other events are skipped.
+func Journal(events []models.PaymentEvent, mapping AccountMapping) ([]JournalEntry, error) {
+	if mapping.Clearing == "" || mapping.Sales == "" {
+		return nil, fmt.Errorf("account mapping requires clearing and sales accounts")
+	}
+
+	refunds := mapping.Refunds
+	if refunds == "" {
+		refunds = mapping.Sales
+	}
+
+	var entries []JournalEntry
+	for _, event := range events {
+		if !event.Success {
+			continue
+		}
+
+		entry := JournalEntry{
+			Date:         event.Timestamp,
+			Reference:    event.Reference,
+			PSPReference: event.PSPReference,
+			Event:        event.Name,
+			Currency:     event.Amount.Currency,
+		}
+		value := event.Amount.Value
+
+		switch event.Name {
+		case models.EventCaptured:
+			entry.Description = fmt.Sprintf("Vipps MobilePay capture %s", event.Reference)
+			entry.Lines = []JournalLine{
+				{Account: mapping.Clearing, Debit: value},
+				{Account: mapping.Sales, Credit: value},
+			}
+		case models.EventRefunded:
+			entry.Description = fmt.Sprintf("Vipps MobilePay refund %s", event.Reference)
+			entry.Lines = []JournalLine{
+				{Account: refunds, Debit: value},
+				{Account: mapping.Clearing, Credit: value},
+			}
+		default:
+			continue
+		}
+
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// JournalFromWebhooks converts webhook events into journal entries
+func JournalFromWebhooks(events []*models.WebhookEvent, mapping AccountMapping) ([]JournalEntry, error) {
+	paymentEvents := make([]models.PaymentEvent, 0, len(events))
+	for _, event := range events {
+		paymentEvents = append(paymentEvents, models.PaymentEvent{
+			Reference:      event.Reference,
+			PSPReference:   event.PSPReference,
+			Name:           event.Name,
+			Amount:         event.Amount,
+			Timestamp:      event.Timestamp,
+			IdempotencyKey: event.IdempotencyKey,
+			Success:        event.Success,
+		})
+	}
+
+	return Journal(paymentEvents, mapping)
+}
+
+// WriteCSV writes journal entries as CSV, one row per posting. Amounts are written in major units.
+func WriteCSV(w io.Writer, entries []JournalEntry) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"date", "reference", "psp_reference", "event", "description", "account", "debit", "credit", "currency"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, entry := range entries {
+		for _, line := range entry.Lines {
+			record := []string{
+				entry.Date.Format("2006-01-02"),
+				string(entry.Reference),
+				string(entry.PSPReference),
+				string(entry.Event),
+				entry.Description,
+				line.Account,
+				formatMinorUnits(line.Debit),
+				formatMinorUnits(line.Credit),
+				entry.Currency,
+			}
+			if err := writer.Write(record); err != nil {
+				return fmt.Errorf("failed to write CSV record: %w", err)
+			}
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatMinorUnits formats an amount in minor units as a decimal in major units, e.g. 1050 as "10.50"
+func formatMinorUnits(value int) string {
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+	return sign + strconv.Itoa(value/100) + "." + fmt.Sprintf("%02d", value%100)
+}
@@ -0,0 +1,102 @@
+package export
+
+import (
+	"encoding/xml"
+	"fmt"
+	"math"
+	"time"
+)
+
+// SAFTAmount is a SAF-T amount in the local currency with the original currency amount
+type SAFTAmount struct {
+	Amount         string `xml:"Amount"`
+	CurrencyCode   string `xml:"CurrencyCode,omitempty"`
+	CurrencyAmount string `xml:"CurrencyAmount,omitempty"`
+}
+
+// SAFTLine is a SAF-T general ledger transaction line
+type SAFTLine struct {
+	RecordID     string      `xml:"RecordID"`
+	AccountID    string      `xml:"AccountID"`
+	Description  string      `xml:"Description"`
+	DebitAmount  *SAFTAmount `xml:"DebitAmount,omitempty"`
+	CreditAmount *SAFTAmount `xml:"CreditAmount,omitempty"`
+}
+
+// SAFTTransaction is a SAF-T general ledger transaction
+type SAFTTransaction struct {
+	XMLName         xml.Name   `xml:"Transaction"`
+	TransactionID   string     `xml:"TransactionID"`
+	TransactionDate string     `xml:"TransactionDate"`
+	Description     string     `xml:"Description"`
+	Lines           []SAFTLine `xml:"Line"`
+}
+
+// ExchangeRate returns the rate converting one unit of currency into the local currency on the given date
+type ExchangeRate func(currency string, date time.Time) (float64, error)
+
+// SAFT converts journal entries into SAF-T general ledger transactions, ready to be placed
+// in the GeneralLedgerEntries/Journal section of a SAF-T Financial file. Amounts are reported
+// in the local currency; entries in other currencies are converted with the exchange rate and
+// reported with their original currency amount alongside. The exchange rate may be nil if all
+// entries are in the local currency.
+func SAFT(entries []JournalEntry, localCurrency string, rate ExchangeRate) ([]SAFTTransaction, error) {
+	transactions := make([]SAFTTransaction, 0, len(entries))
+
+	for i, entry := range entries {
+		id := string(entry.PSPReference)
+		if id == "" {
+			id = fmt.Sprintf("%s-%d", entry.Reference, i+1)
+		}
+
+		transaction := SAFTTransaction{
+			TransactionID:   id,
+			TransactionDate: entry.Date.Format("2006-01-02"),
+			Description:     entry.Description,
+		}
+
+		factor := 1.0
+		foreign := entry.Currency != "" && entry.Currency != localCurrency
+		if foreign {
+			if rate == nil {
+				return nil, fmt.Errorf("entry %s is in %s and no exchange rate is configured", id, entry.Currency)
+			}
+			var err error
+			if factor, err = rate(entry.Currency, entry.Date); err != nil {
+				return nil, fmt.Errorf("failed to get exchange rate for %s: %w", entry.Currency, err)
+			}
+		}
+
+		for j, line := range entry.Lines {
+			saftLine := SAFTLine{
+				RecordID:    fmt.Sprintf("%s-%d", id, j+1),
+				AccountID:   line.Account,
+				Description: entry.Description,
+			}
+			if line.Debit != 0 {
+				saftLine.DebitAmount = saftAmount(line.Debit, entry.Currency, factor, foreign)
+			}
+			if line.Credit != 0 {
+				saftLine.CreditAmount = saftAmount(line.Credit, entry.Currency, factor, foreign)
+			}
+			transaction.Lines = append(transaction.Lines, saftLine)
+		}
+
+		transactions = append(transactions, transaction)
+	}
+
+	return transactions, nil
+}
+
+// saftAmount creates a SAF-T amount, converting foreign currency amounts to the local currency
+func saftAmount(value int, currency string, factor float64, foreign bool) *SAFTAmount {
+	if !foreign {
+		return &SAFTAmount{Amount: formatMinorUnits(value)}
+	}
+
+	return &SAFTAmount{
+		Amount:         formatMinorUnits(int(math.Round(float64(value) * factor))),
+		CurrencyCode:   currency,
+		CurrencyAmount: formatMinorUnits(value),
+	}
+}
@@ -0,0 +1,113 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// SettlementSummary aggregates the captures and refunds of one currency on one day
+type SettlementSummary struct {
+	Date     time.Time // Start of the day
+	Currency string
+	Captured int // Captured amount in minor units
+	Refunded int // Refunded amount in minor units
+	Fees     int // Fees in minor units
+	Net      int // Captured minus refunded minus fees, in minor units
+	Captures int // Number of captures
+	Refunds  int // Number of refunds
+}
+
+// FeeFunc returns the fee in minor units charged for an event, e.g. based on the merchant's price agreement
+type FeeFunc func(event models.PaymentEvent) int
+
+// Summarize groups successful captures and refunds by currency and day. Days are determined
+// in the given location, defaulting to UTC. The fee function is optional. Summaries are
+// ordered by date and currency.
+func Summarize(events []models.PaymentEvent, fee FeeFunc, loc *time.Location) []SettlementSummary {
+	if loc == nil {
+		loc = time.UTC
+	}
+
+	type key struct {
+		date     string
+		currency string
+	}
+	groups := make(map[key]*SettlementSummary)
+
+	for _, event := range events {
+		if !event.Success || (event.Name != models.EventCaptured && event.Name != models.EventRefunded) {
+			continue
+		}
+
+		t := event.Timestamp.In(loc)
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+		k := key{date: day.Format("2006-01-02"), currency: event.Amount.Currency}
+
+		summary, ok := groups[k]
+		if !ok {
+			summary = &SettlementSummary{Date: day, Currency: event.Amount.Currency}
+			groups[k] = summary
+		}
+
+		if event.Name == models.EventCaptured {
+			summary.Captured += event.Amount.Value
+			summary.Captures++
+		} else {
+			summary.Refunded += event.Amount.Value
+			summary.Refunds++
+		}
+		if fee != nil {
+			summary.Fees += fee(event)
+		}
+		summary.Net = summary.Captured - summary.Refunded - summary.Fees
+	}
+
+	summaries := make([]SettlementSummary, 0, len(groups))
+	for _, summary := range groups {
+		summaries = append(summaries, *summary)
+	}
+
+	sort.Slice(summaries, func(i, j int) bool {
+		if !summaries[i].Date.Equal(summaries[j].Date) {
+			return summaries[i].Date.Before(summaries[j].Date)
+		}
+		return summaries[i].Currency < summaries[j].Currency
+	})
+
+	return summaries
+}
+
+// WriteSettlementCSV writes settlement summaries as CSV. Amounts are written in major units.
+func WriteSettlementCSV(w io.Writer, summaries []SettlementSummary) error {
+	writer := csv.NewWriter(w)
+
+	header := []string{"date", "currency", "captured", "refunded", "fees", "net", "captures", "refunds"}
+	if err := writer.Write(header); err != nil {
+		return fmt.Errorf("failed to write CSV header: %w", err)
+	}
+
+	for _, summary := range summaries {
+		record := []string{
+			summary.Date.Format("2006-01-02"),
+			summary.Currency,
+			formatMinorUnits(summary.Captured),
+			formatMinorUnits(summary.Refunded),
+			formatMinorUnits(summary.Fees),
+			formatMinorUnits(summary.Net),
+			strconv.Itoa(summary.Captures),
+			strconv.Itoa(summary.Refunds),
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("failed to write CSV record: %w", err)
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// CardBrand identifies the card network of a card payment
+type CardBrand string
+
+const (
+	// CardBrandVisa is a Visa card
+	CardBrandVisa CardBrand = "VISA"
+	// CardBrandMastercard is a Mastercard card
+	CardBrandMastercard CardBrand = "MASTERCARD"
+	// CardBrandMaestro is a Maestro card
+	CardBrandMaestro CardBrand = "MAESTRO"
+	// CardBrandDankort is a Danish Dankort card
+	CardBrandDankort CardBrand = "DANKORT"
+	// CardBrandAmex is an American Express card
+	CardBrandAmex CardBrand = "AMEX"
+	// CardBrandUnknown is returned when the brand cannot be determined
+	CardBrandUnknown CardBrand = "UNKNOWN"
+)
+
+// CardInfo contains information derived from a card BIN
+type CardInfo struct {
+	BIN           string    // The card BIN (first 6-8 digits)
+	Brand         CardBrand // The card network
+	IssuerCountry string    // ISO 3166-1 alpha-2 country code of the issuer, if known
+}
+
+// CardBinLookup resolves issuer details for a card BIN, e.g. backed by a BIN database or service
+type CardBinLookup interface {
+	LookupCardBin(bin string) (*CardInfo, error)
+}
+
+// ParseCardBin derives card information from a BIN. The brand is detected from the number ranges
+// of the card networks; the issuer country is only available when a lookup is provided.
+func ParseCardBin(bin string, lookup CardBinLookup) (*CardInfo, error) {
+	if len(bin) < 6 || len(bin) > 8 {
+		return nil, fmt.Errorf("invalid card BIN %q: must be 6-8 digits", bin)
+	}
+	for _, r := range bin {
+		if r < '0' || r > '9' {
+			return nil, fmt.Errorf("invalid card BIN %q: must be 6-8 digits", bin)
+		}
+	}
+
+	info := &CardInfo{BIN: bin}
+	if lookup != nil {
+		found, err := lookup.LookupCardBin(bin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to look up card BIN: %w", err)
+		}
+		if found != nil {
+			*info = *found
+			info.BIN = bin
+		}
+	}
+
+	if info.Brand == "" {
+		info.Brand = detectCardBrand(bin)
+	}
+
+	return info, nil
+}
+
+// detectCardBrand detects the card network from the well-known BIN ranges
+func detectCardBrand(bin string) CardBrand {
+	prefix2, _ := strconv.Atoi(bin[:2])
+	prefix4, _ := strconv.Atoi(bin[:4])
+
+	switch {
+	case prefix4 == 5019:
+		return CardBrandDankort
+	case bin[0] == '4':
+		return CardBrandVisa
+	case prefix2 >= 51 && prefix2 <= 55, prefix4 >= 2221 && prefix4 <= 2720:
+		return CardBrandMastercard
+	case prefix2 == 34 || prefix2 == 37:
+		return CardBrandAmex
+	case prefix2 == 50 || (prefix2 >= 56 && prefix2 <= 69):
+		return CardBrandMaestro
+	default:
+		return CardBrandUnknown
+	}
+}
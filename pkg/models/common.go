@@ -19,10 +19,21 @@ type PersonalQR struct {
 	QR string `json:"qr"` // QR code value
 }
 
+// PaymentMethodType identifies the payment method requested or used for a payment
+type PaymentMethodType string
+
+const (
+	// PaymentMethodWallet means the payment is made in the Vipps MobilePay app
+	PaymentMethodWallet PaymentMethodType = "WALLET"
+	// PaymentMethodCard means the payment is made with a card outside the app
+	PaymentMethodCard PaymentMethodType = "CARD"
+)
+
 // PaymentMethod represents the payment method configuration
 type PaymentMethod struct {
-	Type           string   `json:"type"`                     // Usually "WALLET"
-	BlockedSources []string `json:"blockedSources,omitempty"` // Payment sources to block
+	Type           PaymentMethodType `json:"type"`                     // Usually "WALLET"
+	BlockedSources []string          `json:"blockedSources,omitempty"` // Payment sources to block
+	CardBin        string            `json:"cardBin,omitempty"`        // First digits of the card, returned for card payments
 }
 
 // IndustryData contains additional compliance data
@@ -70,6 +81,16 @@ type ProblemDetail struct {
 	Detail   string `json:"detail"`
 	Instance string `json:"instance,omitempty"`
 	Code     string `json:"code,omitempty"`
+	TraceID  string `json:"traceId,omitempty"`
+
+	// Field-level details of validation failures
+	ExtraDetails []ProblemExtraDetail `json:"extraDetails,omitempty"`
+}
+
+// ProblemExtraDetail describes why a single field of a request was rejected
+type ProblemExtraDetail struct {
+	Name   string `json:"name"`   // Name of the field, e.g. amount.value
+	Reason string `json:"reason"` // Why the field was rejected
 }
 
 // Metadata is a map of key-value pairs for storing additional information
@@ -82,3 +103,19 @@ type AggregateAmount struct {
 	RefundedAmount   Amount `json:"refundedAmount"`
 	CancelledAmount  Amount `json:"cancelledAmount"`
 }
+
+// Capturable returns the authorized amount that is neither captured nor cancelled
+func (a AggregateAmount) Capturable() Amount {
+	return Amount{
+		Currency: a.AuthorizedAmount.Currency,
+		Value:    a.AuthorizedAmount.Value - a.CapturedAmount.Value - a.CancelledAmount.Value,
+	}
+}
+
+// Refundable returns the captured amount that is not refunded
+func (a AggregateAmount) Refundable() Amount {
+	return Amount{
+		Currency: a.CapturedAmount.Currency,
+		Value:    a.CapturedAmount.Value - a.RefundedAmount.Value,
+	}
+}
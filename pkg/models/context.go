@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// OrderIDMetadataKey is the metadata key NewPaymentContext takes the order ID from
+const OrderIDMetadataKey = "orderId"
+
+// PaymentContext is the merchant's context of a payment, recorded when it is created so
+// webhook handlers get it with the event instead of looking it up
+type PaymentContext struct {
+	Reference   Reference       `json:"reference"`             // Payment reference
+	OrderID     string          `json:"orderId,omitempty"`     // Order ID, from the orderId metadata entry
+	UserFlow    PaymentUserFlow `json:"userFlow"`              // How the user was brought to the payment
+	Description string          `json:"description,omitempty"` // Payment description shown to the user
+	Amount      Amount          `json:"amount"`                // Amount the payment was created with
+	Metadata    Metadata        `json:"metadata,omitempty"`    // Metadata the payment was created with
+	CreatedAt   time.Time       `json:"createdAt"`             // When the payment was created
+}
+
+// NewPaymentContext returns the context of a payment created with the request
+func NewPaymentContext(req *CreatePaymentRequest) *PaymentContext {
+	return &PaymentContext{
+		Reference:   req.Reference,
+		OrderID:     req.Metadata[OrderIDMetadataKey],
+		UserFlow:    req.UserFlow,
+		Description: req.PaymentDescription,
+		Amount:      req.Amount,
+		Metadata:    req.Metadata,
+		CreatedAt:   time.Now(),
+	}
+}
@@ -0,0 +1,121 @@
+package models
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Locale selects the conventions for text shown to users, such as how amounts are formatted
+type Locale string
+
+const (
+	// LocaleNorwegian is Norwegian Bokmål as used in Norway, e.g. "kr 1 234,50"
+	LocaleNorwegian Locale = "nb-NO"
+	// LocaleDanish is Danish as used in Denmark, e.g. "1.234,50 kr."
+	LocaleDanish Locale = "da-DK"
+	// LocaleFinnish is Finnish as used in Finland, e.g. "1 234,50 €"
+	LocaleFinnish Locale = "fi-FI"
+	// LocaleEnglish is English, e.g. "NOK 1,234.50" or "€1,234.50"
+	LocaleEnglish Locale = "en"
+)
+
+// Locale returns the locale of the market, or LocaleEnglish for unknown markets
+func (m Market) Locale() Locale {
+	switch m {
+	case MarketNorway:
+		return LocaleNorwegian
+	case MarketDenmark:
+		return LocaleDanish
+	case MarketFinland:
+		return LocaleFinnish
+	default:
+		return LocaleEnglish
+	}
+}
+
+// nbsp is the non-breaking space used as thousands separator and between amount and symbol,
+// so formatted amounts are never wrapped across lines
+const nbsp = "\u00a0"
+
+// moneyFormat describes how a locale formats amounts
+type moneyFormat struct {
+	thousands string            // Separator between groups of three digits
+	decimal   string            // Separator between units and minor units
+	prefix    bool              // Whether the currency comes before the number
+	space     bool              // Whether a space separates currency symbols from the number
+	symbols   map[string]string // Currency symbols; other currencies are shown by their code
+}
+
+// moneyFormats are the conventions of each locale
+var moneyFormats = map[Locale]moneyFormat{
+	LocaleNorwegian: {thousands: nbsp, decimal: ",", prefix: true, space: true,
+		symbols: map[string]string{CurrencyNOK: "kr", CurrencyEUR: "€"}},
+	LocaleDanish: {thousands: ".", decimal: ",", prefix: false, space: true,
+		symbols: map[string]string{CurrencyDKK: "kr.", CurrencyEUR: "€"}},
+	LocaleFinnish: {thousands: nbsp, decimal: ",", prefix: false, space: true,
+		symbols: map[string]string{CurrencyEUR: "€"}},
+	LocaleEnglish: {thousands: ",", decimal: ".", prefix: true, space: false,
+		symbols: map[string]string{CurrencyEUR: "€"}},
+}
+
+// Format formats the amount for display in receipts, notifications and logs following the
+// conventions of the locale: "kr 10,00" in Norwegian, "10,00 kr." in Danish and "10,00 €"
+// in Finnish. Currencies without a local symbol are shown by their code, e.g. "10,00 NOK"
+// in Finnish. Spaces are non-breaking. Unknown locales are formatted as LocaleEnglish.
+func (a Amount) Format(locale Locale) string {
+	format, ok := moneyFormats[locale]
+	if !ok {
+		format = moneyFormats[LocaleEnglish]
+	}
+
+	value := a.Value
+	sign := ""
+	if value < 0 {
+		sign = "-"
+		value = -value
+	}
+
+	minor := strconv.Itoa(value % 100)
+	if len(minor) < 2 {
+		minor = "0" + minor
+	}
+	number := groupThousands(strconv.Itoa(value/100), format.thousands) + format.decimal + minor
+
+	currency, ok := format.symbols[a.Currency]
+	separator := nbsp
+	switch {
+	case !ok:
+		// Codes are always separated from the number
+		currency = a.Currency
+	case !format.space:
+		separator = ""
+	}
+	if currency == "" {
+		return sign + number
+	}
+
+	if format.prefix {
+		return sign + currency + separator + number
+	}
+	return sign + number + separator + currency
+}
+
+// groupThousands inserts a separator between groups of three digits
+func groupThousands(digits, separator string) string {
+	if len(digits) <= 3 {
+		return digits
+	}
+
+	var b strings.Builder
+	head := len(digits) % 3
+	if head > 0 {
+		b.WriteString(digits[:head])
+	}
+	for i := head; i < len(digits); i += 3 {
+		if b.Len() > 0 {
+			b.WriteString(separator)
+		}
+		b.WriteString(digits[i : i+3])
+	}
+	return b.String()
+}
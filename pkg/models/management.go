@@ -0,0 +1,20 @@
+package models
+
+// CaptureType is how a sales unit captures authorized payments
+type CaptureType string
+
+const (
+	// CaptureTypeReserve means payments are reserved on authorization and captured separately
+	CaptureTypeReserve CaptureType = "ReserveCapture"
+	// CaptureTypeDirect means payments are captured on authorization, without a Capture call.
+	// Vipps MobilePay enables it for sales units on request, e.g. for instant digital goods.
+	CaptureTypeDirect CaptureType = "DirectCapture"
+)
+
+// SalesUnit is the configuration of a sales unit, as returned by the Management API
+type SalesUnit struct {
+	MSN         string      `json:"msn"`                   // Merchant serial number
+	Name        string      `json:"name"`                  // Name shown to users
+	CaptureType CaptureType `json:"captureType,omitempty"` // How authorized payments are captured
+	ProductType string      `json:"productType,omitempty"` // Product the sales unit is configured for
+}
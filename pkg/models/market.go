@@ -0,0 +1,36 @@
+package models
+
+// Market identifies the country a merchant sells in
+type Market string
+
+const (
+	// MarketNorway is the Norwegian market, using NOK
+	MarketNorway Market = "NO"
+	// MarketDenmark is the Danish market, using DKK
+	MarketDenmark Market = "DK"
+	// MarketFinland is the Finnish market, using EUR
+	MarketFinland Market = "FI"
+)
+
+const (
+	// CurrencyNOK is the Norwegian krone
+	CurrencyNOK = "NOK"
+	// CurrencyDKK is the Danish krone
+	CurrencyDKK = "DKK"
+	// CurrencyEUR is the euro
+	CurrencyEUR = "EUR"
+)
+
+// Currency returns the currency used in the market, or an empty string for unknown markets
+func (m Market) Currency() string {
+	switch m {
+	case MarketNorway:
+		return CurrencyNOK
+	case MarketDenmark:
+		return CurrencyDKK
+	case MarketFinland:
+		return CurrencyEUR
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,190 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// Limits of the metadata of a payment accepted by the ePayment API
+const (
+	MaxMetadataEntries     = 5   // Most entries in the metadata of a payment
+	MaxMetadataKeyLength   = 100 // Longest metadata key, in characters
+	MaxMetadataValueLength = 500 // Longest metadata value, in characters
+)
+
+// ErrMetadataValueMissing is returned by Metadata.Value when there is no value under the key
+var ErrMetadataValueMissing = errors.New("metadata value missing")
+
+// MetadataCodec serializes structured values stored in Metadata, e.g. JSON or msgpack
+type MetadataCodec interface {
+	// Name identifies the codec in stored values, e.g. "json"
+	Name() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// JSONMetadataCodec is a MetadataCodec using encoding/json
+type JSONMetadataCodec struct{}
+
+// Name returns "json"
+func (JSONMetadataCodec) Name() string {
+	return "json"
+}
+
+// Marshal encodes v with json.Marshal
+func (JSONMetadataCodec) Marshal(v interface{}) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Unmarshal decodes data with json.Unmarshal
+func (JSONMetadataCodec) Unmarshal(data []byte, v interface{}) error {
+	return json.Unmarshal(data, v)
+}
+
+// MetadataEncoding stores structured values in metadata with a codec, tagging each value with
+// the codec and the schema version, e.g. "json.2:{...}". Values that are not printable text,
+// such as msgpack, are base64 encoded. Bump the version when the stored type changes, and
+// migrate older values by the version Decode returns.
+type MetadataEncoding struct {
+	Codec   MetadataCodec // Codec of the values; nil means JSONMetadataCodec
+	Version int           // Schema version of the values written
+}
+
+// codec returns the codec of the encoding
+func (e MetadataEncoding) codec() MetadataCodec {
+	if e.Codec == nil {
+		return JSONMetadataCodec{}
+	}
+	return e.Codec
+}
+
+// Encode serializes v into a metadata value, failing if it exceeds MaxMetadataValueLength
+func (e MetadataEncoding) Encode(v interface{}) (string, error) {
+	codec := e.codec()
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode metadata value: %w", err)
+	}
+
+	tag := codec.Name() + "." + strconv.Itoa(e.Version)
+	payload := string(data)
+	if !printable(payload) {
+		tag += ".b64"
+		payload = base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	value := tag + ":" + payload
+	if n := utf8.RuneCountInString(value); n > MaxMetadataValueLength {
+		return "", fmt.Errorf("encoded metadata value is %d characters, the limit is %d", n, MaxMetadataValueLength)
+	}
+	return value, nil
+}
+
+// Decode deserializes a metadata value written by Encode into v and returns the schema
+// version it was written with. Values of another codec or a newer version are rejected.
+func (e MetadataEncoding) Decode(value string, v interface{}) (version int, err error) {
+	tag, payload, ok := strings.Cut(value, ":")
+	if !ok {
+		return 0, fmt.Errorf("metadata value is not encoded: %q", value)
+	}
+
+	parts := strings.Split(tag, ".")
+	if len(parts) < 2 || len(parts) > 3 || (len(parts) == 3 && parts[2] != "b64") {
+		return 0, fmt.Errorf("metadata value has an invalid tag %q", tag)
+	}
+	codec := e.codec()
+	if parts[0] != codec.Name() {
+		return 0, fmt.Errorf("metadata value is encoded with %s, expected %s", parts[0], codec.Name())
+	}
+	version, err = strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("metadata value has an invalid version %q", parts[1])
+	}
+	if version > e.Version {
+		return version, fmt.Errorf("metadata value has version %d, newer than the supported version %d", version, e.Version)
+	}
+
+	data := []byte(payload)
+	if len(parts) == 3 {
+		if data, err = base64.RawURLEncoding.DecodeString(payload); err != nil {
+			return version, fmt.Errorf("failed to decode metadata value: %w", err)
+		}
+	}
+	if err := codec.Unmarshal(data, v); err != nil {
+		return version, fmt.Errorf("failed to decode metadata value: %w", err)
+	}
+	return version, nil
+}
+
+// printable reports whether s is valid UTF-8 without control characters
+func printable(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// SetValue stores a structured value under key, see MetadataEncoding
+func (m *Metadata) SetValue(key string, v interface{}, encoding MetadataEncoding) error {
+	value, err := encoding.Encode(v)
+	if err != nil {
+		return fmt.Errorf("metadata %s: %w", key, err)
+	}
+
+	if *m == nil {
+		*m = make(Metadata)
+	}
+	(*m)[key] = value
+	return nil
+}
+
+// Value decodes the structured value under key into v and returns its schema version. It
+// returns ErrMetadataValueMissing if there is no value under the key.
+func (m Metadata) Value(key string, v interface{}, encoding MetadataEncoding) (int, error) {
+	value, ok := m[key]
+	if !ok {
+		return 0, fmt.Errorf("metadata %s: %w", key, ErrMetadataValueMissing)
+	}
+
+	version, err := encoding.Decode(value, v)
+	if err != nil {
+		return version, fmt.Errorf("metadata %s: %w", key, err)
+	}
+	return version, nil
+}
+
+// Validate checks the metadata against the limits of the ePayment API
+func (m Metadata) Validate() error {
+	problems := &ValidationError{}
+	if len(m) > MaxMetadataEntries {
+		problems.Add("metadata", fmt.Sprintf("has %d entries, the limit is %d", len(m), MaxMetadataEntries))
+	}
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := m[key]
+		if n := utf8.RuneCountInString(key); n > MaxMetadataKeyLength {
+			problems.Add("metadata", fmt.Sprintf("key %q is %d characters, the limit is %d", key, n, MaxMetadataKeyLength))
+		}
+		if n := utf8.RuneCountInString(value); n > MaxMetadataValueLength {
+			problems.Add("metadata."+key, fmt.Sprintf("is %d characters, the limit is %d", n, MaxMetadataValueLength))
+		}
+	}
+	return problems.Err()
+}
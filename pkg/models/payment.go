@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"time"
+)
 
 // PaymentUserFlow defines the flow for bringing users to the payment app
 type PaymentUserFlow string
@@ -73,7 +76,7 @@ type CreatePaymentRequest struct {
 	IndustryData        *IndustryData       `json:"industryData,omitempty"`        // Additional compliance data
 	PaymentMethod       *PaymentMethod      `json:"paymentMethod"`                 // Required: payment method configuration
 	Profile             *Profile            `json:"profile,omitempty"`             // User profile information to request
-	Reference           string              `json:"reference"`                     // Required: unique identifier for the payment
+	Reference           Reference           `json:"reference"`                     // Required: unique identifier for the payment
 	ReturnURL           string              `json:"returnUrl,omitempty"`           // URL to return to after payment
 	UserFlow            PaymentUserFlow     `json:"userFlow"`                      // Required: how to bring user to payment
 	ExpiresAt           *time.Time          `json:"expiresAt,omitempty"`           // When the payment expires (long-living payments)
@@ -86,9 +89,13 @@ type CreatePaymentRequest struct {
 
 // CreatePaymentResponse represents the response after creating a payment
 type CreatePaymentResponse struct {
-	RedirectURL string `json:"redirectUrl"`          // URL for continuing the payment flow
-	Reference   string `json:"reference"`            // Unique reference for the payment
-	QRImageURL  string `json:"qrImageUrl,omitempty"` // URL to QR image if UserFlow is QR
+	RedirectURL string    `json:"redirectUrl"`          // URL for continuing the payment flow
+	Reference   Reference `json:"reference"`            // Unique reference for the payment
+	QRImageURL  string    `json:"qrImageUrl,omitempty"` // URL to QR image if UserFlow is QR
+
+	ShortRedirectURL string `json:"-"` // RedirectURL shortened by the Payment client's shortener
+	ShortQRImageURL  string `json:"-"` // QRImageURL shortened by the Payment client's shortener
+	TraceID          string `json:"-"` // Trace ID of the request, for support tickets
 }
 
 // GetPaymentResponse represents the response when getting payment details
@@ -98,21 +105,22 @@ type GetPaymentResponse struct {
 	State           PaymentState     `json:"state"`                     // Current payment state
 	PaymentMethod   *PaymentMethod   `json:"paymentMethod,omitempty"`   // Payment method used
 	Profile         *Profile         `json:"profile,omitempty"`         // User profile information
-	PSPReference    string           `json:"pspReference"`              // Reference from payment service provider
+	PSPReference    PSPReference     `json:"pspReference"`              // Reference from payment service provider
 	RedirectURL     string           `json:"redirectUrl,omitempty"`     // URL for continuing the payment flow
-	Reference       string           `json:"reference"`                 // Unique reference for the payment
+	Reference       Reference        `json:"reference"`                 // Unique reference for the payment
 	Metadata        Metadata         `json:"metadata,omitempty"`        // Additional metadata
 	CardBin         string           `json:"cardBin,omitempty"`         // First 6 digits of card if card payment
 	CustomerName    string           `json:"customerName,omitempty"`    // Customer name if available
 	CustomerPhone   string           `json:"customerPhone,omitempty"`   // Customer phone if available
 	CustomerEmail   string           `json:"customerEmail,omitempty"`   // Customer email if available
 	CustomerAddress string           `json:"customerAddress,omitempty"` // Customer address if available
+	TraceID         string           `json:"-"`                         // Trace ID of the request, for support tickets
 }
 
 // PaymentEvent represents an event in a payment's history
 type PaymentEvent struct {
-	Reference      string           `json:"reference"`                // Payment reference
-	PSPReference   string           `json:"pspReference"`             // PSP reference for this event
+	Reference      Reference        `json:"reference"`                // Payment reference
+	PSPReference   PSPReference     `json:"pspReference"`             // PSP reference for this event
 	Name           PaymentEventName `json:"name"`                     // Type of event
 	Amount         Amount           `json:"amount"`                   // Amount for this event
 	Timestamp      time.Time        `json:"timestamp"`                // When the event occurred
@@ -135,6 +143,31 @@ type AdjustmentResponse struct {
 	Amount       Amount          `json:"amount"`       // Current payment amount
 	State        PaymentState    `json:"state"`        // Current payment state
 	Aggregate    AggregateAmount `json:"aggregate"`    // Aggregated amounts
-	PSPReference string          `json:"pspReference"` // Reference from payment service provider
-	Reference    string          `json:"reference"`    // Unique reference for the payment
+	PSPReference PSPReference    `json:"pspReference"` // Reference from payment service provider
+	Reference    Reference       `json:"reference"`    // Unique reference for the payment
+	TraceID      string          `json:"-"`            // Trace ID of the request, for support tickets
+}
+
+// IsCardPayment reports whether the payment was made with a card
+func (r *GetPaymentResponse) IsCardPayment() bool {
+	return r.PaymentMethod != nil && r.PaymentMethod.Type == PaymentMethodCard
+}
+
+// IsWalletPayment reports whether the payment was made in the Vipps MobilePay app
+func (r *GetPaymentResponse) IsWalletPayment() bool {
+	return r.PaymentMethod != nil && r.PaymentMethod.Type == PaymentMethodWallet
+}
+
+// CardInfo returns information about the card used for a card payment.
+// The lookup is optional and is used to resolve the issuer country.
+func (r *GetPaymentResponse) CardInfo(lookup CardBinLookup) (*CardInfo, error) {
+	bin := r.CardBin
+	if bin == "" && r.PaymentMethod != nil {
+		bin = r.PaymentMethod.CardBin
+	}
+	if bin == "" {
+		return nil, fmt.Errorf("payment %s has no card BIN", r.Reference)
+	}
+
+	return ParseCardBin(bin, lookup)
 }
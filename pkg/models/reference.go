@@ -0,0 +1,62 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Reference is the merchant's unique identifier for a payment
+type Reference string
+
+// PSPReference is the payment service provider's identifier for a payment or payment event
+type PSPReference string
+
+// referencePattern is the format the ePayment API accepts for payment references
+var referencePattern = regexp.MustCompile(`^[a-zA-Z0-9-]{8,64}$`)
+
+// NewReference creates a validated payment reference
+func NewReference(reference string) (Reference, error) {
+	r := Reference(reference)
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	return r, nil
+}
+
+// Validate checks that the reference is 8-64 characters of letters, digits and dashes
+func (r Reference) Validate() error {
+	if !referencePattern.MatchString(string(r)) {
+		return &ValidationError{Problems: []FieldProblem{{
+			Field:  "reference",
+			Reason: fmt.Sprintf("%q must be 8-64 characters of a-z, A-Z, 0-9 and '-'", string(r)),
+		}}}
+	}
+	return nil
+}
+
+// String returns the reference as a string
+func (r Reference) String() string {
+	return string(r)
+}
+
+// NewPSPReference creates a validated PSP reference
+func NewPSPReference(pspReference string) (PSPReference, error) {
+	r := PSPReference(pspReference)
+	if err := r.Validate(); err != nil {
+		return "", err
+	}
+	return r, nil
+}
+
+// Validate checks that the PSP reference is not empty
+func (r PSPReference) Validate() error {
+	if r == "" {
+		return &ValidationError{Problems: []FieldProblem{{Field: "pspReference", Reason: "must not be empty"}}}
+	}
+	return nil
+}
+
+// String returns the PSP reference as a string
+func (r PSPReference) String() string {
+	return string(r)
+}
@@ -0,0 +1,67 @@
+package models
+
+// PaymentOperation is an operation the merchant can perform on a payment
+type PaymentOperation string
+
+const (
+	// OperationCapture captures (part of) the authorized amount
+	OperationCapture PaymentOperation = "CAPTURE"
+	// OperationRefund refunds (part of) the captured amount
+	OperationRefund PaymentOperation = "REFUND"
+	// OperationCancel cancels the payment, releasing the amount not captured
+	OperationCancel PaymentOperation = "CANCEL"
+)
+
+// paymentTransitions is the documented state machine of ePayment payments. A payment is
+// created in CREATED; the user then authorizes or aborts it, or it expires. The merchant
+// can terminate it by cancelling. ABORTED, EXPIRED and TERMINATED are final.
+var paymentTransitions = map[PaymentState][]PaymentState{
+	PaymentStateCreated: {
+		PaymentStateAuthorized,
+		PaymentStateAborted,
+		PaymentStateExpired,
+		PaymentStateTerminated,
+	},
+	PaymentStateAuthorized: {
+		PaymentStateTerminated,
+	},
+}
+
+// paymentOperations are the operations allowed in each state. Captures and refunds do not
+// change the state of a payment; whether an amount is left to capture or refund is shown
+// by its aggregate.
+var paymentOperations = map[PaymentState][]PaymentOperation{
+	PaymentStateCreated:    {OperationCancel},
+	PaymentStateAuthorized: {OperationCapture, OperationRefund, OperationCancel},
+}
+
+// CanTransition reports whether a payment can move from one state to another
+func CanTransition(from, to PaymentState) bool {
+	for _, state := range paymentTransitions[from] {
+		if state == to {
+			return true
+		}
+	}
+	return false
+}
+
+// IsFinal reports whether a payment in the state can no longer change state
+func (s PaymentState) IsFinal() bool {
+	return len(paymentTransitions[s]) == 0
+}
+
+// AllowedOperations returns the operations the merchant can perform on a payment in the state
+func AllowedOperations(state PaymentState) []PaymentOperation {
+	operations := paymentOperations[state]
+	return append([]PaymentOperation(nil), operations...)
+}
+
+// IsOperationAllowed reports whether the merchant can perform an operation on a payment in the state
+func IsOperationAllowed(state PaymentState, operation PaymentOperation) bool {
+	for _, op := range paymentOperations[state] {
+		if op == operation {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,87 @@
+package models
+
+// paymentStates are all payment states, in the order a payment moves through them
+var paymentStates = []PaymentState{
+	PaymentStateCreated,
+	PaymentStateAuthorized,
+	PaymentStateAborted,
+	PaymentStateExpired,
+	PaymentStateTerminated,
+}
+
+// paymentEventNames are all payment event names, in the order they occur in a payment's life
+var paymentEventNames = []PaymentEventName{
+	EventCreated,
+	EventAuthorized,
+	EventAborted,
+	EventExpired,
+	EventCancelled,
+	EventCaptured,
+	EventRefunded,
+	EventTerminated,
+}
+
+// allPaymentOperations are all operations the merchant can perform on payments
+var allPaymentOperations = []PaymentOperation{
+	OperationCapture,
+	OperationRefund,
+	OperationCancel,
+}
+
+// stateDescriptions describe the payment states
+var stateDescriptions = map[PaymentState]string{
+	PaymentStateCreated:    "The payment has been initiated but not acted upon",
+	PaymentStateAuthorized: "The payment has been accepted by the user",
+	PaymentStateAborted:    "The payment was actively stopped by the user",
+	PaymentStateExpired:    "The user did not act on the payment within the time limit",
+	PaymentStateTerminated: "The merchant canceled the payment before authorization",
+}
+
+// eventDescriptions describe the payment events
+var eventDescriptions = map[PaymentEventName]string{
+	EventCreated:    "A payment was created",
+	EventAuthorized: "A payment was authorized",
+	EventAborted:    "A payment was aborted by the user",
+	EventExpired:    "A payment expired",
+	EventCancelled:  "A payment was cancelled by the merchant",
+	EventCaptured:   "A payment was captured by the merchant",
+	EventRefunded:   "A payment was refunded by the merchant",
+	EventTerminated: "A payment was terminated by the merchant",
+}
+
+// operationDescriptions describe the payment operations
+var operationDescriptions = map[PaymentOperation]string{
+	OperationCapture: "Captures (part of) the authorized amount",
+	OperationRefund:  "Refunds (part of) the captured amount",
+	OperationCancel:  "Cancels the payment, releasing the amount not captured",
+}
+
+// PaymentStates returns all payment states
+func PaymentStates() []PaymentState {
+	return append([]PaymentState(nil), paymentStates...)
+}
+
+// PaymentEventNames returns all payment event names
+func PaymentEventNames() []PaymentEventName {
+	return append([]PaymentEventName(nil), paymentEventNames...)
+}
+
+// PaymentOperations returns all operations the merchant can perform on payments
+func PaymentOperations() []PaymentOperation {
+	return append([]PaymentOperation(nil), allPaymentOperations...)
+}
+
+// Description returns a human readable description of the state
+func (s PaymentState) Description() string {
+	return stateDescriptions[s]
+}
+
+// Description returns a human readable description of the event
+func (n PaymentEventName) Description() string {
+	return eventDescriptions[n]
+}
+
+// Description returns a human readable description of the operation
+func (o PaymentOperation) Description() string {
+	return operationDescriptions[o]
+}
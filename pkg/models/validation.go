@@ -0,0 +1,128 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ErrValidation is matched by every ValidationError
+var ErrValidation = errors.New("validation failed")
+
+// FieldProblem is a problem with a single field of a request
+type FieldProblem struct {
+	Field  string // JSON path of the field, e.g. "amount.currency"
+	Reason string // What is wrong with the value, e.g. "is required"
+}
+
+// ValidationError is returned by checks the SDK performs before a request is sent, listing
+// every problem found so they can be shown next to the fields they concern
+type ValidationError struct {
+	Problems []FieldProblem
+}
+
+// Error implements the error interface
+func (e *ValidationError) Error() string {
+	problems := make([]string, len(e.Problems))
+	for i, problem := range e.Problems {
+		problems[i] = problem.Field + ": " + problem.Reason
+	}
+	return fmt.Sprintf("%v: %s", ErrValidation, strings.Join(problems, "; "))
+}
+
+// Is reports whether the target is ErrValidation
+func (e *ValidationError) Is(target error) bool {
+	return target == ErrValidation
+}
+
+// Add records a problem with a field
+func (e *ValidationError) Add(field, reason string) {
+	e.Problems = append(e.Problems, FieldProblem{Field: field, Reason: reason})
+}
+
+// Merge records the problems of err if it is a ValidationError, prefixing their fields with
+// prefix, e.g. "amount". Other errors are recorded as a problem with the prefix field.
+func (e *ValidationError) Merge(prefix string, err error) {
+	if err == nil {
+		return
+	}
+
+	var other *ValidationError
+	if !errors.As(err, &other) {
+		e.Add(prefix, err.Error())
+		return
+	}
+	for _, problem := range other.Problems {
+		field := problem.Field
+		if prefix != "" {
+			field = prefix + "." + field
+		}
+		e.Add(field, problem.Reason)
+	}
+}
+
+// Err returns the error if any problems were recorded, and nil otherwise
+func (e *ValidationError) Err() error {
+	if len(e.Problems) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Validate checks that the amount is positive and has a currency
+func (a Amount) Validate() error {
+	problems := &ValidationError{}
+	if a.Value <= 0 {
+		problems.Add("value", fmt.Sprintf("must be positive, got %d", a.Value))
+	}
+	if a.Currency == "" {
+		problems.Add("currency", "is required")
+	}
+	return problems.Err()
+}
+
+// Validate checks the fields of the request the ePayment API requires: the reference, a
+// positive amount with a currency, the user flow, a return URL for the redirect flows, and
+// the limits of the metadata
+func (r CreatePaymentRequest) Validate() error {
+	problems := &ValidationError{}
+	problems.Merge("", r.Reference.Validate())
+	problems.Merge("amount", r.Amount.Validate())
+
+	switch r.UserFlow {
+	case "":
+		problems.Add("userFlow", "is required")
+	case UserFlowWebRedirect, UserFlowNativeRedirect:
+		if r.ReturnURL == "" {
+			problems.Add("returnUrl", fmt.Sprintf("is required for %s", r.UserFlow))
+		}
+	}
+
+	if r.ReturnURL != "" {
+		if reason := checkURL(r.ReturnURL, r.UserFlow == UserFlowWebRedirect); reason != "" {
+			problems.Add("returnUrl", reason)
+		}
+	}
+	if r.ReceiptURL != "" {
+		if reason := checkURL(r.ReceiptURL, true); reason != "" {
+			problems.Add("receiptUrl", reason)
+		}
+	}
+	problems.Merge("", r.Metadata.Validate())
+
+	return problems.Err()
+}
+
+// checkURL returns why a URL is invalid, or an empty string. Apps may use their own
+// schemes, so only web URLs are restricted to http and https.
+func checkURL(rawURL string, web bool) string {
+	u, err := url.Parse(rawURL)
+	if err != nil || !u.IsAbs() {
+		return fmt.Sprintf("%q is not an absolute URL", rawURL)
+	}
+	if web && u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Sprintf("%q must be an http or https URL", rawURL)
+	}
+	return ""
+}
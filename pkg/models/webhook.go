@@ -1,12 +1,16 @@
 package models
 
-import "time"
+import (
+	"fmt"
+	"strings"
+	"time"
+)
 
 // WebhookEvent represents the structure of a webhook event
 type WebhookEvent struct {
 	MSN            string           `json:"msn"`                      // The merchant serial number
-	Reference      string           `json:"reference"`                // The payment reference
-	PSPReference   string           `json:"pspReference"`             // The PSP reference
+	Reference      Reference        `json:"reference"`                // The payment reference
+	PSPReference   PSPReference     `json:"pspReference"`             // The PSP reference
 	Name           PaymentEventName `json:"name"`                     // The event type
 	Amount         Amount           `json:"amount"`                   // The amount for the event
 	Timestamp      time.Time        `json:"timestamp"`                // When the event occurred
@@ -52,3 +56,48 @@ const (
 	// WebhookEventPaymentTerminated is sent when a payment is terminated by the merchant
 	WebhookEventPaymentTerminated WebhookEventType = "epayments.payment.terminated.v1"
 )
+
+// WebhookUpdate describes a registration whose events differ from the desired configuration
+type WebhookUpdate struct {
+	Current WebhookRegistration        // The registration as it exists today
+	Desired WebhookRegistrationRequest // The registration as it should be
+}
+
+// WebhookPlan describes the changes needed to bring registered webhooks in line with a desired configuration
+type WebhookPlan struct {
+	Create []WebhookRegistrationRequest // Registrations that do not exist yet
+	Update []WebhookUpdate              // Registrations whose events have drifted
+	Delete []WebhookRegistration        // Registrations that are not part of the desired configuration
+}
+
+// IsEmpty reports whether the plan contains no changes
+func (p *WebhookPlan) IsEmpty() bool {
+	return len(p.Create) == 0 && len(p.Update) == 0 && len(p.Delete) == 0
+}
+
+// String returns a human readable drift report of the plan
+func (p *WebhookPlan) String() string {
+	if p.IsEmpty() {
+		return "No changes. Webhook registrations match the desired configuration."
+	}
+
+	var b strings.Builder
+	for _, req := range p.Create {
+		fmt.Fprintf(&b, "+ create %s [%s]\n", req.URL, strings.Join(req.Events, ", "))
+	}
+	for _, update := range p.Update {
+		fmt.Fprintf(&b, "~ update %s (%s) [%s] -> [%s]\n", update.Current.URL, update.Current.ID,
+			strings.Join(update.Current.Events, ", "), strings.Join(update.Desired.Events, ", "))
+	}
+	for _, reg := range p.Delete {
+		fmt.Fprintf(&b, "- delete %s (%s)\n", reg.URL, reg.ID)
+	}
+	fmt.Fprintf(&b, "Plan: %d to create, %d to update, %d to delete.", len(p.Create), len(p.Update), len(p.Delete))
+
+	return b.String()
+}
+
+// WebhookEventType returns the webhook event type that is sent for the payment event
+func (n PaymentEventName) WebhookEventType() WebhookEventType {
+	return WebhookEventType("epayments.payment." + strings.ToLower(string(n)) + ".v1")
+}
@@ -0,0 +1,192 @@
+// Package notify sends notifications (SMS, email, Slack, ...) when webhook events
+// report payment state changes, using templates filled with the event details
+package notify
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"text/template"
+	"time"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// Notification is a rendered message about a payment state change
+type Notification struct {
+	Event   *models.WebhookEvent // The event that triggered the notification
+	Subject string               // Rendered subject, e.g. for email
+	Body    string               // Rendered message body
+}
+
+// Notifier delivers notifications, e.g. by SMS, email or Slack
+type Notifier interface {
+	Notify(n Notification) error
+}
+
+// NotifierFunc adapts a function to the Notifier interface
+type NotifierFunc func(n Notification) error
+
+// Notify calls f(n)
+func (f NotifierFunc) Notify(n Notification) error {
+	return f(n)
+}
+
+// TemplateData is the data available to notification templates
+type TemplateData struct {
+	Reference    models.Reference
+	PSPReference models.PSPReference
+	Event        models.PaymentEventName
+	Amount       string // Formatted amount, e.g. "10.00 NOK", or "kr 10,00" with the Norwegian locale
+	Value        int    // Amount in minor units
+	Currency     string
+	Timestamp    time.Time
+	MSN          string
+}
+
+// messageTemplate is a parsed subject and body template
+type messageTemplate struct {
+	subject *template.Template
+	body    *template.Template
+}
+
+// registration is a notifier subscribed to a set of events
+type registration struct {
+	notifier Notifier
+	events   map[models.PaymentEventName]bool
+}
+
+// Dispatcher renders notifications for webhook events and delivers them to the registered notifiers
+type Dispatcher struct {
+	templates     map[models.PaymentEventName]messageTemplate
+	registrations []registration
+	locale        models.Locale
+}
+
+// defaultTemplates are used for events without a custom template
+var defaultTemplates = map[models.PaymentEventName][2]string{
+	models.EventCreated:    {"Payment created", "Payment {{.Reference}} of {{.Amount}} was created."},
+	models.EventAuthorized: {"Payment authorized", "Payment {{.Reference}} of {{.Amount}} was authorized."},
+	models.EventAborted:    {"Payment aborted", "Payment {{.Reference}} was aborted."},
+	models.EventExpired:    {"Payment expired", "Payment {{.Reference}} expired."},
+	models.EventCancelled:  {"Payment cancelled", "Payment {{.Reference}} of {{.Amount}} was cancelled."},
+	models.EventCaptured:   {"Payment captured", "{{.Amount}} was captured for payment {{.Reference}}."},
+	models.EventRefunded:   {"Payment refunded", "{{.Amount}} was refunded for payment {{.Reference}}."},
+	models.EventTerminated: {"Payment terminated", "Payment {{.Reference}} was terminated."},
+}
+
+// NewDispatcher creates a new notification dispatcher with default templates
+func NewDispatcher() *Dispatcher {
+	d := &Dispatcher{
+		templates: make(map[models.PaymentEventName]messageTemplate),
+	}
+
+	for event, tmpl := range defaultTemplates {
+		if err := d.SetTemplate(event, tmpl[0], tmpl[1]); err != nil {
+			panic(err)
+		}
+	}
+
+	return d
+}
+
+// SetTemplate sets the subject and body templates for an event. Templates use text/template
+// syntax with TemplateData, e.g. "Payment {{.Reference}} of {{.Amount}} was captured".
+func (d *Dispatcher) SetTemplate(event models.PaymentEventName, subject, body string) error {
+	subjectTmpl, err := template.New(string(event) + "-subject").Parse(subject)
+	if err != nil {
+		return fmt.Errorf("failed to parse subject template: %w", err)
+	}
+
+	bodyTmpl, err := template.New(string(event) + "-body").Parse(body)
+	if err != nil {
+		return fmt.Errorf("failed to parse body template: %w", err)
+	}
+
+	d.templates[event] = messageTemplate{subject: subjectTmpl, body: bodyTmpl}
+	return nil
+}
+
+// SetLocale sets the locale amounts are formatted in, e.g. "kr 10,00" for models.LocaleNorwegian.
+// Without a locale, amounts are formatted as "10.00 NOK".
+func (d *Dispatcher) SetLocale(locale models.Locale) {
+	d.locale = locale
+}
+
+// Add registers a notifier for the given events, or for all events if none are given
+func (d *Dispatcher) Add(notifier Notifier, events ...models.PaymentEventName) {
+	reg := registration{notifier: notifier}
+	if len(events) > 0 {
+		reg.events = make(map[models.PaymentEventName]bool, len(events))
+		for _, event := range events {
+			reg.events[event] = true
+		}
+	}
+
+	d.registrations = append(d.registrations, reg)
+}
+
+// Render renders the notification for an event
+func (d *Dispatcher) Render(event *models.WebhookEvent) (Notification, error) {
+	tmpl, ok := d.templates[event.Name]
+	if !ok {
+		return Notification{}, fmt.Errorf("no template for event type: %s", event.Name)
+	}
+
+	data := TemplateData{
+		Reference:    event.Reference,
+		PSPReference: event.PSPReference,
+		Event:        event.Name,
+		Amount:       d.formatAmount(event.Amount),
+		Value:        event.Amount.Value,
+		Currency:     event.Amount.Currency,
+		Timestamp:    event.Timestamp,
+		MSN:          event.MSN,
+	}
+
+	var subject, body bytes.Buffer
+	if err := tmpl.subject.Execute(&subject, data); err != nil {
+		return Notification{}, fmt.Errorf("failed to render subject: %w", err)
+	}
+	if err := tmpl.body.Execute(&body, data); err != nil {
+		return Notification{}, fmt.Errorf("failed to render body: %w", err)
+	}
+
+	return Notification{Event: event, Subject: subject.String(), Body: body.String()}, nil
+}
+
+// formatAmount formats an amount in the locale of the dispatcher
+func (d *Dispatcher) formatAmount(amount models.Amount) string {
+	if d.locale == "" {
+		return fmt.Sprintf("%.2f %s", float64(amount.Value)/100, amount.Currency)
+	}
+	return amount.Format(d.locale)
+}
+
+// Process renders the notification for an event and delivers it to every notifier
+// subscribed to the event. It can be registered on a webhooks.Router or webhooks.FanOut;
+// register it as optional on a FanOut if failed notifications should not cause redelivery.
+func (d *Dispatcher) Process(event *models.WebhookEvent) error {
+	var notification *Notification
+	var errs []error
+
+	for _, reg := range d.registrations {
+		if reg.events != nil && !reg.events[event.Name] {
+			continue
+		}
+
+		if notification == nil {
+			n, err := d.Render(event)
+			if err != nil {
+				return err
+			}
+			notification = &n
+		}
+
+		if err := reg.notifier.Notify(*notification); err != nil {
+			errs = append(errs, fmt.Errorf("failed to send notification: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,101 @@
+// Package privacy provides helpers for handling personal data in stored payment records,
+// such as removing it after a retention period to comply with GDPR
+package privacy
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// Scrubber removes personal data from stored records. Stores persisting webhook events
+// or payments call it when a record's retention period has passed.
+type Scrubber interface {
+	// Scrub removes personal data from the record in place and reports whether anything was removed
+	Scrub(record interface{}) (bool, error)
+}
+
+// FieldScrubber is the default Scrubber. It clears customer name, phone number, email,
+// address, profile sub and card BIN from the SDK's model types.
+type FieldScrubber struct{}
+
+// Scrub removes personal data from a *models.GetPaymentResponse, *models.CreatePaymentRequest,
+// *models.WebhookEvent, *models.Customer or *models.Profile
+func (s FieldScrubber) Scrub(record interface{}) (bool, error) {
+	switch r := record.(type) {
+	case *models.GetPaymentResponse:
+		changed := clearField(&r.CustomerName)
+		changed = clearField(&r.CustomerPhone) || changed
+		changed = clearField(&r.CustomerEmail) || changed
+		changed = clearField(&r.CustomerAddress) || changed
+		changed = clearField(&r.CardBin) || changed
+		if r.PaymentMethod != nil {
+			changed = clearField(&r.PaymentMethod.CardBin) || changed
+		}
+		profileChanged, _ := s.Scrub(r.Profile)
+		return changed || profileChanged, nil
+	case *models.CreatePaymentRequest:
+		customerChanged, _ := s.Scrub(r.Customer)
+		profileChanged, _ := s.Scrub(r.Profile)
+		return customerChanged || profileChanged, nil
+	case *models.Customer:
+		if r == nil {
+			return false, nil
+		}
+		changed := r.PhoneNumber != nil || r.PersonalQR != nil || r.CustomerToken != nil
+		r.PhoneNumber = nil
+		r.PersonalQR = nil
+		r.CustomerToken = nil
+		return changed, nil
+	case *models.Profile:
+		if r == nil {
+			return false, nil
+		}
+		return clearField(&r.Sub), nil
+	case *models.WebhookEvent:
+		// Webhook events only identify the payment and carry no customer data
+		return false, nil
+	default:
+		return false, fmt.Errorf("unsupported record type %T", record)
+	}
+}
+
+// clearField empties a string field and reports whether it had a value
+func clearField(field *string) bool {
+	if *field == "" {
+		return false
+	}
+	*field = ""
+	return true
+}
+
+// RetentionPolicy scrubs personal data from records stored longer than the retention period
+type RetentionPolicy struct {
+	Retention time.Duration    // How long personal data may be kept
+	Scrubber  Scrubber         // Scrubber to use; defaults to FieldScrubber
+	Now       func() time.Time // Clock; defaults to time.Now
+}
+
+// Expired reports whether a record stored at the given time has passed the retention period
+func (p RetentionPolicy) Expired(storedAt time.Time) bool {
+	now := time.Now
+	if p.Now != nil {
+		now = p.Now
+	}
+	return !storedAt.Add(p.Retention).After(now())
+}
+
+// Apply scrubs the record if it has passed the retention period and reports whether anything was removed
+func (p RetentionPolicy) Apply(record interface{}, storedAt time.Time) (bool, error) {
+	if !p.Expired(storedAt) {
+		return false, nil
+	}
+
+	var scrubber Scrubber = FieldScrubber{}
+	if p.Scrubber != nil {
+		scrubber = p.Scrubber
+	}
+
+	return scrubber.Scrub(record)
+}
@@ -0,0 +1,134 @@
+// Package redact removes credentials and personal data from text the SDK formats for
+// output, such as error messages, debug logs and audit records: client secrets,
+// subscription keys, access tokens and webhook signatures.
+package redact
+
+import (
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Placeholder replaces redacted values
+const Placeholder = "[REDACTED]"
+
+// sensitiveHeaders are the request and response headers whose values are redacted
+var sensitiveHeaders = []string{
+	"Authorization",
+	"X-Vipps-Authorization",
+	"client_id",
+	"client_secret",
+	"Ocp-Apim-Subscription-Key",
+	"Set-Cookie",
+	"Cookie",
+}
+
+// sensitiveFields are the JSON fields whose values are redacted, compared case-insensitively
+var sensitiveFields = map[string]bool{
+	"access_token":    true,
+	"refresh_token":   true,
+	"id_token":        true,
+	"client_secret":   true,
+	"phonenumber":     true,
+	"customerphone":   true,
+	"customername":    true,
+	"customeremail":   true,
+	"customeraddress": true,
+	"email":           true,
+	"address":         true,
+	"customertoken":   true,
+	"personalqr":      true,
+	"sub":             true,
+	"secret":          true,
+}
+
+// minValueLength is the length below which literal values are not redacted, since they
+// would match unrelated text
+const minValueLength = 8
+
+// secretKeys matches the names of credentials in free text, in JSON, form or header notation
+const secretKeys = `(?i:access_token|refresh_token|id_token|client_secret|secret|password|` +
+	`ocp-apim-subscription-key|subscription_key|subscriptionkey)`
+
+// secretPatterns match credentials in free text. The first group is kept, the rest of the
+// match is replaced. Patterns do not require a closing quote, so truncated bodies are covered.
+var secretPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`("` + secretKeys + `"\s*:\s*")[^"]*`),
+	regexp.MustCompile(`(\b` + secretKeys + `=)[^&\s"]*`),
+	regexp.MustCompile(`(\b` + secretKeys + `:\s*)[^\s,"]+`),
+	regexp.MustCompile(`(?i)(\b(?:Bearer|Basic)\s+)[A-Za-z0-9._~+/=-]+`),
+	regexp.MustCompile(`(?i)(\bSignature=)[A-Za-z0-9+/=]+`),
+}
+
+// String redacts credentials in free text: secret fields in JSON, form and header notation,
+// bearer and basic credentials, and HMAC signatures. Any literal values given, such as the
+// configured client secret, are redacted as well, unless they are too short to be secrets.
+func String(s string, values ...string) string {
+	for _, value := range values {
+		if len(value) >= minValueLength {
+			s = strings.ReplaceAll(s, value, Placeholder)
+		}
+	}
+	for _, pattern := range secretPatterns {
+		s = pattern.ReplaceAllString(s, "${1}"+Placeholder)
+	}
+	return s
+}
+
+// Header returns a copy of a header with the values of credential and cookie headers redacted
+func Header(header http.Header) http.Header {
+	if header == nil {
+		return nil
+	}
+
+	clone := header.Clone()
+	for _, name := range sensitiveHeaders {
+		if _, ok := clone[http.CanonicalHeaderKey(name)]; ok {
+			clone.Set(name, Placeholder)
+		}
+		// Headers such as client_id are sent without canonicalization
+		if _, ok := clone[name]; ok {
+			clone[name] = []string{Placeholder}
+		}
+	}
+	return clone
+}
+
+// JSON returns a JSON body with credentials and personal data such as phone numbers
+// redacted. Bodies that are not JSON are dropped, since they cannot be sanitized.
+func JSON(body []byte) []byte {
+	if len(body) == 0 {
+		return nil
+	}
+
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil
+	}
+
+	redacted, err := json.Marshal(value(v))
+	if err != nil {
+		return nil
+	}
+	return redacted
+}
+
+// value redacts sensitive fields in a decoded JSON value
+func value(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if sensitiveFields[strings.ToLower(key)] {
+				v[key] = Placeholder
+				continue
+			}
+			v[key] = value(field)
+		}
+	case []interface{}:
+		for i, element := range v {
+			v[i] = value(element)
+		}
+	}
+	return v
+}
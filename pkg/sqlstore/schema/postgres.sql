@@ -0,0 +1,35 @@
+-- Schema of the Vipps MobilePay SDK stores for PostgreSQL
+
+CREATE TABLE IF NOT EXISTS vipps_payment_metadata (
+    reference VARCHAR(64)  NOT NULL,
+    key       VARCHAR(255) NOT NULL,
+    value     VARCHAR(255) NOT NULL,
+    PRIMARY KEY (key, value, reference)
+);
+
+CREATE TABLE IF NOT EXISTS vipps_subscriptions (
+    id             VARCHAR(255) NOT NULL PRIMARY KEY,
+    agreement_id   VARCHAR(255) NOT NULL DEFAULT '',
+    state          VARCHAR(32)  NOT NULL,
+    next_charge_at TIMESTAMPTZ  NOT NULL,
+    data           TEXT         NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS vipps_subscriptions_agreement ON vipps_subscriptions (agreement_id);
+
+CREATE INDEX IF NOT EXISTS vipps_subscriptions_due ON vipps_subscriptions (state, next_charge_at);
+
+CREATE TABLE IF NOT EXISTS vipps_payment_contexts (
+    reference  VARCHAR(64) NOT NULL PRIMARY KEY,
+    created_at TIMESTAMPTZ NOT NULL,
+    data       TEXT        NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS vipps_commands (
+    id        VARCHAR(255) NOT NULL PRIMARY KEY,
+    reference VARCHAR(64)  NOT NULL,
+    status    VARCHAR(32)  NOT NULL,
+    data      TEXT         NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS vipps_commands_pending ON vipps_commands (status, reference);
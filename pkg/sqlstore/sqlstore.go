@@ -0,0 +1,409 @@
+// Package sqlstore provides database/sql implementations of the SDK's store interfaces for
+// PostgreSQL and MySQL, for merchants who prefer their existing database over Redis. Create
+// the tables with Migrate, or apply the schema files in the schema directory with your own
+// migration tool.
+//
+// The command store is the registry of idempotency keys: commands are stored by the key their
+// operation is sent with. The SDK has no interface for storing webhook events, so there is no
+// event store; a payment's events are read from the API with Payment.GetEvents.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/client"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/subscriptions"
+)
+
+// Compile-time checks that the stores implement the SDK interfaces
+var (
+	_ client.PaymentIndex          = (*PaymentIndex)(nil)
+	_ client.PaymentContextStore   = (*PaymentContextStore)(nil)
+	_ client.CommandStore          = (*CommandStore)(nil)
+	_ subscriptions.AgreementStore = (*SubscriptionStore)(nil)
+)
+
+//go:embed schema/*.sql
+var schemas embed.FS
+
+// Dialect is the SQL dialect of a database
+type Dialect string
+
+const (
+	// Postgres is the dialect of PostgreSQL and compatible databases such as CockroachDB
+	Postgres Dialect = "postgres"
+	// MySQL is the dialect of MySQL and MariaDB
+	MySQL Dialect = "mysql"
+)
+
+// Store holds the database and dialect shared by the stores
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+}
+
+// New creates a store on a database of the given dialect
+func New(db *sql.DB, dialect Dialect) (*Store, error) {
+	if dialect != Postgres && dialect != MySQL {
+		return nil, fmt.Errorf("unsupported dialect %q", dialect)
+	}
+	return &Store{db: db, dialect: dialect}, nil
+}
+
+// Schema returns the schema of the stores for the dialect
+func (s *Store) Schema() (string, error) {
+	schema, err := schemas.ReadFile("schema/" + string(s.dialect) + ".sql")
+	if err != nil {
+		return "", fmt.Errorf("failed to read schema: %w", err)
+	}
+	return string(schema), nil
+}
+
+// Migrate creates the tables and indexes of the stores if they do not exist
+func (s *Store) Migrate(ctx context.Context) error {
+	schema, err := s.Schema()
+	if err != nil {
+		return err
+	}
+
+	for _, statement := range strings.Split(schema, ";") {
+		if strings.TrimSpace(stripComments(statement)) == "" {
+			continue
+		}
+		if _, err := s.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("failed to migrate: %w", err)
+		}
+	}
+	return nil
+}
+
+// stripComments removes SQL line comments
+func stripComments(statement string) string {
+	lines := strings.Split(statement, "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			lines[i] = ""
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// query rewrites the ? placeholders of a query for the dialect
+func (s *Store) query(query string) string {
+	if s.dialect != Postgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// keyColumn returns the quoted name of the metadata key column, a reserved word in MySQL
+func (s *Store) keyColumn() string {
+	if s.dialect == MySQL {
+		return "`key`"
+	}
+	return "key"
+}
+
+// PaymentIndex returns a client.PaymentIndex storing the metadata of created payments
+func (s *Store) PaymentIndex() *PaymentIndex {
+	return &PaymentIndex{store: s}
+}
+
+// PaymentIndex is a client.PaymentIndex backed by the vipps_payment_metadata table
+type PaymentIndex struct {
+	store *Store
+}
+
+// Record stores the metadata of a created payment
+func (i *PaymentIndex) Record(reference models.Reference, metadata models.Metadata) error {
+	insert := "INSERT INTO vipps_payment_metadata (reference, " + i.store.keyColumn() + ", value) VALUES (?, ?, ?)"
+	if i.store.dialect == MySQL {
+		insert = strings.Replace(insert, "INSERT", "INSERT IGNORE", 1)
+	} else {
+		insert += " ON CONFLICT DO NOTHING"
+	}
+	insert = i.store.query(insert)
+
+	ctx := context.Background()
+	for key, value := range metadata {
+		if _, err := i.store.db.ExecContext(ctx, insert, reference.String(), key, value); err != nil {
+			return fmt.Errorf("failed to record payment %s: %w", reference, err)
+		}
+	}
+	return nil
+}
+
+// Find returns the references of payments whose metadata has the given key and value
+func (i *PaymentIndex) Find(key, value string) ([]models.Reference, error) {
+	query := i.store.query("SELECT reference FROM vipps_payment_metadata WHERE " + i.store.keyColumn() + " = ? AND value = ?")
+
+	rows, err := i.store.db.QueryContext(context.Background(), query, key, value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find payments: %w", err)
+	}
+	defer rows.Close()
+
+	var references []models.Reference
+	for rows.Next() {
+		var reference string
+		if err := rows.Scan(&reference); err != nil {
+			return nil, fmt.Errorf("failed to find payments: %w", err)
+		}
+		references = append(references, models.Reference(reference))
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find payments: %w", err)
+	}
+	return references, nil
+}
+
+// replace replaces the row with the given ID in a transaction, as the dialects lack a common upsert
+func (s *Store) replace(ctx context.Context, table, idColumn, id string, columns []string, values ...interface{}) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, s.query("DELETE FROM "+table+" WHERE "+idColumn+" = ?"), id); err != nil {
+		return err
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ")
+	insert := "INSERT INTO " + table + " (" + strings.Join(columns, ", ") + ") VALUES (" + placeholders + ")"
+	if _, err := tx.ExecContext(ctx, s.query(insert), values...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// load reads the data column of the row with the given ID, reporting false if there is none
+func (s *Store) load(ctx context.Context, table, idColumn, id string) (string, bool, error) {
+	var data string
+	err := s.db.QueryRowContext(ctx, s.query("SELECT data FROM "+table+" WHERE "+idColumn+" = ?"), id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return data, true, nil
+}
+
+// PaymentContexts returns a client.PaymentContextStore storing payment contexts as JSON
+func (s *Store) PaymentContexts() *PaymentContextStore {
+	return &PaymentContextStore{store: s}
+}
+
+// PaymentContextStore is a client.PaymentContextStore backed by the vipps_payment_contexts table
+type PaymentContextStore struct {
+	store *Store
+}
+
+// Save stores the context of a created payment
+func (p *PaymentContextStore) Save(paymentContext *models.PaymentContext) error {
+	data, err := json.Marshal(paymentContext)
+	if err != nil {
+		return fmt.Errorf("failed to encode context of payment %s: %w", paymentContext.Reference, err)
+	}
+
+	err = p.store.replace(context.Background(), "vipps_payment_contexts", "reference", paymentContext.Reference.String(),
+		[]string{"reference", "created_at", "data"}, paymentContext.Reference.String(), paymentContext.CreatedAt.UTC(), string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save context of payment %s: %w", paymentContext.Reference, err)
+	}
+	return nil
+}
+
+// Load returns the context of a payment, or nil if none was stored
+func (p *PaymentContextStore) Load(reference models.Reference) (*models.PaymentContext, error) {
+	data, ok, err := p.store.load(context.Background(), "vipps_payment_contexts", "reference", reference.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to load context of payment %s: %w", reference, err)
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	var paymentContext models.PaymentContext
+	if err := json.Unmarshal([]byte(data), &paymentContext); err != nil {
+		return nil, fmt.Errorf("failed to decode context of payment %s: %w", reference, err)
+	}
+	return &paymentContext, nil
+}
+
+// Commands returns a client.CommandStore storing commands as JSON by their ID, the idempotency
+// key their operation is sent with
+func (s *Store) Commands() *CommandStore {
+	return &CommandStore{store: s}
+}
+
+// CommandStore is a client.CommandStore backed by the vipps_commands table
+type CommandStore struct {
+	store *Store
+}
+
+// Save stores a command, replacing the stored command with the same ID
+func (c *CommandStore) Save(command *client.Command) error {
+	data, err := json.Marshal(command)
+	if err != nil {
+		return fmt.Errorf("failed to encode command %s: %w", command.ID, err)
+	}
+
+	err = c.store.replace(context.Background(), "vipps_commands", "id", string(command.ID),
+		[]string{"id", "reference", "status", "data"}, string(command.ID), command.Reference.String(), string(command.Status), string(data))
+	if err != nil {
+		return fmt.Errorf("failed to save command %s: %w", command.ID, err)
+	}
+	return nil
+}
+
+// Load returns the command with the ID, or nil if there is none
+func (c *CommandStore) Load(id client.CommandID) (*client.Command, error) {
+	data, ok, err := c.store.load(context.Background(), "vipps_commands", "id", string(id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load command %s: %w", id, err)
+	}
+	if !ok {
+		return nil, nil
+	}
+
+	var command client.Command
+	if err := json.Unmarshal([]byte(data), &command); err != nil {
+		return nil, fmt.Errorf("failed to decode command %s: %w", id, err)
+	}
+	return &command, nil
+}
+
+// Subscriptions returns a subscriptions.AgreementStore storing subscriptions as JSON, with
+// the columns needed to find due subscriptions and subscriptions by agreement
+func (s *Store) Subscriptions() *SubscriptionStore {
+	return &SubscriptionStore{store: s}
+}
+
+// SubscriptionStore is a subscriptions.AgreementStore backed by the vipps_subscriptions table
+type SubscriptionStore struct {
+	store *Store
+}
+
+// Get returns the subscription with the given ID, or an error if it does not exist
+func (s *SubscriptionStore) Get(id string) (*subscriptions.Subscription, error) {
+	return s.get(s.store.query("SELECT data FROM vipps_subscriptions WHERE id = ?"), id, "subscription "+id)
+}
+
+// GetByAgreement returns the subscription charged through the given agreement
+func (s *SubscriptionStore) GetByAgreement(agreementID string) (*subscriptions.Subscription, error) {
+	return s.get(s.store.query("SELECT data FROM vipps_subscriptions WHERE agreement_id = ?"), agreementID,
+		"subscription for agreement "+agreementID)
+}
+
+// get loads a single subscription
+func (s *SubscriptionStore) get(query, arg, what string) (*subscriptions.Subscription, error) {
+	var data string
+	err := s.store.db.QueryRowContext(context.Background(), query, arg).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, fmt.Errorf("%s not found", what)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %w", what, err)
+	}
+
+	return decodeSubscription(data)
+}
+
+// Save creates or updates a subscription
+func (s *SubscriptionStore) Save(sub *subscriptions.Subscription) error {
+	data, err := encodeSubscription(sub)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	tx, err := s.store.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to save subscription %s: %w", sub.ID, err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, s.store.query("DELETE FROM vipps_subscriptions WHERE id = ?"), sub.ID); err != nil {
+		return fmt.Errorf("failed to save subscription %s: %w", sub.ID, err)
+	}
+	_, err = tx.ExecContext(ctx,
+		s.store.query("INSERT INTO vipps_subscriptions (id, agreement_id, state, next_charge_at, data) VALUES (?, ?, ?, ?, ?)"),
+		sub.ID, sub.AgreementID, string(sub.State), sub.NextChargeAt.UTC(), data)
+	if err != nil {
+		return fmt.Errorf("failed to save subscription %s: %w", sub.ID, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to save subscription %s: %w", sub.ID, err)
+	}
+	return nil
+}
+
+// ListDue returns trial and active subscriptions with a charge due at or before t
+func (s *SubscriptionStore) ListDue(t time.Time) ([]*subscriptions.Subscription, error) {
+	query := s.store.query("SELECT data FROM vipps_subscriptions WHERE state IN (?, ?) AND next_charge_at <= ? ORDER BY next_charge_at")
+
+	rows, err := s.store.db.QueryContext(context.Background(), query,
+		string(subscriptions.StateTrial), string(subscriptions.StateActive), t.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	var due []*subscriptions.Subscription
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, fmt.Errorf("failed to list due subscriptions: %w", err)
+		}
+		sub, err := decodeSubscription(data)
+		if err != nil {
+			return nil, err
+		}
+		due = append(due, sub)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list due subscriptions: %w", err)
+	}
+	return due, nil
+}
+
+// encodeSubscription encodes a subscription for the data column
+func encodeSubscription(sub *subscriptions.Subscription) (string, error) {
+	data, err := json.Marshal(sub)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode subscription %s: %w", sub.ID, err)
+	}
+	return string(data), nil
+}
+
+// decodeSubscription decodes a subscription from the data column
+func decodeSubscription(data string) (*subscriptions.Subscription, error) {
+	var sub subscriptions.Subscription
+	if err := json.Unmarshal([]byte(data), &sub); err != nil {
+		return nil, fmt.Errorf("failed to decode subscription: %w", err)
+	}
+	return &sub, nil
+}
@@ -0,0 +1,172 @@
+package subscriptions
+
+import (
+	"fmt"
+	"time"
+)
+
+// ChargeFailedEvent is the payload of a Recurring charge-failed webhook event
+type ChargeFailedEvent struct {
+	AgreementID   string    `json:"agreementId"`
+	ChargeID      string    `json:"chargeId"`
+	Amount        int       `json:"amount"`
+	Currency      string    `json:"currency"`
+	FailureReason string    `json:"failureReason"`
+	Occurred      time.Time `json:"occurred"`
+}
+
+// AgreementStore is a Store that can also find subscriptions by their Recurring agreement
+type AgreementStore interface {
+	Store
+	// GetByAgreement returns the subscription charged through the given agreement
+	GetByAgreement(agreementID string) (*Subscription, error)
+}
+
+// Dunning schedules retries of failed charges. Retried charges are created by the Manager
+// on its next ChargeDue run, each attempt with its own idempotency key, so a charge is never
+// created twice for the same attempt.
+type Dunning struct {
+	store AgreementStore
+
+	schedule    []time.Duration
+	gracePeriod time.Duration
+
+	onRetryScheduled func(sub *Subscription, at time.Time, reason string)
+	onPastDue        func(sub *Subscription, reason string)
+
+	now func() time.Time
+}
+
+// DunningOption configures a Dunning engine
+type DunningOption func(*Dunning)
+
+// WithRetrySchedule sets the delays after a failure at which the charge is retried.
+// The number of delays is the maximum number of retries.
+func WithRetrySchedule(delays ...time.Duration) DunningOption {
+	return func(d *Dunning) {
+		d.schedule = delays
+	}
+}
+
+// WithGracePeriod sets how long after the due date a failing subscription stays active.
+// Once it has passed, the subscription is marked past due even if retries remain.
+// A zero grace period means the subscription stays active while retries remain.
+func WithGracePeriod(period time.Duration) DunningOption {
+	return func(d *Dunning) {
+		d.gracePeriod = period
+	}
+}
+
+// OnRetryScheduled registers a callback that is called when a retry has been scheduled,
+// e.g. to notify the customer to update their payment card
+func OnRetryScheduled(fn func(sub *Subscription, at time.Time, reason string)) DunningOption {
+	return func(d *Dunning) {
+		d.onRetryScheduled = fn
+	}
+}
+
+// OnPastDue registers a callback that is called when a subscription is given up on and marked past due
+func OnPastDue(fn func(sub *Subscription, reason string)) DunningOption {
+	return func(d *Dunning) {
+		d.onPastDue = fn
+	}
+}
+
+// WithDunningClock sets the function used to get the current time
+func WithDunningClock(now func() time.Time) DunningOption {
+	return func(d *Dunning) {
+		d.now = now
+	}
+}
+
+// NewDunning creates a new dunning engine. By default failed charges are retried after
+// 1, 3 and 7 days.
+func NewDunning(store AgreementStore, opts ...DunningOption) *Dunning {
+	d := &Dunning{
+		store:    store,
+		schedule: []time.Duration{24 * time.Hour, 3 * 24 * time.Hour, 7 * 24 * time.Hour},
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	return d
+}
+
+// RetryPolicy returns a retry policy applying the dunning schedule to charges that fail
+// synchronously. Pass it to the Manager with WithRetryPolicy.
+func (d *Dunning) RetryPolicy() RetryPolicy {
+	return func(sub *Subscription, err error) (time.Time, bool) {
+		at, retry := d.next(sub)
+		if retry {
+			if d.onRetryScheduled != nil {
+				d.onRetryScheduled(sub, at, err.Error())
+			}
+		} else if d.onPastDue != nil {
+			d.onPastDue(sub, err.Error())
+		}
+		return at, retry
+	}
+}
+
+// HandleChargeFailed processes a charge-failed event. The subscription is moved back to the
+// period of the failed charge and a retry is scheduled, or it is marked past due when the
+// schedule or grace period is exhausted. Events for charges other than the subscription's
+// last charge, including redelivered events, are ignored.
+func (d *Dunning) HandleChargeFailed(event ChargeFailedEvent) error {
+	sub, err := d.store.GetByAgreement(event.AgreementID)
+	if err != nil {
+		return fmt.Errorf("failed to get subscription for agreement %s: %w", event.AgreementID, err)
+	}
+
+	if sub.LastChargeID == "" || sub.LastChargeID != event.ChargeID {
+		return nil
+	}
+	if sub.State == StateCancelled || sub.State == StatePaused {
+		return nil
+	}
+
+	// Rewind to the failed period; the charge ID is cleared so redeliveries are ignored
+	sub.PeriodStart = sub.ChargedPeriod
+	sub.PeriodNumber = sub.ChargedNumber
+	sub.Attempt = sub.ChargedAttempt + 1
+	sub.LastChargeID = ""
+
+	at, retry := d.next(sub)
+	if retry {
+		sub.State = StateActive
+		sub.NextChargeAt = at
+	} else {
+		sub.State = StatePastDue
+	}
+
+	sub.UpdatedAt = d.now()
+	if err := d.store.Save(sub); err != nil {
+		return fmt.Errorf("failed to save subscription: %w", err)
+	}
+
+	if retry && d.onRetryScheduled != nil {
+		d.onRetryScheduled(sub, at, event.FailureReason)
+	}
+	if !retry && d.onPastDue != nil {
+		d.onPastDue(sub, event.FailureReason)
+	}
+
+	return nil
+}
+
+// next returns the time of the next retry for a subscription with sub.Attempt failed attempts
+func (d *Dunning) next(sub *Subscription) (time.Time, bool) {
+	if sub.Attempt < 1 || sub.Attempt > len(d.schedule) {
+		return time.Time{}, false
+	}
+
+	at := d.now().Add(d.schedule[sub.Attempt-1])
+	if d.gracePeriod > 0 && at.After(sub.PeriodStart.Add(d.gracePeriod)) {
+		return time.Time{}, false
+	}
+
+	return at, true
+}
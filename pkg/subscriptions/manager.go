@@ -0,0 +1,274 @@
+package subscriptions
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/client"
+)
+
+// Manager orchestrates subscription lifecycles
+type Manager struct {
+	charger Charger
+	store   Store
+
+	retryPolicy RetryPolicy
+	onCharged   func(sub *Subscription, charge *Charge)
+	onFailed    func(sub *Subscription, err error)
+
+	now func() time.Time
+}
+
+// ManagerOption configures a Manager
+type ManagerOption func(*Manager)
+
+// WithRetryPolicy sets the policy used to retry failed charges. By default failed charges are not retried.
+func WithRetryPolicy(policy RetryPolicy) ManagerOption {
+	return func(m *Manager) {
+		m.retryPolicy = policy
+	}
+}
+
+// OnCharged registers a callback that is called after a charge has been created
+func OnCharged(fn func(sub *Subscription, charge *Charge)) ManagerOption {
+	return func(m *Manager) {
+		m.onCharged = fn
+	}
+}
+
+// OnChargeFailed registers a callback that is called when creating a charge fails, including
+// attempts whose outcome is unknown, which are retried with the same idempotency key
+func OnChargeFailed(fn func(sub *Subscription, err error)) ManagerOption {
+	return func(m *Manager) {
+		m.onFailed = fn
+	}
+}
+
+// WithClock sets the function used to get the current time
+func WithClock(now func() time.Time) ManagerOption {
+	return func(m *Manager) {
+		m.now = now
+	}
+}
+
+// NewManager creates a new subscription manager
+func NewManager(charger Charger, store Store, opts ...ManagerOption) *Manager {
+	m := &Manager{
+		charger: charger,
+		store:   store,
+		now:     time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return m
+}
+
+// StartTrial starts the trial period of a new subscription. The caller fills in the
+// identifying fields (ID, AgreementID, Amount, Interval, Description); the first charge
+// is due when the trial ends.
+func (m *Manager) StartTrial(sub *Subscription, trial time.Duration) error {
+	if sub.State != "" {
+		return fmt.Errorf("cannot start trial of subscription %s in state %s", sub.ID, sub.State)
+	}
+
+	now := m.now()
+	trialEnd := now.Add(trial)
+
+	sub.State = StateTrial
+	sub.TrialEndsAt = &trialEnd
+	sub.NextChargeAt = trialEnd
+	sub.PeriodStart = trialEnd
+	sub.CreatedAt = now
+
+	return m.save(sub)
+}
+
+// Activate activates a subscription. A subscription in its trial is activated immediately
+// and charged from now on; a new subscription can be created directly as active.
+func (m *Manager) Activate(sub *Subscription) error {
+	switch sub.State {
+	case "", StateTrial:
+	default:
+		return fmt.Errorf("cannot activate subscription %s in state %s", sub.ID, sub.State)
+	}
+
+	now := m.now()
+	if sub.CreatedAt.IsZero() {
+		sub.CreatedAt = now
+	}
+	sub.State = StateActive
+	sub.TrialEndsAt = nil
+	sub.NextChargeAt = now
+	sub.PeriodStart = now
+	sub.PeriodNumber++
+
+	return m.save(sub)
+}
+
+// Pause suspends charging of an active or past-due subscription
+func (m *Manager) Pause(id string) error {
+	return m.transition(id, func(sub *Subscription) error {
+		if sub.State != StateActive && sub.State != StatePastDue {
+			return fmt.Errorf("cannot pause subscription %s in state %s", sub.ID, sub.State)
+		}
+		sub.State = StatePaused
+		return nil
+	})
+}
+
+// Resume resumes a paused subscription. The next charge is due immediately.
+func (m *Manager) Resume(id string) error {
+	return m.transition(id, func(sub *Subscription) error {
+		if sub.State != StatePaused {
+			return fmt.Errorf("cannot resume subscription %s in state %s", sub.ID, sub.State)
+		}
+		now := m.now()
+		sub.State = StateActive
+		sub.Attempt = 0
+		sub.NextChargeAt = now
+		sub.PeriodStart = now
+		sub.PeriodNumber++
+		return nil
+	})
+}
+
+// Cancel ends a subscription. No further charges are created.
+func (m *Manager) Cancel(id string) error {
+	return m.transition(id, func(sub *Subscription) error {
+		if sub.State == StateCancelled {
+			return nil
+		}
+		sub.State = StateCancelled
+		return nil
+	})
+}
+
+// ChargeDue creates charges for all subscriptions that are due, including trials that have ended.
+// It is meant to be called periodically by a scheduler. Failures of individual subscriptions
+// do not stop the run; they are returned combined.
+func (m *Manager) ChargeDue() error {
+	now := m.now()
+
+	due, err := m.store.ListDue(now)
+	if err != nil {
+		return fmt.Errorf("failed to list due subscriptions: %w", err)
+	}
+
+	var errs []error
+	for _, sub := range due {
+		if err := m.Charge(sub); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Charge creates the charge for the current period of a subscription. The charge uses an
+// idempotency key derived from the subscription and period, so charging the same period
+// twice does not double-charge the customer.
+func (m *Manager) Charge(sub *Subscription) error {
+	if sub.State == StateTrial {
+		sub.State = StateActive
+		sub.TrialEndsAt = nil
+	}
+	if sub.State != StateActive && sub.State != StatePastDue {
+		return fmt.Errorf("cannot charge subscription %s in state %s", sub.ID, sub.State)
+	}
+
+	charge, err := m.charger.CreateCharge(ChargeRequest{
+		AgreementID:    sub.AgreementID,
+		Amount:         sub.Amount,
+		Description:    sub.Description,
+		Due:            sub.NextChargeAt,
+		IdempotencyKey: sub.idempotencyKey(),
+	})
+	if err != nil {
+		if client.IsRetryable(err) {
+			// The charge may have been created, so it is retried with the same idempotency key
+			m.chargeUnconfirmed(sub, err)
+		} else {
+			m.chargeFailed(sub, err)
+		}
+		if saveErr := m.save(sub); saveErr != nil {
+			return errors.Join(err, saveErr)
+		}
+		return fmt.Errorf("failed to charge subscription %s: %w", sub.ID, err)
+	}
+
+	sub.State = StateActive
+	sub.LastChargeID = charge.ID
+	sub.ChargedPeriod = sub.PeriodStart
+	sub.ChargedNumber = sub.PeriodNumber
+	sub.ChargedAttempt = sub.Attempt
+	sub.Attempt = 0
+	sub.PeriodStart = sub.Interval.Next(sub.PeriodStart)
+	sub.PeriodNumber++
+	sub.NextChargeAt = sub.PeriodStart
+
+	if err := m.save(sub); err != nil {
+		return err
+	}
+
+	if m.onCharged != nil {
+		m.onCharged(sub, charge)
+	}
+
+	return nil
+}
+
+// chargeFailed records a failed charge and schedules a retry according to the retry policy
+func (m *Manager) chargeFailed(sub *Subscription, err error) {
+	sub.Attempt++
+
+	retryAt, retry := time.Time{}, false
+	if m.retryPolicy != nil {
+		retryAt, retry = m.retryPolicy(sub, err)
+	}
+
+	if retry {
+		sub.NextChargeAt = retryAt
+	} else {
+		sub.State = StatePastDue
+	}
+
+	if m.onFailed != nil {
+		m.onFailed(sub, err)
+	}
+}
+
+// chargeUnconfirmed records a charge attempt whose outcome is unknown, e.g. after a timeout.
+// The attempt is not counted as failed, so the next run sends the same idempotency key and
+// cannot create a second charge; the subscription stays due until then.
+func (m *Manager) chargeUnconfirmed(sub *Subscription, err error) {
+	if m.onFailed != nil {
+		m.onFailed(sub, err)
+	}
+}
+
+// transition loads a subscription, applies a state change and saves it
+func (m *Manager) transition(id string, change func(sub *Subscription) error) error {
+	sub, err := m.store.Get(id)
+	if err != nil {
+		return fmt.Errorf("failed to get subscription: %w", err)
+	}
+
+	if err := change(sub); err != nil {
+		return err
+	}
+
+	return m.save(sub)
+}
+
+// save persists a subscription
+func (m *Manager) save(sub *Subscription) error {
+	sub.UpdatedAt = m.now()
+	if err := m.store.Save(sub); err != nil {
+		return fmt.Errorf("failed to save subscription: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Package subscriptions orchestrates the lifecycle of subscriptions charged through
+// Vipps MobilePay Recurring agreements: trials, activation, scheduled charges with
+// retry hooks, pausing and cancellation.
+package subscriptions
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// State represents the current state of a subscription
+type State string
+
+const (
+	// StateTrial means the subscription is in its free trial period
+	StateTrial State = "TRIAL"
+	// StateActive means the subscription is charged on schedule
+	StateActive State = "ACTIVE"
+	// StatePastDue means the last charge failed and no further retries are scheduled
+	StatePastDue State = "PAST_DUE"
+	// StatePaused means charging is suspended until the subscription is resumed
+	StatePaused State = "PAUSED"
+	// StateCancelled means the subscription has ended
+	StateCancelled State = "CANCELLED"
+)
+
+// IntervalUnit is the unit of a charge interval
+type IntervalUnit string
+
+const (
+	// IntervalDay charges every N days
+	IntervalDay IntervalUnit = "DAY"
+	// IntervalWeek charges every N weeks
+	IntervalWeek IntervalUnit = "WEEK"
+	// IntervalMonth charges every N months
+	IntervalMonth IntervalUnit = "MONTH"
+	// IntervalYear charges every N years
+	IntervalYear IntervalUnit = "YEAR"
+)
+
+// Interval describes how often a subscription is charged
+type Interval struct {
+	Unit  IntervalUnit `json:"unit"`
+	Count int          `json:"count"`
+}
+
+// Next returns the time of the next charge after t
+func (i Interval) Next(t time.Time) time.Time {
+	count := i.Count
+	if count < 1 {
+		count = 1
+	}
+
+	switch i.Unit {
+	case IntervalDay:
+		return t.AddDate(0, 0, count)
+	case IntervalWeek:
+		return t.AddDate(0, 0, 7*count)
+	case IntervalYear:
+		return t.AddDate(count, 0, 0)
+	default:
+		return t.AddDate(0, count, 0)
+	}
+}
+
+// Subscription is the state of a subscription as persisted in the Store
+type Subscription struct {
+	ID             string          `json:"id"`          // Merchant's identifier for the subscription
+	AgreementID    string          `json:"agreementId"` // Recurring agreement the subscription is charged through
+	Amount         models.Amount   `json:"amount"`      // Amount charged every interval
+	Interval       Interval        `json:"interval"`    // How often the subscription is charged
+	Description    string          `json:"description"` // Description shown to the user on charges
+	State          State           `json:"state"`       // Current state
+	TrialEndsAt    *time.Time      `json:"trialEndsAt,omitempty"`
+	NextChargeAt   time.Time       `json:"nextChargeAt"`           // When the next charge is due
+	PeriodStart    time.Time       `json:"periodStart"`            // Start of the period the next charge covers
+	PeriodNumber   int             `json:"periodNumber"`           // Sequence number of the period the next charge covers
+	Attempt        int             `json:"attempt"`                // Failed attempts for the current period
+	LastChargeID   string          `json:"lastChargeId,omitempty"` // Identifier of the last charge created
+	ChargedPeriod  time.Time       `json:"chargedPeriod"`          // Start of the period the last charge covers
+	ChargedNumber  int             `json:"chargedNumber"`          // Sequence number of the period the last charge covers
+	ChargedAttempt int             `json:"chargedAttempt"`         // Failed attempts before the last charge
+	Metadata       models.Metadata `json:"metadata,omitempty"`
+	CreatedAt      time.Time       `json:"createdAt"`
+	UpdatedAt      time.Time       `json:"updatedAt"`
+}
+
+// ChargeRequest is a request to charge a Recurring agreement
+type ChargeRequest struct {
+	AgreementID    string        // Agreement to charge
+	Amount         models.Amount // Amount to charge
+	Description    string        // Description shown to the user
+	Due            time.Time     // When the charge is due
+	IdempotencyKey string        // Stable key for the period, so retries never double-charge
+}
+
+// Charge is a charge created on a Recurring agreement
+type Charge struct {
+	ID string // Identifier of the charge
+}
+
+// Charger creates charges on Recurring agreements, typically backed by a Recurring API client
+type Charger interface {
+	CreateCharge(req ChargeRequest) (*Charge, error)
+}
+
+// Store persists subscriptions. Implementations must be safe for concurrent use.
+type Store interface {
+	// Get returns the subscription with the given ID, or an error if it does not exist
+	Get(id string) (*Subscription, error)
+	// Save creates or updates a subscription
+	Save(sub *Subscription) error
+	// ListDue returns trial and active subscriptions with a charge due at or before t
+	ListDue(t time.Time) ([]*Subscription, error)
+}
+
+// RetryPolicy decides when a failed charge should be retried. It receives the subscription,
+// with Attempt counting the failed attempts so far, and the error of the last attempt.
+// It returns the time of the next attempt, or false to give up and mark the subscription past due.
+type RetryPolicy func(sub *Subscription, err error) (time.Time, bool)
+
+// idempotencyKey returns the charge idempotency key for the current period and attempt of a
+// subscription. Failed charges are final, so every retry of a period needs its own key.
+// Attempt only advances once a charge is confirmed failed; attempts with an unknown outcome,
+// such as timeouts, are retried with the same key, so they cannot charge twice. The period
+// number tells apart periods starting on the same day, e.g. after a resume on the day of a
+// charge; subscriptions stored before it was introduced start at 0 and keep their keys.
+func (s *Subscription) idempotencyKey() string {
+	key := fmt.Sprintf("%s-%s", s.ID, s.PeriodStart.UTC().Format("20060102"))
+	if s.PeriodNumber > 0 {
+		key = fmt.Sprintf("%s-%d", key, s.PeriodNumber)
+	}
+	if s.Attempt > 0 {
+		key = fmt.Sprintf("%s-retry-%d", key, s.Attempt)
+	}
+	return key
+}
@@ -1,8 +1,10 @@
 package utils
 
 import (
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/client"
@@ -43,46 +45,122 @@ func LoadEnvFromRoot() error {
 	return LoadEnv(DefaultEnvFile)
 }
 
-// NewClientFromEnv creates a new Vipps MobilePay client using environment variables
+// ProfileEnv is the environment variable selecting the credential profile used by NewClientFromEnv
+const ProfileEnv = "VIPPS_PROFILE"
+
+// NewClientFromEnv creates a new Vipps MobilePay client using environment variables.
+// If VIPPS_PROFILE is set, the client is created from that profile, see NewClientFromProfile.
 func NewClientFromEnv() (*client.Client, error) {
 	// Try to load environment variables from .env file, but don't fail if not found
 	_ = LoadEnvFromRoot()
 
+	return newClientFromProfile(GetEnv(ProfileEnv, ""))
+}
+
+// NewClientFromProfile creates a new Vipps MobilePay client from a named profile, so one
+// binary can hold configurations for several environments. The profile "staging" reads
+// VIPPS_STAGING_CLIENT_ID, VIPPS_STAGING_TEST_MODE etc. Credentials must be set for the
+// profile; other settings fall back to the unprefixed VIPPS_* variables. An empty name
+// selects the unprefixed variables.
+func NewClientFromProfile(name string) (*client.Client, error) {
+	// Try to load environment variables from .env file, but don't fail if not found
+	_ = LoadEnvFromRoot()
+
+	return newClientFromProfile(name)
+}
+
+// newClientFromProfile creates a client from the environment variables of a profile
+func newClientFromProfile(name string) (*client.Client, error) {
+	profile := newProfile(name)
+
 	// Get configuration from environment
-	clientID := GetEnv("VIPPS_CLIENT_ID", "")
-	clientSecret := GetEnv("VIPPS_CLIENT_SECRET", "")
-	subscriptionKey := GetEnv("VIPPS_SUBSCRIPTION_KEY", "")
-	msn := GetEnv("VIPPS_MSN", "")
-	testMode := GetEnvBool("VIPPS_TEST_MODE", true)
-	PhoneNumber = GetEnv("VIPPS_PHONE_NUMBER", "")
-	WebhookURL = GetEnv("VIPPS_WEBHOOK_URL", "")
+	clientID := profile.credential("CLIENT_ID")
+	clientSecret := profile.credential("CLIENT_SECRET")
+	subscriptionKey := profile.credential("SUBSCRIPTION_KEY")
+	msn := profile.credential("MSN")
+	testMode := GetEnvBool(profile.key("TEST_MODE"), GetEnvBool("VIPPS_TEST_MODE", true))
+	PhoneNumber = profile.get("PHONE_NUMBER", "")
+	WebhookURL = profile.get("WEBHOOK_URL", "")
 
-	// Create client
-	vippsClient := client.NewClient(
-		clientID,
-		clientSecret,
-		subscriptionKey,
-		msn,
-		testMode,
-	)
-
-	// Set optional system information
-	vippsClient.SetSystemInfo(
-		GetEnv("VIPPS_SYSTEM_NAME", "go-vipps-mobilepay-sdk"),
-		GetEnv("VIPPS_SYSTEM_VERSION", "1.0.0"),
-		GetEnv("VIPPS_SYSTEM_PLUGIN_NAME", "Mobilepay SDK"),
-		GetEnv("VIPPS_SYSTEM_PLUGIN_VERSION", "0.0.1"),
-	)
+	if profile.name != "" && clientID == "" {
+		return nil, fmt.Errorf("profile %q is not configured: %s is not set", name, profile.key("CLIENT_ID"))
+	}
+
+	opts := []client.Option{
+		client.WithTestMode(testMode),
+		// Set optional system information
+		client.WithSystemInfo(
+			profile.get("SYSTEM_NAME", "go-vipps-mobilepay-sdk"),
+			profile.get("SYSTEM_VERSION", "1.0.0"),
+			profile.get("SYSTEM_PLUGIN_NAME", "Mobilepay SDK"),
+			profile.get("SYSTEM_PLUGIN_VERSION", "0.0.1"),
+		),
+	}
 
 	// Set timeout if specified
-	if timeoutStr := GetEnv("VIPPS_TIMEOUT", ""); timeoutStr != "" {
+	if timeoutStr := profile.get("TIMEOUT", ""); timeoutStr != "" {
 		if timeout, err := time.ParseDuration(timeoutStr); err == nil {
-			vippsClient.SetTimeout(timeout)
+			opts = append(opts, client.WithTimeout(timeout))
 		}
 	}
 
+	// Create client
+	vippsClient := client.New(client.Credentials{
+		ClientID:        clientID,
+		ClientSecret:    clientSecret,
+		SubscriptionKey: subscriptionKey,
+		MSN:             msn,
+	}, opts...)
+
 	// Get access token
 	err := vippsClient.GetAccessToken()
 
 	return vippsClient, err
 }
+
+// MissingEnv returns the environment variables required by a profile that are not set, e.g.
+// to report configuration problems at startup. An empty name checks the unprefixed variables.
+func MissingEnv(name string) []string {
+	// Try to load environment variables from .env file, but don't fail if not found
+	_ = LoadEnvFromRoot()
+
+	profile := newProfile(name)
+
+	var missing []string
+	for _, setting := range []string{"CLIENT_ID", "CLIENT_SECRET", "SUBSCRIPTION_KEY", "MSN"} {
+		if profile.credential(setting) == "" {
+			missing = append(missing, profile.key(setting))
+		}
+	}
+	return missing
+}
+
+// profile resolves the environment variables of a named configuration profile
+type profile struct {
+	name string
+}
+
+// newProfile normalizes a profile name, so "pre-prod" reads VIPPS_PRE_PROD_* variables
+func newProfile(name string) profile {
+	name = strings.ToUpper(strings.TrimSpace(name))
+	return profile{name: strings.NewReplacer("-", "_", ".", "_", " ", "_").Replace(name)}
+}
+
+// key returns the name of the environment variable holding a setting for the profile
+func (p profile) key(setting string) string {
+	if p.name == "" {
+		return "VIPPS_" + setting
+	}
+	return "VIPPS_" + p.name + "_" + setting
+}
+
+// credential returns a credential of the profile. Credentials never fall back to the
+// unprefixed variables, so a profile cannot silently use another environment's keys.
+func (p profile) credential(setting string) string {
+	return GetEnv(p.key(setting), "")
+}
+
+// get returns a setting of the profile, falling back to the unprefixed variable
+func (p profile) get(setting, defaultValue string) string {
+	return GetEnv(p.key(setting), GetEnv("VIPPS_"+setting, defaultValue))
+}
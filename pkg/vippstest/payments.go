@@ -0,0 +1,317 @@
+package vippstest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// payment is a payment held by the fake server
+type payment struct {
+	models.GetPaymentResponse
+
+	expiresAt time.Time
+	events    []models.PaymentEvent
+	// Responses to modifications by idempotency key, so repeated requests are not applied twice
+	idempotent map[string]models.AdjustmentResponse
+	// Idempotency key and response of the create request, so a retried create is answered again
+	createKey string
+	created   models.CreatePaymentResponse
+}
+
+// expire moves a payment that was not acted upon in time to EXPIRED
+func (p *payment) expire(now time.Time) {
+	if p.State == models.PaymentStateCreated && !now.Before(p.expiresAt) {
+		p.State = models.PaymentStateExpired
+		p.addEvent(models.EventExpired, p.Amount, "", now)
+	}
+}
+
+// addEvent appends an event to the payment's event log
+func (p *payment) addEvent(name models.PaymentEventName, amount models.Amount, idempotencyKey string, now time.Time) {
+	p.events = append(p.events, models.PaymentEvent{
+		Reference:      p.Reference,
+		PSPReference:   models.PSPReference(uuid.New().String()),
+		Name:           name,
+		Amount:         amount,
+		Timestamp:      now,
+		IdempotencyKey: idempotencyKey,
+		Success:        true,
+	})
+}
+
+// adjustment returns the response to a modification of the payment
+func (p *payment) adjustment() models.AdjustmentResponse {
+	return models.AdjustmentResponse{
+		Amount:       p.Amount,
+		State:        p.State,
+		Aggregate:    *p.Aggregate,
+		PSPReference: p.PSPReference,
+		Reference:    p.Reference,
+	}
+}
+
+// Payment returns the current state of a payment, as Get would return it
+func (s *Server) Payment(reference models.Reference) (models.GetPaymentResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.payments[reference]
+	if !ok {
+		return models.GetPaymentResponse{}, false
+	}
+	resp := p.GetPaymentResponse
+	aggregate := *p.Aggregate
+	resp.Aggregate = &aggregate
+	return resp, true
+}
+
+// Approve authorizes a payment as if the user approved it in the app
+func (s *Server) Approve(reference models.Reference) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.payments[reference]
+	if !ok {
+		return fmt.Errorf("payment %s not found", reference)
+	}
+	return s.approve(p)
+}
+
+// approve authorizes a payment; the caller must hold the lock
+func (s *Server) approve(p *payment) error {
+	now := s.now()
+	p.expire(now)
+	if !models.CanTransition(p.State, models.PaymentStateAuthorized) {
+		return fmt.Errorf("payment %s is %s and cannot be approved", p.Reference, p.State)
+	}
+
+	p.State = models.PaymentStateAuthorized
+	p.Aggregate.AuthorizedAmount.Value = p.Amount.Value
+	p.addEvent(models.EventAuthorized, p.Amount, "", now)
+
+	if s.CaptureType == models.CaptureTypeDirect {
+		p.Aggregate.CapturedAmount.Value = p.Amount.Value
+		p.addEvent(models.EventCaptured, p.Amount, "", now)
+	}
+
+	return nil
+}
+
+// handleCreate creates a payment. A retry with the idempotency key of the create request is
+// answered like the first request, as the API does, instead of creating another payment.
+func (s *Server) handleCreate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, http.StatusMethodNotAllowed, "Method Not Allowed", "")
+		return
+	}
+
+	var req models.CreatePaymentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeProblem(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+	var problems *models.ValidationError
+	if errors.As(req.Reference.Validate(), &problems) {
+		writeValidationError(w, problems)
+		return
+	}
+	if req.Amount.Value <= 0 {
+		writeValidationProblem(w, "amount.value", "amount must be positive")
+		return
+	}
+	if req.Amount.Currency == "" {
+		writeValidationProblem(w, "amount.currency", "currency is required")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := r.Header.Get("Idempotency-Key")
+	if existing, exists := s.payments[req.Reference]; exists {
+		if key != "" && key == existing.createKey {
+			writeJSON(w, http.StatusCreated, existing.created)
+			return
+		}
+		writeProblem(w, http.StatusConflict, "Conflict", fmt.Sprintf("payment %s already exists", req.Reference))
+		return
+	}
+
+	now := s.now()
+	expiresAt := now.Add(s.PaymentTTL)
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+
+	zero := models.Amount{Currency: req.Amount.Currency}
+	redirectURL := fmt.Sprintf("%s/landing/%s", s.URL, req.Reference)
+	p := &payment{
+		GetPaymentResponse: models.GetPaymentResponse{
+			Aggregate: &models.AggregateAmount{
+				AuthorizedAmount: zero,
+				CapturedAmount:   zero,
+				RefundedAmount:   zero,
+				CancelledAmount:  zero,
+			},
+			Amount:        req.Amount,
+			State:         models.PaymentStateCreated,
+			PaymentMethod: req.PaymentMethod,
+			PSPReference:  models.PSPReference(uuid.New().String()),
+			RedirectURL:   redirectURL,
+			Reference:     req.Reference,
+			Metadata:      req.Metadata,
+		},
+		expiresAt:  expiresAt,
+		idempotent: make(map[string]models.AdjustmentResponse),
+	}
+	p.addEvent(models.EventCreated, p.Amount, key, now)
+	s.payments[req.Reference] = p
+
+	resp := models.CreatePaymentResponse{
+		RedirectURL: redirectURL,
+		Reference:   req.Reference,
+	}
+	if req.UserFlow == models.UserFlowQR {
+		resp.QRImageURL = fmt.Sprintf("%s/qr/%s.png", s.URL, req.Reference)
+	}
+	p.createKey, p.created = key, resp
+	writeJSON(w, http.StatusCreated, resp)
+}
+
+// handlePayments serves the endpoints of a single payment
+func (s *Server) handlePayments(w http.ResponseWriter, r *http.Request, path string) {
+	reference, action, _ := strings.Cut(path, "/")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.payments[models.Reference(reference)]
+	if !ok {
+		writeProblem(w, http.StatusNotFound, "Not Found", fmt.Sprintf("payment %s not found", reference))
+		return
+	}
+	now := s.now()
+	p.expire(now)
+
+	switch {
+	case action == "" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, p.GetPaymentResponse)
+	case action == "events" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, p.events)
+	case action == "capture" || action == "refund" || action == "cancel":
+		if r.Method != http.MethodPost {
+			writeProblem(w, http.StatusMethodNotAllowed, "Method Not Allowed", "")
+			return
+		}
+		s.modify(w, r, p, action, now)
+	default:
+		writeProblem(w, http.StatusNotFound, "Not Found", "the fake server does not implement "+r.URL.Path)
+	}
+}
+
+// modify captures, refunds or cancels a payment; the caller must hold the lock
+func (s *Server) modify(w http.ResponseWriter, r *http.Request, p *payment, action string, now time.Time) {
+	key := r.Header.Get("Idempotency-Key")
+	if key != "" {
+		if resp, ok := p.idempotent[action+":"+key]; ok {
+			writeJSON(w, http.StatusOK, resp)
+			return
+		}
+	}
+
+	operation := models.PaymentOperation(strings.ToUpper(action))
+	if !models.IsOperationAllowed(p.State, operation) {
+		writeProblem(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("cannot %s payment %s in state %s", action, p.Reference, p.State))
+		return
+	}
+
+	var req models.ModificationRequest
+	if action != "cancel" {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeProblem(w, http.StatusBadRequest, "Bad Request", err.Error())
+			return
+		}
+		if req.ModificationAmount.Currency != p.Amount.Currency {
+			writeValidationProblem(w, "modificationAmount.currency",
+				fmt.Sprintf("currency %s does not match payment currency %s", req.ModificationAmount.Currency, p.Amount.Currency))
+			return
+		}
+		if req.ModificationAmount.Value <= 0 {
+			writeValidationProblem(w, "modificationAmount.value", "amount must be positive")
+			return
+		}
+	}
+
+	agg := p.Aggregate
+	amount := req.ModificationAmount
+	switch action {
+	case "capture":
+		remaining := agg.AuthorizedAmount.Value - agg.CapturedAmount.Value - agg.CancelledAmount.Value
+		if amount.Value > remaining {
+			writeProblem(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("cannot capture %d, %d available", amount.Value, remaining))
+			return
+		}
+		agg.CapturedAmount.Value += amount.Value
+		p.addEvent(models.EventCaptured, amount, key, now)
+	case "refund":
+		remaining := agg.CapturedAmount.Value - agg.RefundedAmount.Value
+		if amount.Value > remaining {
+			writeProblem(w, http.StatusBadRequest, "Bad Request", fmt.Sprintf("cannot refund %d, %d available", amount.Value, remaining))
+			return
+		}
+		agg.RefundedAmount.Value += amount.Value
+		p.addEvent(models.EventRefunded, amount, key, now)
+	case "cancel":
+		if p.State == models.PaymentStateCreated {
+			p.State = models.PaymentStateTerminated
+			p.addEvent(models.EventTerminated, p.Amount, key, now)
+			break
+		}
+
+		// The amount not captured is released; a partially captured payment stays
+		// authorized, so its captures can still be refunded
+		remaining := agg.AuthorizedAmount.Value - agg.CapturedAmount.Value - agg.CancelledAmount.Value
+		agg.CancelledAmount.Value += remaining
+		if agg.CapturedAmount.Value == 0 {
+			p.State = models.PaymentStateTerminated
+		}
+		p.addEvent(models.EventCancelled, models.Amount{Currency: p.Amount.Currency, Value: remaining}, key, now)
+	}
+
+	resp := p.adjustment()
+	if key != "" {
+		p.idempotent[action+":"+key] = resp
+	}
+	writeJSON(w, http.StatusOK, resp)
+}
+
+// handleTestPayments serves the test-only endpoints, such as force approval
+func (s *Server) handleTestPayments(w http.ResponseWriter, r *http.Request, path string) {
+	reference, action, _ := strings.Cut(path, "/")
+	if action != "approve" || r.Method != http.MethodPost {
+		writeProblem(w, http.StatusNotFound, "Not Found", "the fake server does not implement "+r.URL.Path)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	p, ok := s.payments[models.Reference(reference)]
+	if !ok {
+		writeProblem(w, http.StatusNotFound, "Not Found", fmt.Sprintf("payment %s not found", reference))
+		return
+	}
+	if err := s.approve(p); err != nil {
+		writeProblem(w, http.StatusBadRequest, "Bad Request", err.Error())
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,235 @@
+// Package vippstest provides an in-memory fake of the Vipps MobilePay API for unit tests.
+// The fake server issues access tokens and implements the ePayment API closely enough to
+// exercise payment flows, and its clock can be moved forward to test expiry handling
+// deterministically.
+package vippstest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/client"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+const (
+	// DefaultTokenTTL is how long access tokens issued by the fake server are valid
+	DefaultTokenTTL = time.Hour
+	// DefaultPaymentTTL is how long a payment can be acted upon before it expires,
+	// unless it is created with an explicit ExpiresAt
+	DefaultPaymentTTL = 5 * time.Minute
+)
+
+// Server is a fake Vipps MobilePay API server. Create it with NewServer and close it when done.
+type Server struct {
+	*httptest.Server
+
+	// Validity of issued access tokens and of payments
+	TokenTTL   time.Duration
+	PaymentTTL time.Duration
+
+	// CaptureType of the sales unit; with models.CaptureTypeDirect, payments are captured when
+	// they are approved. Empty means models.CaptureTypeReserve.
+	CaptureType models.CaptureType
+
+	mu       sync.Mutex
+	offset   time.Duration
+	tokens   map[string]time.Time
+	payments map[models.Reference]*payment
+}
+
+// NewServer starts a fake Vipps MobilePay API server
+func NewServer() *Server {
+	s := &Server{
+		TokenTTL:   DefaultTokenTTL,
+		PaymentTTL: DefaultPaymentTTL,
+		tokens:     make(map[string]time.Time),
+		payments:   make(map[models.Reference]*payment),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+
+	return s
+}
+
+// Client returns an API client in test mode that sends its requests to the fake server
+func (s *Server) Client(opts ...client.Option) *client.Client {
+	return client.New(client.Credentials{
+		ClientID:        "test-client-id",
+		ClientSecret:    "test-client-secret",
+		SubscriptionKey: "test-subscription-key",
+		MSN:             "123456",
+	}, append([]client.Option{client.WithTestMode(true), client.WithBaseURL(s.URL)}, opts...)...)
+}
+
+// Now returns the current time of the fake server's clock
+func (s *Server) Now() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.now()
+}
+
+// now returns the current time of the clock; the caller must hold the lock
+func (s *Server) now() time.Time {
+	return time.Now().Add(s.offset)
+}
+
+// AdvanceTime moves the fake server's clock forward. Payments that are not acted upon
+// before their expiry become EXPIRED, and access tokens past their validity are rejected
+// with 401 Unauthorized, so the client has to fetch a new one.
+func (s *Server) AdvanceTime(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.offset += d
+	now := s.now()
+	for _, p := range s.payments {
+		p.expire(now)
+	}
+}
+
+// RevokeTokens revokes all issued access tokens, as when credentials are rotated. Requests
+// carrying a revoked token are rejected with 401 Unauthorized.
+func (s *Server) RevokeTokens() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.tokens = make(map[string]time.Time)
+}
+
+// serveHTTP routes a request to the fake API
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/accesstoken/get" || r.URL.Path == "/miami/v1/token" {
+		s.handleToken(w, r)
+		return
+	}
+
+	if !s.authorized(r) {
+		writeProblem(w, http.StatusUnauthorized, "Unauthorized", "access token is missing, invalid or expired")
+		return
+	}
+
+	switch {
+	case strings.HasPrefix(r.URL.Path, "/management/v1/sales-units/"):
+		s.handleSalesUnit(w, r, strings.TrimPrefix(r.URL.Path, "/management/v1/sales-units/"))
+	case strings.HasPrefix(r.URL.Path, "/epayment/v1/test/payments/"):
+		s.handleTestPayments(w, r, strings.TrimPrefix(r.URL.Path, "/epayment/v1/test/payments/"))
+	case r.URL.Path == "/epayment/v1/payments":
+		s.handleCreate(w, r)
+	case strings.HasPrefix(r.URL.Path, "/epayment/v1/payments/"):
+		s.handlePayments(w, r, strings.TrimPrefix(r.URL.Path, "/epayment/v1/payments/"))
+	default:
+		writeProblem(w, http.StatusNotFound, "Not Found", "the fake server does not implement "+r.URL.Path)
+	}
+}
+
+// handleSalesUnit returns the configuration of the sales unit
+func (s *Server) handleSalesUnit(w http.ResponseWriter, r *http.Request, msn string) {
+	if r.Method != http.MethodGet {
+		writeProblem(w, http.StatusMethodNotAllowed, "Method Not Allowed", "")
+		return
+	}
+
+	s.mu.Lock()
+	captureType := s.CaptureType
+	s.mu.Unlock()
+	if captureType == "" {
+		captureType = models.CaptureTypeReserve
+	}
+
+	writeJSON(w, http.StatusOK, models.SalesUnit{MSN: msn, Name: "Fake sales unit", CaptureType: captureType})
+}
+
+// handleToken issues an access token, from /accesstoken/get or the OAuth token endpoint
+func (s *Server) handleToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeProblem(w, http.StatusMethodNotAllowed, "Method Not Allowed", "")
+		return
+	}
+
+	oauth := r.URL.Path != "/accesstoken/get"
+	if oauth {
+		clientID, clientSecret, ok := r.BasicAuth()
+		if !ok || clientID == "" || clientSecret == "" || r.PostFormValue("grant_type") != "client_credentials" {
+			writeProblem(w, http.StatusUnauthorized, "Unauthorized", "basic authentication and the client_credentials grant are required")
+			return
+		}
+	} else if r.Header.Get("client_id") == "" || r.Header.Get("client_secret") == "" {
+		writeProblem(w, http.StatusUnauthorized, "Unauthorized", "client_id and client_secret are required")
+		return
+	}
+
+	s.mu.Lock()
+	token := uuid.New().String()
+	s.tokens[token] = s.now().Add(s.TokenTTL)
+	s.mu.Unlock()
+
+	if oauth {
+		grant := map[string]interface{}{
+			"access_token": token,
+			"expires_in":   int(s.TokenTTL.Seconds()),
+			"token_type":   "Bearer",
+		}
+		if scope := r.PostFormValue("scope"); scope != "" {
+			grant["scope"] = scope
+		}
+		writeJSON(w, http.StatusOK, grant)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": token,
+		"expires_in":   strconv.Itoa(int(s.TokenTTL.Seconds())),
+		"token_type":   "Bearer",
+	})
+}
+
+// authorized reports whether a request carries a valid access token
+func (s *Server) authorized(r *http.Request) bool {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expiry, ok := s.tokens[token]
+	return ok && s.now().Before(expiry)
+}
+
+// writeJSON writes a JSON response
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+// writeProblem writes an RFC 7807 problem response
+func writeProblem(w http.ResponseWriter, status int, title, detail string, extraDetails ...models.ProblemExtraDetail) {
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(models.ProblemDetail{
+		Type:         "https://developer.vippsmobilepay.com/docs/APIs/epayment-api/api-guide/errors",
+		Title:        title,
+		Status:       status,
+		Detail:       detail,
+		ExtraDetails: extraDetails,
+	})
+}
+
+// writeValidationProblem writes a problem response for a request with an invalid field
+func writeValidationProblem(w http.ResponseWriter, field, reason string) {
+	writeValidationError(w, &models.ValidationError{Problems: []models.FieldProblem{{Field: field, Reason: reason}}})
+}
+
+// writeValidationError writes a 400 problem response listing every problem of a validation error
+func writeValidationError(w http.ResponseWriter, err *models.ValidationError) {
+	details := make([]models.ProblemExtraDetail, len(err.Problems))
+	for i, problem := range err.Problems {
+		details[i] = models.ProblemExtraDetail{Name: problem.Field, Reason: problem.Reason}
+	}
+	writeProblem(w, http.StatusBadRequest, "Bad Request", "One or more validation errors occurred", details...)
+}
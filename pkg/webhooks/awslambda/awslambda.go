@@ -0,0 +1,175 @@
+// Package awslambda adapts the webhook Handler to AWS Lambda functions behind API Gateway
+// REST APIs. The request and response types mirror events.APIGatewayProxyRequest and
+// events.APIGatewayProxyResponse of github.com/aws/aws-lambda-go, so the handler can be
+// passed to lambda.Start without this SDK depending on the AWS libraries.
+package awslambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/webhooks"
+)
+
+// APIGatewayProxyRequest is the event of an API Gateway proxy integration
+type APIGatewayProxyRequest struct {
+	Resource                        string                        `json:"resource"`
+	Path                            string                        `json:"path"`
+	HTTPMethod                      string                        `json:"httpMethod"`
+	Headers                         map[string]string             `json:"headers"`
+	MultiValueHeaders               map[string][]string           `json:"multiValueHeaders"`
+	QueryStringParameters           map[string]string             `json:"queryStringParameters"`
+	MultiValueQueryStringParameters map[string][]string           `json:"multiValueQueryStringParameters"`
+	PathParameters                  map[string]string             `json:"pathParameters"`
+	StageVariables                  map[string]string             `json:"stageVariables"`
+	RequestContext                  APIGatewayProxyRequestContext `json:"requestContext"`
+	Body                            string                        `json:"body"`
+	IsBase64Encoded                 bool                          `json:"isBase64Encoded,omitempty"`
+}
+
+// APIGatewayProxyRequestContext is the part of the request context used by the adapter
+type APIGatewayProxyRequestContext struct {
+	RequestID  string `json:"requestId"`
+	Stage      string `json:"stage"`
+	Path       string `json:"path"` // Path of the request as called, including the stage
+	DomainName string `json:"domainName"`
+}
+
+// APIGatewayProxyResponse is the response of an API Gateway proxy integration
+type APIGatewayProxyResponse struct {
+	StatusCode        int                 `json:"statusCode"`
+	Headers           map[string]string   `json:"headers"`
+	MultiValueHeaders map[string][]string `json:"multiValueHeaders"`
+	Body              string              `json:"body"`
+	IsBase64Encoded   bool                `json:"isBase64Encoded,omitempty"`
+}
+
+// Handler returns a Lambda handler that validates webhook deliveries with h and passes the
+// events to processor, answering exactly as Handler.HandleHTTP does. The signature is
+// validated against the path Vipps MobilePay called, including the API Gateway stage.
+func Handler(h *webhooks.Handler, processor webhooks.EventProcessor) func(ctx context.Context, event APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+	httpHandler := h.HandleHTTP(processor)
+
+	return func(ctx context.Context, event APIGatewayProxyRequest) (APIGatewayProxyResponse, error) {
+		req, err := NewRequest(ctx, event)
+		if err != nil {
+			return APIGatewayProxyResponse{}, err
+		}
+
+		w := newResponseWriter()
+		httpHandler.ServeHTTP(w, req)
+
+		return w.response(), nil
+	}
+}
+
+// NewRequest converts an API Gateway proxy event to an HTTP request
+func NewRequest(ctx context.Context, event APIGatewayProxyRequest) (*http.Request, error) {
+	body := []byte(event.Body)
+	if event.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(event.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode request body: %w", err)
+		}
+		body = decoded
+	}
+
+	path := event.RequestContext.Path
+	if path == "" {
+		path = event.Path
+	}
+
+	header := make(http.Header)
+	for name, values := range event.MultiValueHeaders {
+		for _, value := range values {
+			header.Add(name, value)
+		}
+	}
+	for name, value := range event.Headers {
+		if header.Get(name) == "" {
+			header.Set(name, value)
+		}
+	}
+
+	host := header.Get("Host")
+	if host == "" {
+		host = event.RequestContext.DomainName
+		header.Set("Host", host)
+	}
+
+	query := make(url.Values)
+	for name, values := range event.MultiValueQueryStringParameters {
+		query[name] = values
+	}
+	for name, value := range event.QueryStringParameters {
+		if _, ok := query[name]; !ok {
+			query.Set(name, value)
+		}
+	}
+
+	u := &url.URL{Scheme: "https", Host: host, Path: path, RawQuery: query.Encode()}
+	req, err := http.NewRequestWithContext(ctx, event.HTTPMethod, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header = header
+	req.Host = host
+
+	return req, nil
+}
+
+// responseWriter records the response of an http.Handler
+type responseWriter struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+// newResponseWriter creates a responseWriter
+func newResponseWriter() *responseWriter {
+	return &responseWriter{header: make(http.Header)}
+}
+
+// Header returns the response header
+func (w *responseWriter) Header() http.Header {
+	return w.header
+}
+
+// Write records the response body
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.body.Write(b)
+}
+
+// WriteHeader records the response status
+func (w *responseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+// response converts the recorded response to an API Gateway response
+func (w *responseWriter) response() APIGatewayProxyResponse {
+	status := w.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	headers := make(map[string]string, len(w.header))
+	for name := range w.header {
+		headers[name] = w.header.Get(name)
+	}
+
+	return APIGatewayProxyResponse{
+		StatusCode:        status,
+		Headers:           headers,
+		MultiValueHeaders: w.header,
+		Body:              w.body.String(),
+	}
+}
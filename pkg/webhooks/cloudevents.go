@@ -0,0 +1,77 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// CloudEventsSpecVersion is the version of the CloudEvents specification produced by ToCloudEvent
+const CloudEventsSpecVersion = "1.0"
+
+// CloudEvent is a CloudEvents v1.0 envelope in the structured JSON format, ready to be
+// forwarded to event buses such as Knative Eventing or Amazon EventBridge
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`       // CloudEvents version, always "1.0"
+	ID              string          `json:"id"`                // Identifies the event; stable across redeliveries
+	Source          string          `json:"source"`            // Context the event happened in, per merchant
+	Type            string          `json:"type"`              // Event type, e.g. epayments.payment.captured.v1
+	Subject         string          `json:"subject,omitempty"` // Payment reference
+	Time            time.Time       `json:"time"`              // When the event occurred
+	DataContentType string          `json:"datacontenttype"`   // Content type of Data, always application/json
+	Data            json.RawMessage `json:"data"`              // The webhook event
+}
+
+// CloudEventSource returns the source of the events of a merchant, identified by its MSN
+func CloudEventSource(msn string) string {
+	return "urn:vippsmobilepay:epayment:msn:" + msn
+}
+
+// ToCloudEvent converts a webhook event to a CloudEvents envelope carrying the event as
+// data. The type is the webhook event type, the subject the payment reference and the
+// source identifies the merchant. The ID is derived from the event, so a redelivered
+// event gets the same ID and can be deduplicated by the receiving bus.
+func ToCloudEvent(event *models.WebhookEvent) (*CloudEvent, error) {
+	if event.Name == "" {
+		return nil, fmt.Errorf("webhook event has no name")
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal webhook event: %w", err)
+	}
+
+	return &CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		ID:              cloudEventID(event),
+		Source:          CloudEventSource(event.MSN),
+		Type:            string(event.Name.WebhookEventType()),
+		Subject:         string(event.Reference),
+		Time:            event.Timestamp,
+		DataContentType: "application/json",
+		Data:            data,
+	}, nil
+}
+
+// CloudEventProcessor returns an EventProcessor that converts events to CloudEvents and
+// passes them to publish, e.g. to forward them to an event bus from a FanOut
+func CloudEventProcessor(publish func(event *CloudEvent) error) EventProcessor {
+	return func(event *models.WebhookEvent) error {
+		ce, err := ToCloudEvent(event)
+		if err != nil {
+			return err
+		}
+		return publish(ce)
+	}
+}
+
+// cloudEventID derives a stable ID from the identifying fields of an event
+func cloudEventID(event *models.WebhookEvent) string {
+	id := fmt.Sprintf("%s/%s/%s", event.Reference, event.Name, event.PSPReference)
+	if event.IdempotencyKey != "" {
+		id += "/" + event.IdempotencyKey
+	}
+	return id
+}
@@ -0,0 +1,69 @@
+package webhooks
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+const (
+	// DefaultDeliveryIDHeader is the header the delivery ID is read from by default
+	DefaultDeliveryIDHeader = "Webhook-Id"
+	// DefaultAttemptHeader is the header the delivery attempt is read from by default
+	DefaultAttemptHeader = "Webhook-Attempt"
+)
+
+// EventContext is a webhook event with the metadata of its delivery, so handlers can make
+// retry and deduplication decisions, e.g. alert on a late attempt or skip a known delivery
+type EventContext struct {
+	*models.WebhookEvent
+	MSN        string      // Merchant serial number the event was sent for
+	DeliveryID string      // ID of the delivery, shared by its retries; empty if the header is absent
+	Attempt    int         // Delivery attempt, starting at 1; 0 if the header is absent
+	TraceID    string      // Trace ID of the W3C traceparent header; empty if absent
+	ReceivedAt time.Time   // When the delivery was received
+	Header     http.Header // Headers of the delivery request
+}
+
+// ContextProcessor is a function that processes a webhook event with its delivery metadata
+type ContextProcessor func(*EventContext) error
+
+// newEventContext returns the context of an event delivered with the request
+func (h *Handler) newEventContext(r *http.Request, event *models.WebhookEvent, receivedAt time.Time) *EventContext {
+	deliveryIDHeader := h.DeliveryIDHeader
+	if deliveryIDHeader == "" {
+		deliveryIDHeader = DefaultDeliveryIDHeader
+	}
+	attemptHeader := h.AttemptHeader
+	if attemptHeader == "" {
+		attemptHeader = DefaultAttemptHeader
+	}
+
+	ec := &EventContext{
+		WebhookEvent: event,
+		MSN:          event.MSN,
+		DeliveryID:   r.Header.Get(deliveryIDHeader),
+		TraceID:      traceID(r.Header.Get("Traceparent")),
+		ReceivedAt:   receivedAt,
+		Header:       r.Header,
+	}
+	if ec.MSN == "" {
+		ec.MSN = r.Header.Get("Merchant-Serial-Number")
+	}
+	if attempt, err := strconv.Atoi(r.Header.Get(attemptHeader)); err == nil && attempt > 0 {
+		ec.Attempt = attempt
+	}
+	return ec
+}
+
+// traceID returns the trace ID of a W3C traceparent header, version-traceid-parentid-flags
+func traceID(traceparent string) string {
+	parts := strings.Split(traceparent, "-")
+	if len(parts) < 4 || len(parts[1]) != 32 {
+		return ""
+	}
+	return parts[1]
+}
@@ -0,0 +1,36 @@
+package webhooks
+
+import (
+	"fmt"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// ContextLoader returns the context of payments recorded when they were created, such as a
+// client.PaymentContextStore
+type ContextLoader interface {
+	// Load returns the context of a payment, or nil if none was recorded
+	Load(reference models.Reference) (*models.PaymentContext, error)
+}
+
+// EnrichedEvent is a webhook event joined with the context of its payment
+type EnrichedEvent struct {
+	*models.WebhookEvent
+	Context *models.PaymentContext // Context of the payment; nil if none was recorded, e.g. for payments created elsewhere
+}
+
+// EnrichedProcessor is a function that processes an enriched webhook event
+type EnrichedProcessor func(*EnrichedEvent) error
+
+// Enrich returns a processor joining each event with the context of its payment, e.g. its
+// order ID, user flow and description, before passing it to next. If the context cannot be
+// loaded, the delivery fails so Vipps MobilePay retries it.
+func Enrich(loader ContextLoader, next EnrichedProcessor) EventProcessor {
+	return func(event *models.WebhookEvent) error {
+		paymentContext, err := loader.Load(event.Reference)
+		if err != nil {
+			return fmt.Errorf("failed to load context of payment %s: %w", event.Reference, err)
+		}
+		return next(&EnrichedEvent{WebhookEvent: event, Context: paymentContext})
+	}
+}
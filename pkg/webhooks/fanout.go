@@ -0,0 +1,67 @@
+package webhooks
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// FanOut dispatches each event to multiple processors, e.g. a business router, an audit
+// router and a metrics router. Processors run in the order they were added, and every
+// processor sees every event regardless of whether the others fail.
+type FanOut struct {
+	targets []fanOutTarget
+	onError func(name string, event *models.WebhookEvent, err error)
+}
+
+// fanOutTarget is a processor registered on a FanOut
+type fanOutTarget struct {
+	name      string
+	processor EventProcessor
+	required  bool
+}
+
+// NewFanOut creates a new webhook fan-out
+func NewFanOut() *FanOut {
+	return &FanOut{}
+}
+
+// Add registers a required processor. If it fails, the delivery fails and Vipps MobilePay
+// retries the event, which is then dispatched to all processors again.
+func (f *FanOut) Add(name string, processor EventProcessor) {
+	f.targets = append(f.targets, fanOutTarget{name: name, processor: processor, required: true})
+}
+
+// AddOptional registers a best-effort processor. Its failures are reported to the
+// OnError callback but do not fail the delivery.
+func (f *FanOut) AddOptional(name string, processor EventProcessor) {
+	f.targets = append(f.targets, fanOutTarget{name: name, processor: processor})
+}
+
+// OnError registers a callback that is called for every failing processor
+func (f *FanOut) OnError(fn func(name string, event *models.WebhookEvent, err error)) {
+	f.onError = fn
+}
+
+// Process dispatches an event to all registered processors. It returns an error
+// combining the failures of the required processors.
+func (f *FanOut) Process(event *models.WebhookEvent) error {
+	var errs []error
+
+	for _, target := range f.targets {
+		err := target.processor(event)
+		if err == nil {
+			continue
+		}
+
+		if f.onError != nil {
+			f.onError(target.name, event, err)
+		}
+		if target.required {
+			errs = append(errs, fmt.Errorf("%s: %w", target.name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,52 @@
+// Package gcf adapts the webhook Handler to Google Cloud Functions HTTP functions
+package gcf
+
+import (
+	"net/http"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/webhooks"
+)
+
+// config holds the options of a Cloud Function
+type config struct {
+	path string
+}
+
+// Option configures a Cloud Function
+type Option func(*config)
+
+// WithPath sets the path Vipps MobilePay calls, e.g. "/vipps-webhook" for a function named
+// vipps-webhook. Cloud Functions strips the function name from the request path, but the
+// signature covers the path as called, so it must be restored for validation.
+func WithPath(path string) Option {
+	return func(c *config) {
+		c.path = path
+	}
+}
+
+// Function returns an HTTP Cloud Function that validates webhook deliveries with h and
+// passes the events to processor, answering exactly as Handler.HandleHTTP does. Register
+// it with functions.HTTP or export it from the function's package.
+func Function(h *webhooks.Handler, processor webhooks.EventProcessor, opts ...Option) func(w http.ResponseWriter, r *http.Request) {
+	cfg := &config{}
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	httpHandler := h.HandleHTTP(processor)
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		r = r.Clone(r.Context())
+
+		// The Go HTTP server moves the Host header to Request.Host, but the signature covers it
+		if r.Header.Get("Host") == "" {
+			r.Header.Set("Host", r.Host)
+		}
+		if cfg.path != "" {
+			r.URL.Path = cfg.path
+			r.URL.RawPath = ""
+		}
+
+		httpHandler(w, r)
+	}
+}
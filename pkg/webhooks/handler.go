@@ -2,21 +2,74 @@
 package webhooks
 
 import (
-	"crypto/hmac"
+	"bytes"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/redact"
 )
 
+// DefaultMaxBodyBytes is the default limit for the size of webhook request bodies
+const DefaultMaxBodyBytes = 1 << 20
+
 // Handler processes webhook events from Vipps MobilePay
 type Handler struct {
 	SecretKey string
+
+	// MaxBodyBytes limits the size of request bodies; zero means DefaultMaxBodyBytes.
+	// Webhook endpoints are public, so bodies are never read without a bound.
+	MaxBodyBytes int64
+
+	// ProbeStatus is the status answering GET and HEAD requests to the webhook path, e.g. from
+	// uptime checkers probing the URL; zero means 405 Method Not Allowed. Set it to 200 to report
+	// the endpoint as healthy. Probes never reach the event handler.
+	ProbeStatus int
+
+	// Codec decodes event payloads; nil means encoding/json. A client.Codec can be used, so
+	// payments and webhooks share one implementation.
+	Codec Codec
+
+	// RequireHTTPS rejects deliveries that did not arrive over HTTPS, catching ingress that
+	// terminates TLS incorrectly or forwards plain HTTP. Enable it in production.
+	RequireHTTPS bool
+
+	// TrustForwardedHeaders takes the scheme from the X-Forwarded-Proto and Forwarded headers
+	// when the request did not arrive over TLS, e.g. behind a load balancer, API Gateway or
+	// Cloud Functions terminating TLS. Only enable it if the proxy sets these headers, since
+	// clients could otherwise spoof them.
+	TrustForwardedHeaders bool
+
+	// DeliveryIDHeader and AttemptHeader name the headers the delivery ID and attempt number
+	// of an EventContext are read from; empty means DefaultDeliveryIDHeader and
+	// DefaultAttemptHeader. Set them to the headers your sender or ingress provides.
+	DeliveryIDHeader string
+	AttemptHeader    string
+
+	// StrictSignature validates the signature of every delivery, rejecting all of them if
+	// no secret key is set, rejects bodies that do not match the signed content hash, signs
+	// with Request.Host if there is no Host header, and only signs with the X-Forwarded-Host
+	// header if TrustForwardedHeaders is set
+	StrictSignature bool
+
+	// MaxClockSkew rejects deliveries whose signed X-Ms-Date is further from the current
+	// time than this, so captured deliveries cannot be replayed later; zero disables the check
+	MaxClockSkew time.Duration
+
+	// Quiet suppresses the diagnostics logged with the standard logger when a signature does not match
+	Quiet bool
+}
+
+// Codec decodes webhook event payloads, e.g. with a faster drop-in replacement for encoding/json
+type Codec interface {
+	Unmarshal(data []byte, v interface{}) error
 }
 
 // NewHandler creates a new webhook handler
@@ -26,120 +79,263 @@ func NewHandler(secretKey string) *Handler {
 	}
 }
 
+// readBody reads the request body up to the size limit and restores it for later reading
+func (h *Handler) readBody(r *http.Request) ([]byte, error) {
+	if r.Body == nil {
+		return nil, reject(http.StatusBadRequest, RejectInvalidBody, "Missing body", "the request has no body")
+	}
+
+	limit := h.MaxBodyBytes
+	if limit <= 0 {
+		limit = DefaultMaxBodyBytes
+	}
+
+	// Size the buffer from Content-Length, so a body is read with a single allocation; the
+	// extra byte lets the final read report io.EOF without growing the buffer
+	size := int64(bytes.MinRead)
+	if r.ContentLength > 0 && r.ContentLength <= limit {
+		size = r.ContentLength + 1
+	}
+
+	body := make([]byte, 0, size)
+	for {
+		if len(body) == cap(body) {
+			body = append(body, 0)[:len(body)]
+		}
+		n, err := r.Body.Read(body[len(body):cap(body)])
+		body = body[:len(body)+n]
+		if int64(len(body)) > limit {
+			return nil, reject(http.StatusRequestEntityTooLarge, RejectBodyTooLarge, "Body too large",
+				fmt.Sprintf("the request body exceeds %d bytes", limit))
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+
+	restored := &restoredBody{}
+	restored.Reset(body)
+	r.Body = restored
+	return body, nil
+}
+
+// restoredBody replays a request body that has been read, in one allocation instead of the
+// two of io.NopCloser(bytes.NewReader(body))
+type restoredBody struct {
+	bytes.Reader
+}
+
+// Close does nothing, since the original body has been read completely
+func (*restoredBody) Close() error {
+	return nil
+}
+
 // ValidateSignature validates the signature of a webhook event
 func (h *Handler) ValidateSignature(r *http.Request) error {
-	// First, verify the content hash
-	body, err := io.ReadAll(r.Body)
+	if r.URL == nil {
+		return fmt.Errorf("missing request URL")
+	}
+
+	body, err := h.readBody(r)
 	if err != nil {
-		return fmt.Errorf("failed to read request body: %w", err)
+		return err
 	}
+	return h.validateSignature(r, body)
+}
 
-	// Restore the body for later reading
-	r.Body = io.NopCloser(strings.NewReader(string(body)))
+// validateSignature validates the signature of a webhook event with the body already read.
+// It runs for every delivery, so the expected values are computed into pooled buffers and
+// compared in their binary form, without building strings.
+func (h *Handler) validateSignature(r *http.Request, body []byte) error {
+	if r.URL == nil {
+		return fmt.Errorf("missing request URL")
+	}
 
-	// Compute SHA256 hash of the body
-	contentHash := sha256.Sum256(body)
-	expectedContentHash := base64.StdEncoding.EncodeToString(contentHash[:])
+	// Anyone can sign with an empty key
+	if h.StrictSignature && h.SecretKey == "" {
+		return reject(http.StatusUnauthorized, RejectInvalidSignature, "Invalid signature",
+			"no secret key is configured to validate the signature")
+	}
 
-	// Check if content hash matches
-	actualContentHash := r.Header.Get("X-Ms-Content-Sha256")
+	// First, verify the content hash
+	actualContentHash := headerValue(r.Header, "X-Ms-Content-Sha256")
 	if actualContentHash == "" {
-		return fmt.Errorf("missing X-Ms-Content-Sha256 header")
+		return reject(http.StatusUnauthorized, RejectMissingHeader, "Missing header",
+			"the X-Ms-Content-Sha256 header is required")
+	}
+	date := headerValue(r.Header, "X-Ms-Date")
+	if err := h.checkClockSkew(date); err != nil {
+		return err
 	}
 
-	if expectedContentHash != actualContentHash {
-		fmt.Printf("Content hash mismatch: expected %s, got %s\n",
-			expectedContentHash, actualContentHash)
+	s := getSigner(h.SecretKey)
+	defer putSigner(s)
+
+	contentHash := sha256.Sum256(body)
+	if !s.matchesBase64(contentHash[:], actualContentHash) {
+		if h.StrictSignature {
+			return reject(http.StatusUnauthorized, RejectContentHashMismatch, "Content hash mismatch",
+				"the body does not match the X-Ms-Content-Sha256 header")
+		}
+		if !h.Quiet {
+			log.Printf("Webhook content hash mismatch: expected %s, got %s",
+				base64.StdEncoding.EncodeToString(contentHash[:]), actualContentHash)
+		}
 		// For debugging, continue even if this doesn't match
 	}
 
 	// Get authorization header (could be either Authorization or X-Vipps-Authorization)
-	authHeader := r.Header.Get("Authorization")
+	authHeader := headerValue(r.Header, "Authorization")
 	if authHeader == "" {
-		authHeader = r.Header.Get("X-Vipps-Authorization")
+		authHeader = headerValue(r.Header, "X-Vipps-Authorization")
 		if authHeader == "" {
-			return fmt.Errorf("missing Authorization or X-Vipps-Authorization header")
+			return reject(http.StatusUnauthorized, RejectMissingHeader, "Missing header",
+				"the Authorization or X-Vipps-Authorization header is required")
 		}
 	}
 
 	// Get the host from the X-Forwarded-Host header if available, otherwise use the Host header
-	host := r.Header.Get("X-Forwarded-Host")
+	var host string
+	if !h.StrictSignature || h.TrustForwardedHeaders {
+		host = headerValue(r.Header, "X-Forwarded-Host")
+	}
 	if host == "" {
-		host = r.Header.Get("Host")
+		host = headerValue(r.Header, "Host")
+	}
+	if host == "" && h.StrictSignature {
+		// The Go HTTP server moves the Host header to Request.Host
+		host = r.Host
 	}
 
-	// Construct the string to be signed exactly as in the C# example
-	signedString := fmt.Sprintf("%s\n%s\n%s;%s;%s",
-		r.Method,
-		r.URL.Path, // This should be the path only, not the full URI with query params
-		r.Header.Get("X-Ms-Date"),
-		host,
-		r.Header.Get("X-Ms-Content-Sha256"))
-
-	// Compute HMAC-SHA256
-	mac := hmac.New(sha256.New, []byte(h.SecretKey))
-	mac.Write([]byte(signedString))
-	expectedSignatureBytes := mac.Sum(nil)
-	expectedSignature := base64.StdEncoding.EncodeToString(expectedSignatureBytes)
-
-	// Format the expected authorization header exactly as in the C# example
-	expectedAuthHeader := fmt.Sprintf("HMAC-SHA256 SignedHeaders=x-ms-date;host;x-ms-content-sha256&Signature=%s", expectedSignature)
+	// The signed string is "METHOD\nPATH\nDATE;HOST;CONTENT-HASH", the path without query parameters
+	signature := s.sign(r.Method, r.URL.Path, date, host, actualContentHash)
 
-	if expectedAuthHeader != authHeader {
+	actualSignature, ok := strings.CutPrefix(authHeader, authorizationPrefix)
+	if !ok || !s.matchesBase64(signature, actualSignature) {
 		// Log the error but return an actual error
-		fmt.Printf("Auth header mismatch:\nExpected: %s\nActual:   %s\n",
-			expectedAuthHeader, authHeader)
-		return fmt.Errorf("signature validation failed")
+		// Only the signed headers are logged; the signatures are redacted
+		if !h.Quiet {
+			expectedAuthHeader := authorizationPrefix + base64.StdEncoding.EncodeToString(signature)
+			log.Printf("Webhook auth header mismatch: expected %s, got %s",
+				redact.String(expectedAuthHeader), redact.String(authHeader))
+		}
+		return reject(http.StatusUnauthorized, RejectInvalidSignature, "Invalid signature",
+			"the signature does not match the request")
 	}
 
-	fmt.Println("Signature validation successful")
 	return nil
 }
 
+// Scheme returns the scheme the request arrived with: "https" if it arrived over TLS or, with
+// TrustForwardedHeaders, if the proxy reports that it did, and "http" otherwise
+func (h *Handler) Scheme(r *http.Request) string {
+	if r.TLS != nil {
+		return "https"
+	}
+	if !h.TrustForwardedHeaders {
+		return "http"
+	}
+
+	// The first entry was added by the proxy closest to the client
+	if proto := r.Header.Get("X-Forwarded-Proto"); proto != "" {
+		first, _, _ := strings.Cut(proto, ",")
+		return strings.ToLower(strings.TrimSpace(first))
+	}
+	if forwarded := r.Header.Get("Forwarded"); forwarded != "" {
+		first, _, _ := strings.Cut(forwarded, ",")
+		for _, pair := range strings.Split(first, ";") {
+			name, value, _ := strings.Cut(strings.TrimSpace(pair), "=")
+			if strings.EqualFold(name, "proto") {
+				return strings.ToLower(strings.Trim(value, `"`))
+			}
+		}
+	}
+	return "http"
+}
+
 // ParseEvent parses a webhook event from an HTTP request
 func (h *Handler) ParseEvent(r *http.Request) (*models.WebhookEvent, error) {
-	// Validate the signature if a secret key is provided
-	if h.SecretKey != "" {
-		if err := h.ValidateSignature(r); err != nil {
-			return nil, fmt.Errorf("signature validation failed: %w", err)
-		}
+	if h.RequireHTTPS && h.Scheme(r) != "https" {
+		return nil, reject(http.StatusForbidden, RejectInsecureTransport, "Insecure transport",
+			"webhook deliveries must arrive over HTTPS")
 	}
 
 	// Read the request body
-	body, err := io.ReadAll(r.Body)
+	body, err := h.readBody(r)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read request body: %w", err)
+		return nil, err
+	}
+
+	// Validate the signature if a secret key is provided
+	if h.SecretKey != "" || h.StrictSignature {
+		if err := h.validateSignature(r, body); err != nil {
+			return nil, fmt.Errorf("signature validation failed: %w", err)
+		}
 	}
 
 	// Parse the event
 	var event models.WebhookEvent
-	if err := json.Unmarshal(body, &event); err != nil {
-		return nil, fmt.Errorf("failed to parse event: %w", err)
+	if err := h.unmarshal(body, &event); err != nil {
+		return nil, reject(http.StatusBadRequest, RejectInvalidBody, "Invalid body",
+			fmt.Sprintf("failed to parse event: %v", err))
 	}
 
 	return &event, nil
 }
 
-// HandleHTTP creates an http.HandlerFunc that processes webhook events
+// unmarshal decodes a payload with the handler's codec
+func (h *Handler) unmarshal(data []byte, v interface{}) error {
+	if h.Codec != nil {
+		return h.Codec.Unmarshal(data, v)
+	}
+	return json.Unmarshal(data, v)
+}
+
+// HandleHTTP creates an http.HandlerFunc that processes webhook events.
+// Rejected deliveries are answered with an application/problem+json body whose code
+// tells precisely why the delivery was rejected.
 func (h *Handler) HandleHTTP(handler func(event *models.WebhookEvent) error) http.HandlerFunc {
+	return h.HandleHTTPContext(func(ec *EventContext) error {
+		return handler(ec.WebhookEvent)
+	})
+}
+
+// HandleHTTPContext creates an http.HandlerFunc that processes webhook events with the
+// metadata of their delivery, such as the attempt number, e.g. with Router.ProcessContext.
+// It answers deliveries exactly as HandleHTTP does.
+func (h *Handler) HandleHTTPContext(handler ContextProcessor) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		receivedAt := time.Now()
+
+		// Answer health probes without invoking the handler
+		if (r.Method == http.MethodGet || r.Method == http.MethodHead) && h.ProbeStatus != 0 &&
+			h.ProbeStatus != http.StatusMethodNotAllowed {
+			w.WriteHeader(h.ProbeStatus)
+			return
+		}
+
 		// Only allow POST requests
 		if r.Method != http.MethodPost {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			w.Header().Set("Allow", http.MethodPost)
+			writeProblem(w, reject(http.StatusMethodNotAllowed, RejectMethodNotAllowed, "Method not allowed",
+				fmt.Sprintf("method %s is not allowed, use POST", r.Method)), 0, "", "")
 			return
 		}
 
 		// Parse the event
 		event, err := h.ParseEvent(r)
 		if err != nil {
-			http.Error(w, fmt.Sprintf("Failed to parse event: %v", err), http.StatusBadRequest)
+			writeProblem(w, err, http.StatusBadRequest, RejectInvalidBody, "Failed to parse event")
 			return
 		}
 
 		// Process the event
-		if err := handler(event); err != nil {
+		if err := handler(h.newEventContext(r, event, receivedAt)); err != nil {
 			// Return a 5xx error so Vipps MobilePay will retry
-			http.Error(w, fmt.Sprintf("Failed to process event: %v", err), http.StatusInternalServerError)
+			writeProblem(w, err, http.StatusInternalServerError, RejectProcessingFailed, "Failed to process event")
 			return
 		}
 
@@ -153,25 +349,39 @@ type EventProcessor func(*models.WebhookEvent) error
 
 // Router routes webhook events to different handlers based on event type
 type Router struct {
-	handlers map[models.PaymentEventName]EventProcessor
+	handlers map[models.PaymentEventName]ContextProcessor
 	fallback EventProcessor
+
+	// Staleness policy: events older than maxAge go to stale instead of their handler
+	maxAge time.Duration
+	stale  EventProcessor
+	now    func() time.Time
 }
 
 // NewRouter creates a new webhook router
 func NewRouter() *Router {
 	return &Router{
-		handlers: make(map[models.PaymentEventName]EventProcessor),
+		handlers: make(map[models.PaymentEventName]ContextProcessor),
+		now:      time.Now,
 	}
 }
 
 // Handle registers a handler for a specific event type
 func (r *Router) Handle(eventName models.PaymentEventName, handler EventProcessor) {
-	r.handlers[eventName] = handler
+	r.handlers[eventName] = func(ec *EventContext) error {
+		return handler(ec.WebhookEvent)
+	}
 }
 
 // HandleFunc registers a handler function for a specific event type
 func (r *Router) HandleFunc(eventName models.PaymentEventName, handlerFunc func(*models.WebhookEvent) error) {
-	r.handlers[eventName] = handlerFunc
+	r.Handle(eventName, handlerFunc)
+}
+
+// HandleContext registers a handler for a specific event type that receives the delivery
+// metadata of events, see EventContext. Events routed with Process carry no metadata.
+func (r *Router) HandleContext(eventName models.PaymentEventName, handler ContextProcessor) {
+	r.handlers[eventName] = handler
 }
 
 // HandleDefault registers a fallback handler for unhandled event types
@@ -179,11 +389,46 @@ func (r *Router) HandleDefault(handler EventProcessor) {
 	r.fallback = handler
 }
 
+// SetMaxEventAge sets the maximum age of events, based on their Timestamp, that are routed to
+// their handlers. Older events, e.g. an AUTHORIZED event delivered after the authorization
+// expired, are passed to the stale handler or dropped. Zero disables the check.
+func (r *Router) SetMaxEventAge(maxAge time.Duration) {
+	r.maxAge = maxAge
+}
+
+// HandleStale registers a handler for events older than the maximum event age, e.g. to flag
+// them for manual review or to log them. Without a stale handler, stale events are silently
+// acknowledged and dropped.
+func (r *Router) HandleStale(handler EventProcessor) {
+	r.stale = handler
+}
+
+// IsStale reports whether an event is older than the maximum event age
+func (r *Router) IsStale(event *models.WebhookEvent) bool {
+	if r.maxAge <= 0 || event.Timestamp.IsZero() {
+		return false
+	}
+	return r.now().Sub(event.Timestamp) > r.maxAge
+}
+
 // Process routes an event to the appropriate handler
 func (r *Router) Process(event *models.WebhookEvent) error {
-	fmt.Println("Processing event:", event.Name)
+	return r.ProcessContext(&EventContext{WebhookEvent: event, MSN: event.MSN})
+}
+
+// ProcessContext routes an event with its delivery metadata to the appropriate handler. Use
+// it with Handler.HandleHTTPContext.
+func (r *Router) ProcessContext(ec *EventContext) error {
+	event := ec.WebhookEvent
+	if r.IsStale(event) {
+		if r.stale != nil {
+			return r.stale(event)
+		}
+		return nil
+	}
+
 	if handler, ok := r.handlers[event.Name]; ok {
-		return handler(event)
+		return handler(ec)
 	}
 
 	if r.fallback != nil {
@@ -0,0 +1,139 @@
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+const testSecret = "test-secret"
+
+var testBody = []byte(`{"msn":"123456","reference":"order-123","pspReference":"7686f7788898767977","name":"AUTHORIZED","amount":{"currency":"NOK","value":1000},"timestamp":"2024-01-01T12:00:00Z","success":true}`)
+
+// newSignedRequest returns a webhook delivery signed with the secret key
+func newSignedRequest(secret string, body []byte) *http.Request {
+	r, _ := http.NewRequest(http.MethodPost, "https://example.com/webhooks?ignored=1", bytes.NewReader(body))
+	sum := sha256.Sum256(body)
+	contentHash := base64.StdEncoding.EncodeToString(sum[:])
+	date := "Mon, 01 Jan 2024 12:00:00 GMT"
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("POST\n/webhooks\n" + date + ";example.com;" + contentHash))
+
+	r.Header.Set("Host", "example.com")
+	r.Header.Set("X-Ms-Date", date)
+	r.Header.Set("X-Ms-Content-Sha256", contentHash)
+	r.Header.Set("Authorization", authorizationPrefix+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func TestValidateSignature(t *testing.T) {
+	h := &Handler{SecretKey: testSecret, Quiet: true}
+	if err := h.ValidateSignature(newSignedRequest(testSecret, testBody)); err != nil {
+		t.Fatalf("valid signature rejected: %v", err)
+	}
+	if err := h.ValidateSignature(newSignedRequest("other-secret", testBody)); err == nil {
+		t.Fatal("signature with another secret accepted")
+	}
+}
+
+func FuzzValidateSignature(f *testing.F) {
+	valid := newSignedRequest(testSecret, testBody)
+	f.Add("POST", "/webhooks", valid.Header.Get("Authorization"), valid.Header.Get("X-Ms-Content-Sha256"),
+		valid.Header.Get("X-Ms-Date"), "example.com", testBody)
+	f.Add("POST", "/webhooks", "", "", "", "", []byte{})
+	f.Add("POST", "/webhooks", "HMAC-SHA256", "=", "not a date", "example.com", []byte("{"))
+	f.Add("POST", "/webhooks", authorizationPrefix, "AAAA", "", "", []byte("null"))
+	f.Add("POST", "/webhooks", authorizationPrefix+"%%%%", "\x00\xff", ";;;", ";", []byte{0xff, 0xfe})
+	f.Add("GET", "", "Bearer token", "a\nb", "Mon, 01 Jan 2024 12:00:00 GMT", "a;b", bytes.Repeat([]byte("x"), 1024))
+
+	f.Fuzz(func(t *testing.T, method, path, auth, contentHash, date, host string, body []byte) {
+		r := &http.Request{
+			Method: method,
+			URL:    &url.URL{Path: path},
+			Header: http.Header{},
+			Body:   http.NoBody,
+		}
+		if body != nil {
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		r.Header.Set("Authorization", auth)
+		r.Header.Set("X-Ms-Content-Sha256", contentHash)
+		r.Header.Set("X-Ms-Date", date)
+		r.Header.Set("Host", host)
+
+		for _, h := range []*Handler{
+			{SecretKey: testSecret, Quiet: true, MaxBodyBytes: 4096},
+			{SecretKey: testSecret, StrictSignature: true, MaxClockSkew: DefaultMaxClockSkew, MaxBodyBytes: 4096},
+		} {
+			if err := h.ValidateSignature(r); err == nil && auth != valid.Header.Get("Authorization") {
+				t.Fatalf("forged signature %q accepted", auth)
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+		}
+	})
+}
+
+func FuzzParseEvent(f *testing.F) {
+	f.Add(testBody, false)
+	f.Add([]byte{}, false)
+	f.Add([]byte("{"), false)
+	f.Add([]byte("null"), false)
+	f.Add([]byte(`{"amount":{"value":"1000"}}`), false)
+	f.Add([]byte(`{"timestamp":"yesterday"}`), true)
+	f.Add([]byte(`[{"name":"AUTHORIZED"}]`), true)
+	f.Add([]byte(`{"name":"`+string(bytes.Repeat([]byte("A"), 2048))+`"}`), true)
+
+	f.Fuzz(func(t *testing.T, body []byte, signed bool) {
+		h := &Handler{Quiet: true, MaxBodyBytes: 4096}
+		r := newSignedRequest(testSecret, body)
+		if signed {
+			h.SecretKey = testSecret
+		}
+
+		event, err := h.ParseEvent(r)
+		if err == nil && event == nil {
+			t.Fatal("no event and no error")
+		}
+		if len(body) > 4096 && err == nil {
+			t.Fatalf("body of %d bytes accepted", len(body))
+		}
+	})
+}
+
+func BenchmarkValidateSignature(b *testing.B) {
+	h := &Handler{SecretKey: testSecret}
+	r := newSignedRequest(testSecret, testBody)
+	body := &restoredBody{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		body.Reset(testBody)
+		r.Body = body
+		if err := h.ValidateSignature(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParseEvent(b *testing.B) {
+	h := &Handler{SecretKey: testSecret}
+	r := newSignedRequest(testSecret, testBody)
+	body := &restoredBody{}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		body.Reset(testBody)
+		r.Body = body
+		if _, err := h.ParseEvent(r); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
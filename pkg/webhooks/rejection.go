@@ -0,0 +1,83 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// Rejection codes identify why a webhook delivery was rejected
+const (
+	// RejectMethodNotAllowed means the request did not use POST
+	RejectMethodNotAllowed = "method-not-allowed"
+	// RejectInsecureTransport means the request did not arrive over HTTPS while RequireHTTPS is set
+	RejectInsecureTransport = "insecure-transport"
+	// RejectMissingHeader means a header required for signature validation is missing
+	RejectMissingHeader = "missing-header"
+	// RejectInvalidSignature means the signature does not match the request
+	RejectInvalidSignature = "invalid-signature"
+	// RejectContentHashMismatch means the body does not match the signed content hash while
+	// StrictSignature is set
+	RejectContentHashMismatch = "content-hash-mismatch"
+	// RejectClockSkew means the X-Ms-Date header is missing, invalid or further from the
+	// current time than MaxClockSkew
+	RejectClockSkew = "clock-skew"
+	// RejectBodyTooLarge means the request body exceeds the size limit
+	RejectBodyTooLarge = "body-too-large"
+	// RejectInvalidBody means the request body is missing or cannot be parsed as an event
+	RejectInvalidBody = "invalid-body"
+	// RejectProcessingFailed means the event was valid but the event handler failed
+	RejectProcessingFailed = "processing-failed"
+)
+
+// problemTypePrefix prefixes the rejection code in the problem type URI
+const problemTypePrefix = "urn:vipps-mobilepay-sdk:webhook:"
+
+// RejectionError describes why a webhook delivery was rejected. HandleHTTP writes it
+// as an application/problem+json response.
+type RejectionError struct {
+	Status int    // HTTP status code of the response
+	Code   string // Machine-readable rejection code, one of the Reject constants
+	Title  string // Short summary of the rejection
+	Detail string // Explanation specific to this delivery
+}
+
+// Error implements the error interface
+func (e *RejectionError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Title, e.Detail)
+}
+
+// Problem returns the rejection as RFC 7807 problem details
+func (e *RejectionError) Problem() models.ProblemDetail {
+	return models.ProblemDetail{
+		Type:   problemTypePrefix + e.Code,
+		Title:  e.Title,
+		Status: e.Status,
+		Detail: e.Detail,
+		Code:   e.Code,
+	}
+}
+
+// reject creates a RejectionError
+func reject(status int, code, title, detail string) *RejectionError {
+	return &RejectionError{Status: status, Code: code, Title: title, Detail: detail}
+}
+
+// writeProblem writes an error as an application/problem+json response. Errors that are
+// not a RejectionError are reported with the fallback status and code.
+func writeProblem(w http.ResponseWriter, err error, status int, code, title string) {
+	var rejection *RejectionError
+	if !errors.As(err, &rejection) {
+		rejection = reject(status, code, title, err.Error())
+	}
+
+	body, _ := json.Marshal(rejection.Problem())
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(rejection.Status)
+	w.Write(body)
+}
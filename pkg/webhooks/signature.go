@@ -0,0 +1,89 @@
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"hash"
+	"net/http"
+	"sync"
+)
+
+// authorizationPrefix precedes the signature in the Authorization header of a delivery
+const authorizationPrefix = "HMAC-SHA256 SignedHeaders=x-ms-date;host;x-ms-content-sha256&Signature="
+
+// signer computes webhook signatures with reusable buffers, so computing a signature does
+// not allocate; validating a delivery only allocates the body it reads and restores
+type signer struct {
+	key     string
+	mac     hash.Hash
+	message []byte
+	sum     []byte
+	encoded []byte
+}
+
+// signers pools signers between deliveries
+var signers sync.Pool
+
+// getSigner returns a pooled signer for the secret key
+func getSigner(key string) *signer {
+	if s, ok := signers.Get().(*signer); ok && s.key == key {
+		return s
+	}
+	return &signer{key: key, mac: hmac.New(sha256.New, []byte(key))}
+}
+
+// putSigner returns a signer to the pool
+func putSigner(s *signer) {
+	signers.Put(s)
+}
+
+// sign returns the HMAC-SHA256 of the signed string of a delivery. The result is only valid
+// until the signer is used again.
+func (s *signer) sign(method, path, date, host, contentHash string) []byte {
+	m := s.message[:0]
+	m = append(m, method...)
+	m = append(m, '\n')
+	m = append(m, path...)
+	m = append(m, '\n')
+	m = append(m, date...)
+	m = append(m, ';')
+	m = append(m, host...)
+	m = append(m, ';')
+	m = append(m, contentHash...)
+	s.message = m
+
+	s.mac.Reset()
+	s.mac.Write(m)
+	s.sum = s.mac.Sum(s.sum[:0])
+	return s.sum
+}
+
+// matchesBase64 reports in constant time whether encoded is the standard base64 encoding of raw
+func (s *signer) matchesBase64(raw []byte, encoded string) bool {
+	n := base64.StdEncoding.EncodedLen(len(raw))
+	if cap(s.encoded) < n {
+		s.encoded = make([]byte, n)
+	}
+	expected := s.encoded[:n]
+	base64.StdEncoding.Encode(expected, raw)
+
+	if len(encoded) != n {
+		return false
+	}
+	var diff byte
+	for i := 0; i < n; i++ {
+		diff |= expected[i] ^ encoded[i]
+	}
+	return subtle.ConstantTimeByteEq(diff, 0) == 1
+}
+
+// headerValue returns the first value of a header, looked up by its canonical name without
+// the canonicalization http.Header.Get performs
+func headerValue(header http.Header, canonicalKey string) string {
+	if values := header[canonicalKey]; len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
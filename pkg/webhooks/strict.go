@@ -0,0 +1,51 @@
+package webhooks
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultMaxClockSkew is the clock skew tolerated by strict handlers
+	DefaultMaxClockSkew = 5 * time.Minute
+	// StrictMaxBodyBytes is the body size limit of strict handlers; events are a few hundred bytes
+	StrictMaxBodyBytes = 64 << 10
+)
+
+// NewStrictHandler creates a webhook handler with all hardening enabled, so a security review
+// can check a single constructor: StrictSignature, a MaxClockSkew of DefaultMaxClockSkew,
+// RequireHTTPS, a body limit of StrictMaxBodyBytes and no diagnostic output. An empty secret
+// key rejects every delivery. Behind a proxy terminating TLS, set TrustForwardedHeaders.
+func NewStrictHandler(secretKey string) *Handler {
+	return &Handler{
+		SecretKey:       secretKey,
+		MaxBodyBytes:    StrictMaxBodyBytes,
+		RequireHTTPS:    true,
+		StrictSignature: true,
+		MaxClockSkew:    DefaultMaxClockSkew,
+		Quiet:           true,
+	}
+}
+
+// checkClockSkew rejects a signed date further from the current time than MaxClockSkew
+func (h *Handler) checkClockSkew(date string) error {
+	if h.MaxClockSkew <= 0 {
+		return nil
+	}
+
+	signedAt, err := http.ParseTime(date)
+	if err != nil {
+		return reject(http.StatusUnauthorized, RejectClockSkew, "Invalid date",
+			"the X-Ms-Date header is missing or not an HTTP date")
+	}
+	skew := time.Since(signedAt)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > h.MaxClockSkew {
+		return reject(http.StatusUnauthorized, RejectClockSkew, "Clock skew",
+			fmt.Sprintf("the delivery was signed %s from the current time, more than %s", skew.Round(time.Second), h.MaxClockSkew))
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+// Package workflows provides helpers combining several API calls into common merchant workflows
+package workflows
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zenfulcode/vipps-mobilepay-sdk/pkg/models"
+)
+
+// ShippedItem is a receipt line item and the quantity of it included in a shipment
+type ShippedItem struct {
+	Item     models.LineItem // The line item as sent in the payment receipt
+	Quantity int             // Number of units shipped
+}
+
+// ShippingInfo describes how a shipment is delivered
+type ShippingInfo struct {
+	Carrier        string    // Name of the carrier, e.g. "Posten"
+	TrackingNumber string    // Carrier tracking number
+	TrackingURL    string    // URL where the customer can track the shipment
+	ShippedAt      time.Time // When the shipment left the warehouse
+}
+
+// Capturer captures funds from an authorized payment, implemented by *client.Payment
+type Capturer interface {
+	Capture(reference models.Reference, req models.ModificationRequest) (*models.AdjustmentResponse, error)
+}
+
+// StatusPoster posts the shipping status of an order, typically through the Order Management API
+type StatusPoster interface {
+	PostShippingStatus(reference models.Reference, info ShippingInfo, items []ShippedItem) error
+}
+
+// ShipmentStage identifies the step of a shipment that failed
+type ShipmentStage string
+
+const (
+	// StageCapture means capturing the shipped amount failed and nothing was changed
+	StageCapture ShipmentStage = "CAPTURE"
+	// StageStatus means the amount was captured but posting the shipping status failed
+	StageStatus ShipmentStage = "STATUS"
+)
+
+// ShipmentError is returned when a shipment fails. It tells which step failed and
+// how to recover from the partial result.
+type ShipmentError struct {
+	Stage   ShipmentStage              // The step that failed
+	Capture *models.AdjustmentResponse // The capture result, set if the capture succeeded
+	Err     error                      // The underlying error
+}
+
+// Error implements the error interface
+func (e *ShipmentError) Error() string {
+	return fmt.Sprintf("shipment failed at %s: %v", e.Stage, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *ShipmentError) Unwrap() error {
+	return e.Err
+}
+
+// Guidance describes how to recover from the failure
+func (e *ShipmentError) Guidance() string {
+	switch e.Stage {
+	case StageCapture:
+		return "Nothing was captured. The shipment can be retried as a whole."
+	default:
+		return "The amount was captured. Retry posting the shipping status with PostStatus; " +
+			"do not ship again, as that would capture twice. If the shipment is cancelled, refund the captured amount."
+	}
+}
+
+// Shipment captures the amount of shipped line items and posts their shipping status
+type Shipment struct {
+	payment Capturer
+	status  StatusPoster
+}
+
+// NewShipment creates a new partial shipment workflow
+func NewShipment(payment Capturer, status StatusPoster) *Shipment {
+	return &Shipment{
+		payment: payment,
+		status:  status,
+	}
+}
+
+// CaptureAmount computes the amount to capture for the shipped items: the item price
+// minus the item discount, times the quantity shipped. Amount and Discount are per unit.
+func CaptureAmount(items []ShippedItem) (models.Amount, error) {
+	var total models.Amount
+
+	for _, shipped := range items {
+		item := shipped.Item
+		if shipped.Quantity <= 0 {
+			return models.Amount{}, fmt.Errorf("invalid quantity %d for item %s", shipped.Quantity, item.Name)
+		}
+		if item.Quantity > 0 && shipped.Quantity > item.Quantity {
+			return models.Amount{}, fmt.Errorf("shipped quantity %d exceeds ordered quantity %d for item %s",
+				shipped.Quantity, item.Quantity, item.Name)
+		}
+
+		if total.Currency == "" {
+			total.Currency = item.Amount.Currency
+		} else if item.Amount.Currency != total.Currency {
+			return models.Amount{}, fmt.Errorf("item %s has currency %s, expected %s", item.Name, item.Amount.Currency, total.Currency)
+		}
+
+		total.Value += (item.Amount.Value - item.Discount.Value) * shipped.Quantity
+	}
+
+	if total.Value <= 0 {
+		return models.Amount{}, fmt.Errorf("shipment has nothing to capture")
+	}
+
+	return total, nil
+}
+
+// Ship captures the amount of the shipped items and then posts the shipping status.
+// On failure a *ShipmentError describes which step failed and how to recover.
+func (s *Shipment) Ship(reference models.Reference, items []ShippedItem, info ShippingInfo) (*models.AdjustmentResponse, error) {
+	amount, err := CaptureAmount(items)
+	if err != nil {
+		return nil, &ShipmentError{Stage: StageCapture, Err: err}
+	}
+
+	capture, err := s.payment.Capture(reference, models.ModificationRequest{ModificationAmount: amount})
+	if err != nil {
+		return nil, &ShipmentError{Stage: StageCapture, Err: err}
+	}
+
+	if err := s.PostStatus(reference, items, info); err != nil {
+		return capture, &ShipmentError{Stage: StageStatus, Capture: capture, Err: err}
+	}
+
+	return capture, nil
+}
+
+// PostStatus posts the shipping status without capturing, e.g. to recover from a StageStatus failure
+func (s *Shipment) PostStatus(reference models.Reference, items []ShippedItem, info ShippingInfo) error {
+	if info.ShippedAt.IsZero() {
+		info.ShippedAt = time.Now()
+	}
+
+	if err := s.status.PostShippingStatus(reference, info, items); err != nil {
+		return fmt.Errorf("failed to post shipping status: %w", err)
+	}
+
+	return nil
+}